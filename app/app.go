@@ -1,13 +1,21 @@
 package app
 
 import (
+	"os"
+	"io"
 	"context"
+	"net"
 	"net/http"
 	"log"
 
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
 	"github.com/aniketalshi/go_rest_cache/app/logging"
 	"github.com/aniketalshi/go_rest_cache/app/cache"
 	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/app/rpc"
+	"github.com/aniketalshi/go_rest_cache/app/snapshot"
 	"github.com/aniketalshi/go_rest_cache/config"
 )
 
@@ -16,45 +24,227 @@ type App struct {
 	DBClient *model.DBClient
 	Cacher   *cache.Cacher
 	Handler  http.Handler
+
+	// grpc api, nil unless a port is configured for it
+	grpcServer *grpc.Server
+}
+
+// cachesURL reports whether url is among endpoints of org served from cache
+func cachesURL(org config.OrgConfig, url string) bool {
+	for _, cached := range org.GetCachedURLs() {
+		if cached == url {
+			return true
+		}
+	}
+	return false
 }
 
 // Initialize initializes all high level datastructures
 func (aa *App) Initialize() {
 
+	aa.InitializeCache()
+
+	// seed the cache from snapshot so that we can serve data before first refresh completes
+	if path := config.GetConfig().GetCacheConfig().Snapshot; path != "" {
+		if err := aa.LoadSnapshot(path); err != nil {
+			logging.Logger(context.Background()).Error("Error importing snapshot at startup",
+													   zap.String("path", path),
+													   zap.String("msg", err.Error()))
+		}
+	}
+
+	// set up the mux router and handlers
+	aa.Handler = cache.SetupHandlers(aa.Cacher)
+
+	log.Print("Server Initialized. Starting up...")
+}
+
+// InitializeCache sets up the logger, redis client and cacher without any http handlers.
+// Used on its own by tooling which operates on the cache without serving requests
+func (aa *App) InitializeCache() {
+
 	// initialize the logger
 	logging.InitLogger()
 
 	// setup client to talk to redis instance
 	aa.DBClient = model.SetupDBClient()
-	
+
+	// breaker shared by everything talking to upstream
+	breaker := cache.NewCircuitBreaker()
+
+	// request budget shared by refresh jobs of all orgs
+	budget := cache.NewUpstreamBudget()
+
 	// setup cacher which maintains go routines to periodically cache data
 	aa.Cacher = &cache.Cacher {
-		GitClient: cache.GetNewGithubClient(context.Background()),
 		DBClient: aa.DBClient,
+		Jobs: cache.NewScheduler(),
+		Breaker: breaker,
+		Search: cache.NewSearchIndex(),
+		Updates: cache.NewUpdateFeed(),
+		Limiter: cache.NewRateLimiter(aa.DBClient),
 	}
-	
-	// set up the mux router and handlers
-	aa.Handler = cache.SetupHandlers(aa.Cacher)
 
-	log.Print("Server Initialized. Starting up...")
+	if config.GetConfig().GetProvider() == config.ProviderGitHub {
+		aa.Cacher.Provider = cache.GetNewGithubClient(context.Background(), breaker, budget)
+	} else {
+		aa.Cacher.Provider = cache.NewHTTPProvider(breaker, budget)
+	}
+
+	cache.PublishMetrics(aa.Cacher)
 }
 
 // Run runs the go routines which will start caching the data periodically
 func (aa *App) Run() {
 
-	// channel used for synchronizing two different go routines so that one can 
-	// let the other know when data is cached in redis.
-	isCached := make(chan bool)
-	
-	orgURL := "/orgs/" + config.GetConfig().GetOrg()
-	memberURL := orgURL + "/members"
-	repoURL := orgURL + "/repos"
-
-	go aa.Cacher.CacheRepos(isCached, repoURL)
-	go aa.Cacher.CacheMembers(memberURL)
-	go aa.Cacher.CacheOrgDetails(orgURL)
+	// grpc api is served alongside http when a port is configured for it
+	if port := config.GetConfig().GetGRPCPort(); port != "" {
+		aa.grpcServer = rpc.NewServer(aa.Cacher)
+		go func() {
+			if err := aa.ServeGRPC(port); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	for _, url := range config.GetConfig().GetEndpoints() {
+		go aa.Cacher.CacheEndpoint(url)
+	}
+
+	// orgs and views are only supported on github
+	if !cache.BuildsViews() {
+		return
+	}
+
+	// channel used for synchronizing two different go routines so that one can
+	// let the other know when data of an org is cached in redis.
+	isCached := make(chan string)
+
+	// repositories are always cached since views are built from them
+	for _, org := range config.GetConfig().GetOrgs() {
+
+		go aa.Cacher.CacheRepos(isCached, org)
+
+		if cachesURL(org, org.GetMembersURL()) {
+			go aa.Cacher.CacheMembers(org)
+
+			if org.Members.Profiles {
+				go aa.Cacher.CacheMemberProfiles(org)
+			}
+		}
+		if cachesURL(org, org.GetURL()) {
+			go aa.Cacher.CacheOrgDetails(org)
+		}
+	}
 	go aa.Cacher.CacheRootEndpoint("/")
-	
-	go aa.Cacher.PopulateViews(isCached, repoURL)
+
+	go aa.Cacher.PopulateViews(isCached)
+}
+
+// ServeGRPC serves the grpc api on port, returning once it fails or nil once stopped by Shutdown
+func (aa *App) ServeGRPC(port string) error {
+
+	listener, err := net.Listen("tcp", ":" + port)
+	if err != nil {
+		return err
+	}
+
+	log.Print("Serving grpc on port " + port)
+	return aa.grpcServer.Serve(listener)
+}
+
+// Shutdown stops serving grpc, letting calls in flight complete until ctx is done. Streams of updates,
+// which never end on their own, are ended first. Calls still running once ctx is done are cancelled
+func (aa *App) Shutdown(ctx context.Context) {
+
+	if aa.grpcServer == nil {
+		return
+	}
+
+	aa.Cacher.Updates.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		aa.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		aa.grpcServer.Stop()
+	}
+}
+
+// Warm runs every refresh job once followed by rebuilding the views. Returns the first error encountered
+func (aa *App) Warm() error {
+
+	var refreshers []func() error
+
+	for _, url := range config.GetConfig().GetEndpoints() {
+		url := url
+		refreshers = append(refreshers, func() error { return aa.Cacher.RefreshEndpoint(url) })
+	}
+
+	if cache.BuildsViews() {
+		refreshers = append(refreshers, func() error { return aa.Cacher.RefreshRootEndpoint("/") })
+	}
+
+	for _, org := range config.GetConfig().GetOrgs() {
+		org := org
+
+		if cachesURL(org, org.GetURL()) {
+			refreshers = append(refreshers, func() error { return aa.Cacher.RefreshOrgDetails(org) })
+		}
+		if cachesURL(org, org.GetMembersURL()) {
+			refreshers = append(refreshers, func() error { return aa.Cacher.RefreshMembers(org) })
+			refreshers = append(refreshers, func() error { return aa.Cacher.RefreshMemberProfiles(org) })
+		}
+		refreshers = append(refreshers, func() error { return aa.Cacher.RefreshRepos(org) })
+		refreshers = append(refreshers, func() error { return aa.Cacher.RefreshRepoResources(org) })
+	}
+	// views of each org are built as its repositories are refreshed
+	if cache.BuildsViews() {
+		refreshers = append(refreshers, aa.Cacher.BuildAggregateViews)
+	}
+
+	for _, refresh := range refreshers {
+		if err := refresh(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildViews rebuilds the views of every org and those aggregated across orgs from repositories currently cached
+func (aa *App) RebuildViews() error {
+	return aa.Cacher.RebuildViews()
+}
+
+// LoadSnapshot imports the snapshot archive at path into the cache
+func (aa *App) LoadSnapshot(path string) error {
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	_, err = aa.ImportSnapshot(fp)
+	return err
+}
+
+// ImportSnapshot imports the snapshot archive read from r into the cache and rebuilds the views
+func (aa *App) ImportSnapshot(r io.Reader) (*snapshot.ImportResult, error) {
+
+	result, err := snapshot.Import(aa.DBClient, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// views are not part of snapshots, they are rebuilt from the repositories imported
+	if cache.BuildsViews() {
+		return result, aa.Cacher.RebuildViews()
+	}
+	return result, nil
 }
-	
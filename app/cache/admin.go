@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/gorilla/mux"
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/app/snapshot"
+)
+
+// ListKeys lists cached keys matching the optional pattern query param along with their size, age and ttl
+func (hh *Handlers) ListKeys(w http.ResponseWriter, r *http.Request) {
+
+	keys, err := hh.cacher.DBClient.Keys(patternParam(r))
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+
+	result := make([]*model.KeyInfo, 0, len(keys))
+	for _, key := range keys {
+		info, err := hh.cacher.DBClient.Describe(key)
+		if err != nil {
+			writeAdminError(w, r, 500, err)
+			return
+		}
+		result = append(result, info)
+	}
+
+	writeAdminResponse(w, r, result)
+}
+
+// DumpKey writes out the raw value of key specified in query param
+func (hh *Handlers) DumpKey(w http.ResponseWriter, r *http.Request) {
+
+	key := r.URL.Query().Get("key")
+	response := hh.cacher.DBClient.Get(key)
+	if response == nil {
+		w.WriteHeader(404)
+		w.Write([]byte("key not found"))
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(response)
+}
+
+// InvalidateKeys removes all cached keys matching the pattern query param
+func (hh *Handlers) InvalidateKeys(w http.ResponseWriter, r *http.Request) {
+
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("pattern is required"))
+		return
+	}
+
+	keys, err := hh.cacher.DBClient.Keys(pattern)
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+
+	deleted, err := hh.cacher.DBClient.Delete(keys...)
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+
+	logging.Logger(r.Context()).Info("Invalidated cached keys",
+									 zap.String("pattern", pattern),
+									 zap.Int64("deleted", deleted))
+
+	writeAdminResponse(w, r, map[string]interface{}{"deleted": deleted, "keys": keys})
+}
+
+// ListJobs lists all refresh jobs and their state
+func (hh *Handlers) ListJobs(w http.ResponseWriter, r *http.Request) {
+	writeAdminResponse(w, r, hh.cacher.Jobs.Status())
+}
+
+// ControlJob refreshes, pauses or resumes the job named in query param
+func (hh *Handlers) ControlJob(w http.ResponseWriter, r *http.Request) {
+
+	name := r.URL.Query().Get("name")
+	action := mux.Vars(r)["action"]
+
+	var err error
+	switch action {
+	case "refresh":
+		err = hh.cacher.Jobs.Trigger(name)
+	case "pause":
+		err = hh.cacher.Jobs.Pause(name)
+	case "resume":
+		err = hh.cacher.Jobs.Resume(name)
+	}
+
+	logging.Logger(r.Context()).Info("Admin job action",
+									 zap.String("job", name),
+									 zap.String("action", action))
+
+	if err == ErrUnknownJob {
+		writeAdminError(w, r, 404, err)
+		return
+	}
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+
+	for _, status := range hh.cacher.Jobs.Status() {
+		if status.Name == name {
+			writeAdminResponse(w, r, status)
+			return
+		}
+	}
+}
+
+// ExportSnapshot streams a snapshot archive of cached keys matching the optional pattern query param
+func (hh *Handlers) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename=cache.snap")
+
+	if _, err := snapshot.Export(hh.cacher.DBClient, patternParam(r), w); err != nil {
+		// headers are already sent at this point, all we can do is log
+		logging.Logger(r.Context()).Error("Error exporting snapshot",
+										  zap.String("msg", err.Error()))
+	}
+}
+
+// ImportSnapshot loads the snapshot archive sent in request body into the cache
+func (hh *Handlers) ImportSnapshot(w http.ResponseWriter, r *http.Request) {
+
+	result, err := snapshot.Import(hh.cacher.DBClient, r.Body)
+	if err != nil {
+		writeAdminError(w, r, 400, err)
+		return
+	}
+
+	// views are not part of snapshots, they are rebuilt from the repositories imported
+	if BuildsViews() {
+		if err := hh.cacher.RebuildViews(); err != nil {
+			writeAdminError(w, r, 500, err)
+			return
+		}
+	}
+
+	writeAdminResponse(w, r, result)
+}
+
+// ListAPIKeys lists client api keys from config and redis, without the keys themselves
+func (hh *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+
+	keys, err := hh.auth.ListAPIKeys()
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+	writeAdminResponse(w, r, keys)
+}
+
+// CreateAPIKey generates a key for the identity and scopes sent in request body.
+// The key is only ever returned in this response
+func (hh *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	key := &APIKey{}
+	if err := json.NewDecoder(r.Body).Decode(key); err != nil || key.ID == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("body must be json with id, scopes and optional proxy restrictions"))
+		return
+	}
+
+	raw, err := hh.auth.CreateAPIKey(key)
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+
+	logging.Logger(r.Context()).Info("Api key created",
+									 zap.String("id", key.ID),
+									 zap.Strings("scopes", key.Scopes))
+
+	writeAdminResponse(w, r, map[string]interface{}{"id": key.ID, "key": raw})
+}
+
+// DeleteAPIKey removes keys stored in redis with the id query param
+func (hh *Handlers) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	id := r.URL.Query().Get("id")
+
+	found, err := hh.auth.DeleteAPIKey(id)
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+	if !found {
+		w.WriteHeader(404)
+		w.Write([]byte("no api key with id " + id + " in store"))
+		return
+	}
+
+	logging.Logger(r.Context()).Info("Api key deleted", zap.String("id", id))
+	writeAdminResponse(w, r, map[string]string{"deleted": id})
+}
+
+// patternParam returns the pattern query param, matching everything if not set
+func patternParam(r *http.Request) string {
+	if pattern := r.URL.Query().Get("pattern"); pattern != "" {
+		return pattern
+	}
+	return "*"
+}
+
+func writeAdminResponse(w http.ResponseWriter, r *http.Request, result interface{}) {
+
+	js, err := json.Marshal(result)
+	if err != nil {
+		writeAdminError(w, r, 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(js)
+}
+
+func writeAdminError(w http.ResponseWriter, r *http.Request, code int, err error) {
+
+	logging.Logger(r.Context()).Error("Admin request failed",
+									  zap.Int("code", code),
+									  zap.String("msg", err.Error()))
+	w.WriteHeader(code)
+	w.Write([]byte(err.Error()))
+}
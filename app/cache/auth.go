@@ -0,0 +1,286 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// scopes an api key may be granted
+const (
+	ScopeCachedRead = "cached-read"
+	ScopeViews      = "views"
+	ScopeProxy      = "proxy"
+	ScopeAdmin      = "admin"
+)
+
+// routeScopes maps each class of route to the scope required for it
+var routeScopes = map[string]string{
+	RouteCached:  ScopeCachedRead,
+	RouteViews:   ScopeViews,
+	RouteProxied: ScopeProxy,
+	RouteAdmin:   ScopeAdmin,
+}
+
+// apiKeysHash is the redis hash holding api keys managed through admin api, keyed by sha256 of the key
+const apiKeysHash = model.InternalPrefix + "apikeys"
+
+// APIKey is the identity of an authenticated client
+type APIKey = config.APIKeyConfig
+
+type apiKeyCtxKeyType int
+
+const apiKeyCtxKey apiKeyCtxKeyType = iota
+
+// APIKeyFromContext returns the api key the request was authenticated with, nil if none
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyCtxKey).(*APIKey)
+	return key
+}
+
+// HasScope reports whether key was granted scope
+func HasScope(key *APIKey, scope string) bool {
+	if key == nil {
+		return false
+	}
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates clients with api keys from config or redis and checks their scopes
+type Authenticator struct {
+	db *model.DBClient
+
+	// keys from config indexed by sha256 of the key
+	configured map[string]*APIKey
+}
+
+// NewAuthenticator returns authenticator for keys configured in config and stored in redis through db
+func NewAuthenticator(db *model.DBClient) *Authenticator {
+
+	aa := &Authenticator{db: db, configured: make(map[string]*APIKey)}
+
+	for i := range config.GetConfig().GetAuthConfig().Keys {
+		key := config.GetConfig().GetAuthConfig().Keys[i]
+
+		hash := strings.ToLower(key.KeySHA256)
+		if key.Key != "" {
+			hash = hashAPIKey(key.Key)
+		}
+		aa.configured[hash] = &key
+	}
+	return aa
+}
+
+// Middleware rejects requests without a valid api key with 401, and those whose key lacks the scope
+// for the route with 403. Every authenticated request is audit logged with the key id.
+// Admin routes may alternatively be authorized by admin token, which is left to AdminAuth
+func (aa *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		class := classifyRoute(r)
+		if !config.GetConfig().GetAuthConfig().Enabled || class == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := r.Header.Get(APIKeyHeader)
+		if raw == "" && class == RouteAdmin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := aa.lookup(raw)
+		if err != nil {
+			logging.Logger(r.Context()).Error("Error looking up api key",
+											  zap.String("msg", err.Error()))
+			w.WriteHeader(500)
+			return
+		}
+
+		if key == nil {
+			logging.Logger(r.Context()).Warn("Request without valid api key rejected",
+											 zap.String("method", r.Method),
+											 zap.String("path", r.URL.Path))
+			w.WriteHeader(401)
+			w.Write([]byte("valid api key required in " + APIKeyHeader + " header"))
+			return
+		}
+
+		// every log line of this request carries the key id from now on
+		ctx := logging.NewContext(r.Context(), zap.String("apiKeyID", key.ID))
+		r = r.WithContext(context.WithValue(ctx, apiKeyCtxKey, key))
+
+		allowed, reason := authorize(key, class, r)
+
+		logging.Logger(r.Context()).Info("Audit",
+										 zap.String("method", r.Method),
+										 zap.String("path", r.URL.Path),
+										 zap.String("class", class),
+										 zap.Bool("allowed", allowed),
+										 zap.String("reason", reason))
+
+		if !allowed {
+			w.WriteHeader(403)
+			w.Write([]byte(reason))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize checks key has scope for class of route, and for proxied routes that method and path are allowed
+func authorize(key *APIKey, class string, r *http.Request) (bool, string) {
+
+	if !HasScope(key, routeScopes[class]) {
+		return false, "api key lacks scope " + routeScopes[class]
+	}
+
+	if class != RouteProxied {
+		return true, "scope " + routeScopes[class]
+	}
+
+	methods := key.Proxy.Methods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD"}
+	}
+	if !containsFold(methods, r.Method) {
+		return false, "api key may not proxy method " + r.Method
+	}
+
+	if len(key.Proxy.Paths) == 0 {
+		return true, "scope " + ScopeProxy
+	}
+	for _, pattern := range key.Proxy.Paths {
+		if matched, _ := path.Match(pattern, r.URL.Path); matched {
+			return true, "proxy path " + pattern
+		}
+	}
+	return false, "api key may not proxy path " + r.URL.Path
+}
+
+// Authenticate returns the api key raw is, nil if it is unknown. Used by servers other than http
+func (aa *Authenticator) Authenticate(raw string) (*APIKey, error) {
+	return aa.lookup(raw)
+}
+
+// lookup finds the key in config first and redis second, nil if it is unknown
+func (aa *Authenticator) lookup(raw string) (*APIKey, error) {
+
+	if raw == "" {
+		return nil, nil
+	}
+
+	hash := hashAPIKey(raw)
+	if key, ok := aa.configured[hash]; ok {
+		return key, nil
+	}
+
+	content, err := aa.db.HGet(apiKeysHash, hash)
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	key := &APIKey{}
+	if err := json.Unmarshal(content, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CreateAPIKey generates a new random key for the given identity and stores it in redis.
+// Only the hash of the key is stored, so the returned raw key can't be recovered later
+func (aa *Authenticator) CreateAPIKey(key *APIKey) (string, error) {
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	raw := hex.EncodeToString(secret)
+
+	js, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := aa.db.HSet(apiKeysHash, hashAPIKey(raw), js); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ListAPIKeys returns keys stored in redis along with those from config
+func (aa *Authenticator) ListAPIKeys() ([]*APIKey, error) {
+
+	stored, err := aa.db.HGetAll(apiKeysHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*APIKey
+	for _, key := range aa.configured {
+		keys = append(keys, key)
+	}
+
+	for _, content := range stored {
+		key := &APIKey{}
+		if err := json.Unmarshal([]byte(content), key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey removes keys with id from redis, returning whether any were found.
+// Keys from config can only be removed by editing config
+func (aa *Authenticator) DeleteAPIKey(id string) (bool, error) {
+
+	stored, err := aa.db.HGetAll(apiKeysHash)
+	if err != nil {
+		return false, err
+	}
+
+	var hashes []string
+	for hash, content := range stored {
+		key := &APIKey{}
+		if err := json.Unmarshal([]byte(content), key); err == nil && key.ID == id {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	if len(hashes) == 0 {
+		return false, nil
+	}
+	return true, aa.db.HDel(apiKeysHash, hashes...)
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsFold(values []string, value string) bool {
+	for _, vv := range values {
+		if strings.EqualFold(vv, value) {
+			return true
+		}
+	}
+	return false
+}
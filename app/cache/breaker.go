@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned instead of calling upstream while the breaker is open
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// BreakerStatus is a point in time snapshot of the breaker
+type BreakerStatus struct {
+	State    string    `json:"state"`
+	Failures int       `json:"consecutive_failures"`
+	OpenedAt time.Time `json:"opened_at,omitempty"`
+	Trips    int       `json:"trips"`
+	Rejected int       `json:"rejected"`
+}
+
+// CircuitBreaker stops calls to upstream after consecutive failures, letting a limited number of
+// probes through once open duration elapses to find out whether upstream has recovered
+type CircuitBreaker struct {
+	mu     sync.Mutex
+	conf   config.BreakerConfig
+	status BreakerStatus
+
+	// probes in flight and succeeded while half-open
+	probing   int
+	succeeded int
+}
+
+// NewCircuitBreaker returns a closed breaker configured from config
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		conf:   config.GetConfig().GetBreakerConfig(),
+		status: BreakerStatus{State: BreakerClosed},
+	}
+}
+
+// Allow reports whether a call to upstream may proceed, returning ErrCircuitOpen if not.
+// Every allowed call must be followed by Record
+func (cb *CircuitBreaker) Allow() error {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.status.State == BreakerOpen && time.Since(cb.status.OpenedAt) >= cb.conf.GetOpenDuration() {
+		cb.transition(BreakerHalfOpen)
+	}
+
+	switch cb.status.State {
+	case BreakerOpen:
+		cb.status.Rejected += 1
+		return ErrCircuitOpen
+
+	case BreakerHalfOpen:
+		if cb.probing+cb.succeeded >= cb.conf.GetHalfOpenProbes() {
+			cb.status.Rejected += 1
+			return ErrCircuitOpen
+		}
+		cb.probing += 1
+	}
+	return nil
+}
+
+// Record records the outcome of a call allowed through
+func (cb *CircuitBreaker) Record(success bool) {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.status.State == BreakerHalfOpen && cb.probing > 0 {
+		cb.probing -= 1
+
+		if !success {
+			cb.trip()
+			return
+		}
+
+		cb.succeeded += 1
+		if cb.succeeded >= cb.conf.GetHalfOpenProbes() {
+			cb.status.Failures = 0
+			cb.transition(BreakerClosed)
+		}
+		return
+	}
+
+	if success {
+		cb.status.Failures = 0
+		return
+	}
+
+	cb.status.Failures += 1
+	if cb.status.State == BreakerClosed && cb.status.Failures >= cb.conf.GetFailureThreshold() {
+		cb.trip()
+	}
+}
+
+// Release gives back a call allowed through without recording an outcome, e.g when client went away
+func (cb *CircuitBreaker) Release() {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.status.State == BreakerHalfOpen && cb.probing > 0 {
+		cb.probing -= 1
+	}
+}
+
+// RetryAfter returns how long until the breaker lets probes through
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.status.State != BreakerOpen {
+		return 0
+	}
+	return cb.conf.GetOpenDuration() - time.Since(cb.status.OpenedAt)
+}
+
+// Status returns the current state of the breaker
+func (cb *CircuitBreaker) Status() BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.status
+}
+
+func (cb *CircuitBreaker) trip() {
+	cb.status.OpenedAt = time.Now().UTC()
+	cb.status.Trips += 1
+	cb.transition(BreakerOpen)
+}
+
+func (cb *CircuitBreaker) transition(state string) {
+
+	logging.Logger(context.Background()).Warn("Upstream circuit breaker changed state",
+											  zap.String("from", cb.status.State),
+											  zap.String("to", state),
+											  zap.Int("failures", cb.status.Failures))
+
+	cb.status.State = state
+	cb.probing = 0
+	cb.succeeded = 0
+}
+
+// Transport wraps next so that every round trip goes through the breaker.
+// Transport errors and 5xx responses count as failures
+func (cb *CircuitBreaker) Transport(next http.RoundTripper) http.RoundTripper {
+	return &breakerTransport{breaker: cb, next: next}
+}
+
+type breakerTransport struct {
+	breaker *CircuitBreaker
+	next    http.RoundTripper
+}
+
+func (bt *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	if err := bt.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := bt.next.RoundTrip(req)
+
+	// caller giving up says nothing about health of upstream
+	if err != nil && req.Context().Err() != nil {
+		bt.breaker.Release()
+		return resp, err
+	}
+
+	bt.breaker.Record(err == nil && resp.StatusCode < 500)
+	return resp, err
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// breaker steps, elapse lets the open duration pass
+const (
+	stepAllow   = "allow"
+	stepReject  = "reject"
+	stepSuccess = "success"
+	stepFailure = "failure"
+	stepRelease = "release"
+	stepElapse  = "elapse"
+)
+
+func newTestBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		conf:   config.BreakerConfig{FailureThreshold: 2, OpenDuration: 30, HalfOpenProbes: 2},
+		status: BreakerStatus{State: BreakerClosed},
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+
+	logging.InitLogger()
+
+	tests := []struct {
+		name     string
+		steps    []string
+		state    string
+		failures int
+		trips    int
+		rejected int
+	}{
+		{"closed", nil, BreakerClosed, 0, 0, 0},
+		{"failures below threshold", []string{stepFailure}, BreakerClosed, 1, 0, 0},
+		{"success resets failures", []string{stepFailure, stepSuccess, stepFailure}, BreakerClosed, 1, 0, 0},
+		{"consecutive failures trip", []string{stepFailure, stepFailure}, BreakerOpen, 2, 1, 0},
+		{"open rejects calls", []string{stepFailure, stepFailure, stepReject, stepReject}, BreakerOpen, 2, 1, 2},
+		{"half-open after open duration", []string{stepFailure, stepFailure, stepElapse, stepAllow},
+			BreakerHalfOpen, 2, 1, 0},
+		{"half-open limits probes in flight",
+			[]string{stepFailure, stepFailure, stepElapse, stepAllow, stepAllow, stepReject},
+			BreakerHalfOpen, 2, 1, 1},
+		{"released probes make room for others",
+			[]string{stepFailure, stepFailure, stepElapse, stepAllow, stepAllow, stepRelease, stepAllow},
+			BreakerHalfOpen, 2, 1, 0},
+		{"successful probes close",
+			[]string{stepFailure, stepFailure, stepElapse, stepAllow, stepAllow, stepSuccess, stepSuccess},
+			BreakerClosed, 0, 1, 0},
+		{"succeeded probes count against the limit",
+			[]string{stepFailure, stepFailure, stepElapse, stepAllow, stepSuccess, stepAllow, stepReject},
+			BreakerHalfOpen, 2, 1, 1},
+		{"failed probe trips again",
+			[]string{stepFailure, stepFailure, stepElapse, stepAllow, stepSuccess, stepAllow, stepFailure, stepReject},
+			BreakerOpen, 2, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			cb := newTestBreaker()
+			for i, step := range tt.steps {
+				switch step {
+				case stepAllow, stepReject:
+					err := cb.Allow()
+					if want := step == stepReject; (err == ErrCircuitOpen) != want {
+						t.Fatalf("step %d: Allow() = %v, want rejected %v", i, err, want)
+					}
+				case stepSuccess, stepFailure:
+					cb.Record(step == stepSuccess)
+				case stepRelease:
+					cb.Release()
+				case stepElapse:
+					cb.status.OpenedAt = cb.status.OpenedAt.Add(-cb.conf.GetOpenDuration())
+				}
+			}
+
+			status := cb.Status()
+			if status.State != tt.state || status.Failures != tt.failures || status.Trips != tt.trips ||
+				status.Rejected != tt.rejected {
+				t.Errorf("Status() = %s with %d failures, %d trips, %d rejected, want %s with %d, %d, %d",
+					status.State, status.Failures, status.Trips, status.Rejected,
+					tt.state, tt.failures, tt.trips, tt.rejected)
+			}
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (rt roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt(req)
+}
+
+func TestBreakerTransport(t *testing.T) {
+
+	logging.InitLogger()
+
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		cancel   bool
+		failures int
+	}{
+		{"success", 200, nil, false, 0},
+		{"client errors are not upstream failures", 404, nil, false, 0},
+		{"server errors", 502, nil, false, 1},
+		{"transport errors", 0, errors.New("connection refused"), false, 1},
+		{"callers giving up", 0, context.Canceled, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			cb := newTestBreaker()
+			transport := cb.Transport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &http.Response{StatusCode: tt.status}, nil
+			}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			} else {
+				defer cancel()
+			}
+
+			req := httptest.NewRequest("GET", "http://upstream/orgs/Netflix", nil).WithContext(ctx)
+			if _, err := transport.RoundTrip(req); err != tt.err {
+				t.Fatalf("RoundTrip() error = %v, want %v", err, tt.err)
+			}
+
+			if status := cb.Status(); status.Failures != tt.failures {
+				t.Errorf("failures = %d, want %d", status.Failures, tt.failures)
+			}
+		})
+	}
+
+	// an open breaker doesn't call upstream at all
+	cb := newTestBreaker()
+	cb.Record(false)
+	cb.Record(false)
+
+	called := false
+	transport := cb.Transport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return &http.Response{StatusCode: 200}, nil
+	}))
+
+	req := httptest.NewRequest("GET", "http://upstream/orgs/Netflix", nil)
+	if _, err := transport.RoundTrip(req); err != ErrCircuitOpen || called {
+		t.Errorf("RoundTrip() through open breaker = %v, called upstream %v", err, called)
+	}
+
+	// time left is counted down from when the breaker opened
+	if retry := cb.RetryAfter(); retry <= 29*time.Second || retry > 30*time.Second {
+		t.Errorf("RetryAfter() = %v, want just under 30s", retry)
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// budgetKey is the single bucket all refresh jobs draw from
+const budgetKey = "budget"
+
+type budgetExemptCtxKeyType int
+
+const budgetExemptCtxKey budgetExemptCtxKeyType = iota
+
+// withoutBudget marks requests made on behalf of clients with their own token, which don't
+// spend the budget of our token
+func withoutBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, budgetExemptCtxKey, true)
+}
+
+// UpstreamBudget paces requests refresh jobs of every org make upstream, so that together they
+// stay within the rate limit of our token
+type UpstreamBudget struct {
+	limit   config.Limit
+	enabled bool
+	store   *memoryTokenStore
+}
+
+// NewUpstreamBudget returns budget configured from config
+func NewUpstreamBudget() *UpstreamBudget {
+
+	limit, enabled := config.GetConfig().GetCacheConfig().Budget.GetLimit()
+
+	return &UpstreamBudget{limit: limit, enabled: enabled, store: newMemoryTokenStore()}
+}
+
+// Wait blocks until the budget allows another request or ctx is done
+func (ub *UpstreamBudget) Wait(ctx context.Context) error {
+
+	if !ub.enabled {
+		return nil
+	}
+
+	for {
+		allowed, remaining, _ := ub.store.take(budgetKey, ub.limit)
+		if allowed {
+			return nil
+		}
+
+		// time until next token is available
+		wait := time.Duration((1 - remaining) / ub.limit.Rate * float64(time.Second))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Transport wraps next so that every round trip waits for the budget first
+func (ub *UpstreamBudget) Transport(next http.RoundTripper) http.RoundTripper {
+	return &budgetTransport{budget: ub, next: next}
+}
+
+type budgetTransport struct {
+	budget *UpstreamBudget
+	next   http.RoundTripper
+}
+
+func (bt *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	if exempt, _ := req.Context().Value(budgetExemptCtxKey).(bool); !exempt {
+		if err := bt.budget.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return bt.next.RoundTrip(req)
+}
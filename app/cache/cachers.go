@@ -1,11 +1,11 @@
 package cache
 
 import (
-	"time"
 	"encoding/json"
-	"sort"
+	"errors"
 	"context"
-	"strconv"
+	"net/url"
+	"strings"
 
 	"go.uber.org/zap"
 
@@ -18,206 +18,502 @@ import (
 // Cacher is responsible for maintaining go routines which peridoically cache data in redis 
 // and apis to get data back from redis
 type Cacher struct {
-	GitClient *GithubClient
+	Provider Provider
 	DBClient *model.DBClient
+	Jobs *Scheduler
+	Breaker *CircuitBreaker
+
+	// Search indexes cached repositories and members in memory
+	Search *SearchIndex
+
+	// Updates announces changes of cached keys and views to watchers
+	Updates *UpdateFeed
+
+	// Limiter holds the rate limit buckets of clients, shared by every server so that a client gets
+	// its limits once however it calls us
+	Limiter *RateLimiter
+}
+
+// ViewsJob is the name under which the job populating views aggregated across orgs is registered
+const ViewsJob = "views"
+
+// keys under which views aggregated across orgs sorted by each parameter are cached
+const (
+	ViewByForks       = "top-repo-by-forks"
+	ViewByLastUpdated = "top-repo-by-lastupdated"
+	ViewByOpenIssues  = "top-repo-by-openissues"
+	ViewByStars       = "top-repo-by-stars"
+)
+
+// ViewKeys lists the keys of all views built from repository data
+var ViewKeys = []string{ViewByForks, ViewByLastUpdated, ViewByOpenIssues, ViewByStars}
+
+// ViewKey returns the key view is cached under for org, or the key of view aggregated across
+// all orgs if org is empty
+func ViewKey(view string, org string) string {
+	if org == "" {
+		return view
+	}
+	return view + ":" + org
+}
+
+// BuildsViews reports whether orgs are cached and views built over them, which is only done for github
+func BuildsViews() bool {
+	return config.GetConfig().GetProvider() == config.ProviderGitHub
+}
+
+// AllViewKeys lists the keys of every view, aggregated across orgs and of each org.
+// Views are only built over github repositories and members
+func AllViewKeys() []string {
+
+	if !BuildsViews() {
+		return nil
+	}
+
+	keys := append(append([]string{}, ViewKeys...), MemberViewKeys...)
+	for _, org := range config.GetConfig().GetOrgs() {
+		for _, view := range append(append([]string{}, ViewKeys...), orgMemberViews(org)...) {
+			keys = append(keys, ViewKey(view, org.Name))
+		}
+	}
+	return keys
 }
 
 // ViewResult is a structure for extracting data into custom views we serve to clients
-// for viewing repository by top N parameters
+// for viewing repository by top N parameters. Repo holds the login in views of members
 type ViewResult struct {
 	Repo string	 `json:"repo"`
 	Count string `json:"count"`
 }
 
-// schedules the go routine to run at periodic intervals
-func (cc *Cacher) schedule(cachingFunc func()) {
-	
-	// Get the refresh rate from config
-	refreshDuration := time.Duration(config.GetConfig().GetCacheConfig().RefreshInterval)
+// schedules the go routine caching url to run at periodic intervals and registers it as a job named by url
+func (cc *Cacher) schedule(url string, cachingFunc func() error) {
 	
-	// ticker goes of at fixed intervals
-	ticker := time.NewTicker(refreshDuration * time.Second)
-	defer ticker.Stop()
+	// Get the refresh rate from config, orgs may refresh at their own rate
+	refreshDuration := config.GetConfig().GetRefreshInterval(url)
 	
-	for ; true; <- ticker.C{
-		cachingFunc()
-	}
+	cc.Jobs.Schedule(url, refreshDuration, cachingFunc)
 }
 
 // Queries the github api to fetch all the repos for a given organization and caches 
 // the response into the redis
-func (cc *Cacher) CacheRepos(isCached chan<- bool, url string) {
-
-	cc.schedule(func() {
-	        repos, err := cc.GitClient.GetRepositories()
-	        if err != nil {
-				logging.Logger(context.Background()).Fatal("Error getting the repositories",
-					      									zap.String("msg", err.Error()))
-	        }
-
-	        js, err := json.Marshal(repos)
-	        if err != nil {
-				logging.Logger(context.Background()).Fatal("Error trying to marshal repository struct",
-															zap.String("msg", err.Error()))
-	        }
-	    	
-	        cc.DBClient.Set(url, js)
-	
-			// Nofity the go routine populating views that we have cached new repository data into redis
-			isCached <- true
+func (cc *Cacher) CacheRepos(isCached chan<- string, org config.OrgConfig) {
+
+	resourcesCached := false
+	cc.schedule(org.GetReposURL(), func() error {
+		if err := cc.RefreshRepos(org); err != nil {
+			return err
+		}
+
+		// sub-resources are cached for the repositories just indexed, so start caching them once there are some
+		if !resourcesCached && len(org.Repos.GetResourcePaths()) > 0 {
+			resourcesCached = true
+			go cc.CacheRepoResources(org)
+		}
+
+		// Nofity the go routine populating views that we have cached new repository data into redis
+		isCached <- org.Name
+		return nil
 	})
 }
 
-func (cc *Cacher) SortAndSetView(repos []github.Repository, key string, comparator func(int, int)bool) {
+// RefreshRepos fetches all the repos for the organization once and caches them
+func (cc *Cacher) RefreshRepos(org config.OrgConfig) error {
 
-	// sort repos by comparator
-	sort.Slice(repos, comparator)
+	repos, err := cc.fetchRepos(org)
+	if err != nil {
+		logging.Logger(context.Background()).Error("Error getting the repositories",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return err
+	}
 
 	js, err := json.Marshal(repos)
 	if err != nil {
-		logging.Logger(context.Background()).Fatal("Error trying to marshal repository struct",
+		logging.Logger(context.Background()).Error("Error trying to marshal repository struct",
 												   zap.String("msg", err.Error()))
+		return err
 	}
 
-	cc.DBClient.Set(key, js)
+	cc.store(org.GetReposURL(), js)
+
+	// views of org are built along with the index
+	if err := cc.IndexRepos(org, repos); err != nil {
+		logging.Logger(context.Background()).Error("Error indexing repositories",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return err
+	}
+	return nil
 }
 
-func (cc *Cacher) PopulateViews(isCached <-chan bool, url string) {
-	
-	cc.schedule(func() {
-	
-		// waiting on signal from go routine which has cached new repository data into redis
-		<-isCached
-	
-		resp := cc.GetCachedEndpoint(url)
-	    var repos []github.Repository
-
-	    if err := json.Unmarshal(resp, &repos); err != nil {
-	    	logging.Logger(context.Background()).Error("Error unmarshalling repository struct",
-	    							  zap.String("msg", err.Error()))
-        }	
-
-	    
-	    // sort repo by forks and insert the sorted list in redis
-	    cc.SortAndSetView(repos, "top-repo-by-forks", func(i, j int) bool {
-	    	return *(repos[i].ForksCount) > *(repos[j].ForksCount)
-	    })
-
-	    // sort by last updated 	
-	    cc.SortAndSetView(repos, "top-repo-by-lastupdated", func(i, j int) bool {
-	    	return repos[i].UpdatedAt.Time.Sub(repos[j].UpdatedAt.Time) > 0
-	    })
-	    
-	    // sort by number of open issues
-	    cc.SortAndSetView(repos, "top-repo-by-openissues", func(i, j int) bool {
-	    	return *(repos[i].OpenIssuesCount) > *(repos[j].OpenIssuesCount)
-	    })
-
-	    // sort by number of stars
-	    cc.SortAndSetView(repos, "top-repo-by-stars", func(i, j int) bool {
-	    	return *(repos[i].StargazersCount) > *(repos[j].StargazersCount)
-	    })
-	})
+// PopulateViews rebuilds views aggregated across orgs and the search index every time a go routine
+// caching repositories signals that it has cached and indexed new repository data of an org into redis
+func (cc *Cacher) PopulateViews(isCached <-chan string) {
+
+	cc.Jobs.Register(ViewsJob, cc.BuildAggregateViews)
+	cc.Jobs.Register(SearchJob, cc.BuildSearchIndex)
+
+	for range isCached {
+		cc.Jobs.Run(ViewsJob)
+		cc.Jobs.Run(SearchJob)
+	}
 }
 
-func (cc *Cacher) GetView(ctx context.Context, key string, limit int) ([]ViewResult, error) {
+// RebuildViews rebuilds the views of every org, those aggregated across orgs and the search index from
+// repositories and members currently cached
+func (cc *Cacher) RebuildViews() error {
 
-	serializedRepos := cc.DBClient.Get(key)
-	
-	var repos []github.Repository
-	if err := json.Unmarshal(serializedRepos, &repos); err != nil {
+	if !BuildsViews() {
+		return errors.New("views are only built over github repositories")
+	}
 
-		logging.Logger(ctx).Error("Error unmarshalling repository struct",
-								  zap.String("msg", err.Error()))
-		return nil, err
-    }	
+	for _, org := range config.GetConfig().GetOrgs() {
+		if cc.IsWarm(org.GetReposURL()) {
+			if err := cc.BuildViews(org); err != nil {
+				return err
+			}
+		}
+		if org.CachesMembers() && cc.IsWarm(org.GetMembersURL()) {
+			if err := cc.BuildMemberViews(org); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cc.BuildAggregateViews(); err != nil {
+		return err
+	}
+	return cc.BuildSearchIndex()
+}
 
-	logging.Logger(ctx).Info("Repositories fetched for view",
-							zap.Int("Num Repos", len(repos)))
-	
-	var result []ViewResult
-	counter := 1
-
-	for _, repo := range repos {
-		
-		var count string
-		if key == "top-repo-by-forks" {
-			count = strconv.Itoa(*repo.ForksCount)
-		} else if key == "top-repo-by-lastupdated" {
-			count = repo.UpdatedAt.Time.Format(time.RFC3339)
-		} else if key == "top-repo-by-openissues" {
-			count = strconv.Itoa(*repo.OpenIssuesCount)
-		} else if key == "top-repo-by-stars" {
-			count = strconv.Itoa(*repo.StargazersCount)
+// BuildViews indexes the cached repositories of org, building views of org along the way. Refreshes
+// index repositories as they fetch them, so this is only needed for repositories cached otherwise,
+// such as from a snapshot
+func (cc *Cacher) BuildViews(org config.OrgConfig) error {
+
+	repos, err := cc.cachedRepos(org)
+	if err != nil {
+		return err
+	}
+	return cc.IndexRepos(org, repos)
+}
+
+// BuildAggregateViews merges views of every org into views aggregated across orgs.
+// Orgs whose views are not built yet are left out
+func (cc *Cacher) BuildAggregateViews() error {
+
+	for _, view := range append(append([]string{}, ViewKeys...), MemberViewKeys...) {
+
+		var keys, etags []string
+		for _, org := range config.GetConfig().GetOrgNames() {
+
+			meta, err := cc.DBClient.GetMeta(ViewKey(view, org))
+			if err != nil {
+				return err
+			}
+			if meta == nil {
+				continue
+			}
+
+			keys = append(keys, viewIndexKey(view, org))
+			etags = append(etags, meta.ETag)
 		}
 
-		res := ViewResult{
-			Repo: *repo.FullName,
-			Count: count,
+		// members of several orgs are ranked once rather than by the sum of their scores
+		union := cc.DBClient.UnionSortedSets
+		if isMemberView(view) {
+			union = cc.DBClient.UnionSortedSetsMax
+		}
+		if err := union(viewIndexKey(view, ""), keys...); err != nil {
+			return err
+		}
+
+		// views used to be stored as json under their view key
+		if err := cc.DBClient.DeleteValues(view); err != nil {
+			return err
 		}
-		
-		result = append(result, res)
 
-		if counter == limit {
-			break
+		// aggregated view changes only when a view it is made of does
+		etag := model.ContentHash([]byte(strings.Join(etags, "\n")))
+		if err := cc.DBClient.Touch(ViewKey(view, ""), etag); err != nil {
+			return err
 		}
-		counter += 1
+		cc.notify(ViewKey(view, ""))
+	}
+	return nil
+}
+
+// ListRepos returns json of the cached repositories of org, filtered and sorted by type, sort and
+// direction in query the way the org repos endpoint does. Returns nil if they are not cached yet
+func (cc *Cacher) ListRepos(org config.OrgConfig, query url.Values) ([]json.RawMessage, error) {
+
+	resp := cc.GetCachedEndpoint(org.GetReposURL())
+	if len(resp) == 0 {
+		return nil, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(resp, &items); err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	return filterRepos(items, query)
+}
+
+// cachedRepos returns the repositories of org currently cached
+func (cc *Cacher) cachedRepos(org config.OrgConfig) ([]*github.Repository, error) {
+
+	resp := cc.GetCachedEndpoint(org.GetReposURL())
+	var repos []*github.Repository
+
+	if err := json.Unmarshal(resp, &repos); err != nil {
+		logging.Logger(context.Background()).Error("Error unmarshalling repository struct",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return nil, err
+	}
+	return repos, nil
+}
+
+// GetView returns the first limit entries of view of org, or of view aggregated across orgs if org is empty
+func (cc *Cacher) GetView(ctx context.Context, view string, org string, limit int) ([]ViewResult, error) {
+
+	members, err := cc.DBClient.TopScored(viewIndexKey(view, org), limit)
+	if err != nil {
+
+		logging.Logger(ctx).Error("Error reading view",
+								  zap.String("view", ViewKey(view, org)),
+								  zap.String("msg", err.Error()))
+		return nil, err
+	}
+
+	logging.Logger(ctx).Info("Repositories fetched for view",
+							 zap.Int("Num Repos", len(members)))
+
+	var result []ViewResult
+	for _, member := range members {
+		result = append(result, ViewResult{
+			Repo: viewMemberName(view, member.Member),
+			Count: formatScore(view, member.Score),
+		})
 	}
 	return result, nil
 }
 
 // CacheMembers caches data related to member of org into redis
-func (cc *Cacher) CacheMembers(url string) {
-
-	cc.schedule(func() {
-	    users, err := cc.GitClient.GetMembers()
-
-	    if err != nil {
-	    	logging.Logger(context.Background()).Error("Error fetching members of org",
-											         zap.String("msg", err.Error()))
-	    } else {
-
-	        js, err := json.Marshal(users)
-	        if err != nil {
-	        	logging.Logger(context.Background()).Fatal("Error trying to marshal users struct",
-		    									         zap.String("msg", err.Error()))
-	        }
-	        
-	        cc.DBClient.Set(url, js)
+func (cc *Cacher) CacheMembers(org config.OrgConfig) {
+
+	cc.schedule(org.GetMembersURL(), func() error {
+		if err := cc.RefreshMembers(org); err != nil {
+			return err
 		}
+
+		// members are searched and viewed along with repositories, so don't wait for the next repository refresh
+		cc.Jobs.Run(ViewsJob)
+		cc.Jobs.Run(SearchJob)
+		return nil
 	})
 }
 
+// RefreshMembers fetches members of org once and caches them
+func (cc *Cacher) RefreshMembers(org config.OrgConfig) error {
+
+	users, err := cc.fetchMembers(org)
+	if err != nil {
+		logging.Logger(context.Background()).Error("Error fetching members of org",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return err
+	}
+
+	js, err := json.Marshal(users)
+	if err != nil {
+		logging.Logger(context.Background()).Error("Error trying to marshal users struct",
+												   zap.String("msg", err.Error()))
+		return err
+	}
+
+	cc.store(org.GetMembersURL(), js)
+
+	// member views of org are built along with the index
+	if err := cc.IndexMembers(org, users); err != nil {
+		logging.Logger(context.Background()).Error("Error indexing members",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return err
+	}
+	return nil
+}
 
 // CacheOrgDetails caches data from org endpoint into redis
-func (cc *Cacher) CacheOrgDetails(url string) {
+func (cc *Cacher) CacheOrgDetails(org config.OrgConfig) {
+
+	cc.schedule(org.GetURL(), func() error {
+		return cc.RefreshOrgDetails(org)
+	})
+}
 
-	cc.schedule(func() {
-		orgInfo, err := cc.GitClient.GetOrgDetails(url)
-	    if err != nil {
-	    	logging.Logger(context.Background()).Fatal("Error getting the org info",
-											         zap.String("msg", err.Error()))
-	    }
+// RefreshOrgDetails fetches the org endpoint once and caches it
+func (cc *Cacher) RefreshOrgDetails(org config.OrgConfig) error {
 
-	    cc.DBClient.Set(url, orgInfo)
+	orgInfo, err := cc.fetchOrgDetails(org)
+	if err != nil {
+		logging.Logger(context.Background()).Error("Error getting the org info",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return err
+	}
+
+	cc.store(org.GetURL(), orgInfo)
+	return nil
+}
+
+// CacheEndpoint caches the configured endpoint of upstream into redis
+func (cc *Cacher) CacheEndpoint(url string) {
+
+	cc.schedule(url, func() error {
+		return cc.RefreshEndpoint(url)
 	})
 }
 
+// RefreshEndpoint fetches the endpoint through the provider once and caches it under url
+func (cc *Cacher) RefreshEndpoint(url string) error {
+
+	resp, err := cc.Provider.Fetch(context.Background(), url, "")
+	if err != nil {
+		logging.Logger(context.Background()).Error("Error fetching endpoint",
+												   zap.String("url", url),
+												   zap.String("msg", err.Error()))
+		return err
+	}
+
+	cc.store(url, resp)
+	return nil
+}
+
 // CacheRootEndpoint caches the info from root endpoint into redis
 func (cc *Cacher) CacheRootEndpoint(url string) {
-	
-	cc.schedule (func() {
-	    resp, err := cc.GitClient.GetRootInfo()
-	    if err != nil {
-	    	logging.Logger(context.Background()).Fatal("Error getting the root node",
-											         zap.String("msg", err.Error()))
-	    }
-	    cc.DBClient.Set(url, resp)
+
+	cc.schedule(url, func() error {
+		return cc.RefreshRootEndpoint(url)
 	})
 }
 
+// RefreshRootEndpoint fetches the root endpoint once and caches it under url
+func (cc *Cacher) RefreshRootEndpoint(url string) error {
+
+	resp, err := cc.fetchRootInfo()
+	if err != nil {
+		logging.Logger(context.Background()).Error("Error getting the root node",
+												   zap.String("msg", err.Error()))
+		return err
+	}
+
+	cc.store(url, resp)
+	return nil
+}
+
+// fetchRepos returns the repositories of org, kept to public ones when the shared cache is restricted to them
+func (cc *Cacher) fetchRepos(org config.OrgConfig) ([]*github.Repository, error) {
+
+	if op, ok := cc.Provider.(OrgProvider); ok {
+		return op.GetRepositories(org.Name)
+	}
+
+	url := org.GetReposURL()
+	if config.GetConfig().GetPassthroughConfig().PublicOnly() {
+		url += "?type=public"
+	}
+
+	var repos []*github.Repository
+	if err := cc.fetchJSON(url, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// fetchMembers returns the members of org, kept to public ones when the shared cache is restricted to them
+func (cc *Cacher) fetchMembers(org config.OrgConfig) ([]*github.User, error) {
+
+	if op, ok := cc.Provider.(OrgProvider); ok {
+		return op.GetMembers(org.Name)
+	}
+
+	url := org.GetMembersURL()
+	if config.GetConfig().GetPassthroughConfig().PublicOnly() {
+		url = org.GetURL() + "/public_members"
+	}
+
+	var users []*github.User
+	if err := cc.fetchJSON(url, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// fetchOrgDetails returns the org endpoint, without fields only members may see when the shared
+// cache is restricted to public data
+func (cc *Cacher) fetchOrgDetails(org config.OrgConfig) ([]byte, error) {
+
+	if op, ok := cc.Provider.(OrgProvider); ok {
+		return op.GetOrgDetails(org.GetURL())
+	}
+
+	body, err := cc.Provider.Fetch(context.Background(), org.GetURL(), "")
+	if err != nil || !config.GetConfig().GetPassthroughConfig().PublicOnly() {
+		return body, err
+	}
+	return filterFields(body, publicOrgFields)
+}
+
+// fetchRootInfo returns the root endpoint of upstream
+func (cc *Cacher) fetchRootInfo() ([]byte, error) {
+
+	if op, ok := cc.Provider.(OrgProvider); ok {
+		return op.GetRootInfo()
+	}
+	return cc.Provider.Fetch(context.Background(), "", "")
+}
+
+// fetchJSON fetches path through the provider and decodes its response into v
+func (cc *Cacher) fetchJSON(path string, v interface{}) error {
+
+	body, err := cc.Provider.Fetch(context.Background(), path, "")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// store caches payload fetched from upstream under key, rewriting upstream urls if configured
+func (cc *Cacher) store(key string, payload []byte) {
+	cc.DBClient.Set(key, StoreRewriter().RewriteBody(payload))
+	cc.notify(key)
+}
+
+// IsWarm reports whether key has been populated at least once, either by a refresh job or a snapshot import
+func (cc *Cacher) IsWarm(key string) bool {
+	meta, err := cc.DBClient.GetMeta(key)
+	return err == nil && meta != nil
+}
+
 // GetCachedEndpoint fetches the data from redis and serves response back to handler
 func (cc *Cacher) GetCachedEndpoint(path string) []byte {
 	return cc.DBClient.Get(path)
 }
 
+// GetCachedEncoded fetches the data from redis as stored, along with its encoding.
+// Repositories are served from their indexed hash
+func (cc *Cacher) GetCachedEncoded(path string) ([]byte, string) {
+
+	for _, org := range config.GetConfig().GetOrgs() {
+		if repo, resource, ok := org.ParseRepoURL(path); ok && resource == "" {
+			data, _ := cc.GetRepo(org.Name + "/" + repo)
+			return data, model.EncodingIdentity
+		}
+	}
+	return cc.DBClient.GetEncoded(path)
+}
+
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// negotiateEncoding picks the encoding body, held compressed with stored, is sent to client with.
+// Stored bytes are sent as is whenever client accepts their encoding, otherwise the encoding client
+// prefers among gzip and br is used for bodies large enough to be worth compressing
+func negotiateEncoding(r *http.Request, body []byte, stored string) string {
+
+	conf := config.GetConfig().GetCacheConfig().Compression
+	if !conf.Responses {
+		return model.EncodingIdentity
+	}
+
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	if stored != model.EncodingIdentity && accepted(stored) > 0 {
+		return stored
+	}
+
+	// compressed values are larger than min size anyway
+	if stored == model.EncodingIdentity && len(body) < conf.GetMinSize() {
+		return model.EncodingIdentity
+	}
+
+	// gzip is preferred on ties, being much cheaper to compress on the fly
+	best, bestQ := model.EncodingIdentity, 0.0
+	for _, encoding := range []string{config.EncodingGzip, config.EncodingBrotli} {
+		if q := accepted(encoding); q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// acceptedEncodings parses Accept-Encoding header into a function returning the quality client
+// assigned to an encoding, 0 if it is not acceptable
+func acceptedEncodings(header string) func(string) float64 {
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = value
+				}
+			}
+		}
+		qualities[name] = q
+	}
+
+	return func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		return qualities["*"]
+	}
+}
+
+// transcode converts body compressed with from into body compressed with to
+func transcode(body []byte, from string, to string) ([]byte, error) {
+
+	if from == to {
+		return body, nil
+	}
+
+	content, err := model.Decompress(body, from)
+	if err != nil || to == model.EncodingIdentity {
+		return content, err
+	}
+	return model.Compress(content, to)
+}
+
+func writeEncodingError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.Logger(r.Context()).Error("Error encoding cached response",
+									  zap.String("path", r.URL.Path),
+									  zap.String("msg", err.Error()))
+	w.WriteHeader(500)
+}
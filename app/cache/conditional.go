@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// jsonContentType is the content type of every response served from cache
+const jsonContentType = "application/json; charset=utf-8"
+
+// maxAge returns Cache-Control directive letting clients reuse a response for d
+func maxAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return "max-age=" + strconv.Itoa(int(d/time.Second))
+}
+
+// sharedMaxAge returns Cache-Control for data shared by all clients, which is kept out of shared
+// caches when clients must authenticate
+func sharedMaxAge(d time.Duration) string {
+	if config.GetConfig().GetAuthConfig().Enabled {
+		return "private, " + maxAge(d)
+	}
+	return maxAge(d)
+}
+
+// serveCached writes body held in cache, compressed with encoding, along with validators derived
+// from meta, answering conditional requests whose validators still match with 304. The etag is
+// computed from body when meta has none, and Last-Modified is left out when meta is nil.
+// Body is compressed as negotiated with the client
+func serveCached(w http.ResponseWriter, r *http.Request, body []byte, encoding string, meta *model.KeyMeta,
+	cacheControl string) {
+
+	etag := ""
+	if meta != nil {
+		etag = meta.ETag
+	}
+	if etag == "" {
+		content, err := model.Decompress(body, encoding)
+		if err != nil {
+			writeEncodingError(w, r, err)
+			return
+		}
+		etag = model.ContentHash(content)
+	}
+
+	served := negotiateEncoding(r, body, encoding)
+
+	// every encoding is a representation of its own
+	if served != model.EncodingIdentity {
+		etag += "-" + served
+	}
+	etag = `"` + etag + `"`
+
+	header := w.Header()
+	header.Set("Content-Type", jsonContentType)
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", cacheControl)
+	if config.GetConfig().GetCacheConfig().Compression.Responses {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	var lastModified time.Time
+	if meta != nil {
+		lastModified = meta.LastModified()
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if (r.Method == "GET" || r.Method == "HEAD") && notModified(r, etag, lastModified) {
+		header.Del("Content-Type")
+		w.WriteHeader(304)
+		return
+	}
+
+	data, err := transcode(body, encoding, served)
+	if err != nil {
+		writeEncodingError(w, r, err)
+		return
+	}
+
+	if served != model.EncodingIdentity {
+		header.Set("Content-Encoding", served)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+
+	w.WriteHeader(200)
+	if r.Method != "HEAD" {
+		w.Write(data)
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the former is absent, against
+// the validators of the current representation
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+
+			// weak comparison, W/ prefixed tags match their strong counterpart
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	// http dates have second precision
+	return !lastModified.Truncate(time.Second).After(since)
+}
@@ -3,6 +3,8 @@ package cache
 import (
 	"golang.org/x/oauth2"
 	"context"
+	"encoding/json"
+	"net/url"
 	"net/http"
 	"io/ioutil"
 	"github.com/aniketalshi/go_rest_cache/app/logging"
@@ -15,15 +17,20 @@ type GithubClient struct
 {
 	Stub *github.Client	
 	ctx	 context.Context
+
+	// client used for querying endpoints directly, bypassing github client library
+	httpClient *http.Client
 }
 
-// GetNewGithubClient will setup access tokens and setup a new github client 
-func GetNewGithubClient(ctx context.Context) *GithubClient {
+// GetNewGithubClient will setup access tokens and setup a new github client.
+// All calls to upstream wait for the shared budget and go through the breaker
+func GetNewGithubClient(ctx context.Context, breaker *CircuitBreaker, budget *UpstreamBudget) *GithubClient {
 
 	// GITHUB api token is required for overcoming ratelimit while querying the apis
 	apiToken := config.GetConfig().GetTargetToken()
 
 	var client *github.Client	
+	httpClient := &http.Client{Transport: budget.Transport(breaker.Transport(http.DefaultTransport))}
 
 	// check if token is set
 	if apiToken != "" {
@@ -31,31 +38,47 @@ func GetNewGithubClient(ctx context.Context) *GithubClient {
 			&oauth2.Token{AccessToken: apiToken},	
 		)
 
-		tc := oauth2.NewClient(ctx, ts)
+		// oauth2 client layers its transport on top of client found in context
+		tc := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, httpClient), ts)
 		client = github.NewClient(tc)
 	} else {
 
 		logging.Logger(ctx).Error("GITHUB API TOKEN is not set")
-		client = github.NewClient(nil)
+		client = github.NewClient(httpClient)
+	}
+
+	// point the client at configured upstream, which may be a github enterprise instance
+	if baseURL, err := url.Parse(config.GetConfig().GetTargetBaseURL() + "/"); err == nil {
+		client.BaseURL = baseURL
 	}
 
 	return &GithubClient{
 		Stub: client,
 		ctx: ctx,
+		httpClient: httpClient,
 	}
 }
 
 // GetRepositories calls the get repo for a given organization api on github and
 // paginates through all  the response pages adding them to result set
-func (gc *GithubClient) GetRepositories() ([]*github.Repository, error) {
+func (gc *GithubClient) GetRepositories(org string) ([]*github.Repository, error) {
+
+	if config.GetConfig().GetGraphQLConfig().Enabled {
+		return gc.getRepositoriesGraphQL(org)
+	}
 
     opt := &github.RepositoryListByOrgOptions{
     	ListOptions: github.ListOptions{PerPage: 10},
     }
+
+	// keep private repositories out of shared cache when clients bring their own tokens
+	if config.GetConfig().GetPassthroughConfig().PublicOnly() {
+		opt.Type = "public"
+	}
     // get all pages of results
     var allRepos []*github.Repository
     for {
-    	repos, resp, err := gc.Stub.Repositories.ListByOrg(gc.ctx, config.GetConfig().GetOrg(), opt)
+    	repos, resp, err := gc.Stub.Repositories.ListByOrg(gc.ctx, org, opt)
     	if err != nil {
     		return nil, err
     	}
@@ -71,15 +94,22 @@ func (gc *GithubClient) GetRepositories() ([]*github.Repository, error) {
 	return allRepos, nil
 }
 
-func (gc *GithubClient) GetMembers() ([]*github.User, error) {
+func (gc *GithubClient) GetMembers(org string) ([]*github.User, error) {
+
+	// graphql can't tell public members apart, so public only mode sticks to rest
+	passthrough := config.GetConfig().GetPassthroughConfig()
+	if config.GetConfig().GetGraphQLConfig().Enabled && !passthrough.PublicOnly() {
+		return gc.getMembersGraphQL(org)
+	}
 	
 	opt := &github.ListMembersOptions {
 		ListOptions: github.ListOptions{PerPage: 10},
+		PublicOnly: config.GetConfig().GetPassthroughConfig().PublicOnly(),
 	}
 
 	var allMembers []*github.User
 	for {
-		users, resp, err := gc.Stub.Organizations.ListMembers(gc.ctx, config.GetConfig().GetOrg(), opt)
+		users, resp, err := gc.Stub.Organizations.ListMembers(gc.ctx, org, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -101,7 +131,39 @@ func (gc *GithubClient) GetMembers() ([]*github.User, error) {
 // that is different than what we observe by curling endpoint directly - some fields are skipped.
 // That caused our unit-test to failed. So hitting endpoint directly.
 func (gc *GithubClient) GetOrgDetails(url string) ([]byte, error) {
-	return gc.queryUpstream(url)
+
+	body, err := gc.queryUpstream(url)
+	if err != nil || !config.GetConfig().GetPassthroughConfig().PublicOnly() {
+		return body, err
+	}
+	return filterFields(body, publicOrgFields)
+}
+
+// publicOrgFields are the fields of org endpoint visible to everyone, the rest such as plan and
+// private repo counts are only returned to members of the org
+var publicOrgFields = []string{
+	"login", "id", "node_id", "url", "repos_url", "events_url", "hooks_url", "issues_url",
+	"members_url", "public_members_url", "avatar_url", "description", "name", "company", "blog",
+	"location", "email", "twitter_username", "is_verified", "has_organization_projects",
+	"has_repository_projects", "public_repos", "public_gists", "followers", "following",
+	"html_url", "created_at", "updated_at", "type",
+}
+
+// filterFields drops every field of json object in body which is not listed in fields
+func filterFields(body []byte, fields []string) ([]byte, error) {
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil, err
+	}
+
+	filtered := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := object[field]; ok {
+			filtered[field] = value
+		}
+	}
+	return json.Marshal(filtered)
 }
 
 // GetRootInfo queries the root endpoint 
@@ -115,9 +177,7 @@ func (gc *GithubClient) GetRootInfo() ([]byte, error) {
 // bypassing github client library
 func (gc *GithubClient) queryUpstream (path string) ([]byte, error) {
 
-	token := config.GetConfig().GetTargetToken()
-	
-	url := config.GetConfig().GetTargetScheme() + "://" + config.GetConfig().GetTargetUrl()
+	url := config.GetConfig().GetTargetBaseURL()
 
 	if path != "" {
 		url = url + path
@@ -129,11 +189,10 @@ func (gc *GithubClient) queryUpstream (path string) ([]byte, error) {
 	}
 	
 	// API Token to overcome ratelimit	
-	req.Header.Set("Authorization", "token " + token)
-	client := &http.Client{}
+	authorizeUpstream(req)
 
 	// issue the request
-	resp, err := client.Do(req)
+	resp, err := gc.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -145,3 +204,14 @@ func (gc *GithubClient) queryUpstream (path string) ([]byte, error) {
 	body, err := ioutil.ReadAll(resp.Body)
 	return body, err
 }
+
+// Ping checks whether the upstream target is reachable and healthy, bypassing the breaker
+func (gc *GithubClient) Ping(ctx context.Context) error {
+	return pingUpstream(ctx)
+}
+
+// Fetch queries path following Link headers, merging pages of list endpoints into a single
+// json array like refresh jobs do
+func (gc *GithubClient) Fetch(ctx context.Context, path string, authorization string) ([]byte, error) {
+	return fetchPages(ctx, gc.httpClient, path, authorization, config.PaginationConfig{Strategy: config.PaginateLink})
+}
@@ -0,0 +1,332 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// graphqlPageSize is the largest page github's graphql api hands out
+const graphqlPageSize = 100
+
+// reposQuery fetches a page of repositories of an org with everything views and clients of the
+// rest endpoint rely on
+const reposQuery = `
+query($org: String!, $cursor: String, $privacy: RepositoryPrivacy, $size: Int!) {
+  organization(login: $org) {
+    repositories(first: $size, after: $cursor, privacy: $privacy, orderBy: {field: CREATED_AT, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        databaseId
+        id
+        name
+        nameWithOwner
+        description
+        homepageUrl
+        url
+        isPrivate
+        isFork
+        isArchived
+        isDisabled
+        createdAt
+        updatedAt
+        pushedAt
+        diskUsage
+        forkCount
+        owner { login avatarUrl }
+        defaultBranchRef { name }
+        primaryLanguage { name }
+        licenseInfo { key name spdxId }
+        repositoryTopics(first: 20) { nodes { topic { name } } }
+        stargazers { totalCount }
+        issues(states: OPEN) { totalCount }
+        pullRequests(states: OPEN) { totalCount }
+      }
+    }
+  }
+}`
+
+// membersQuery fetches a page of members of an org
+const membersQuery = `
+query($org: String!, $cursor: String, $size: Int!) {
+  organization(login: $org) {
+    membersWithRole(first: $size, after: $cursor) {
+      pageInfo { hasNextPage endCursor }
+      nodes { databaseId id login avatarUrl url isSiteAdmin }
+    }
+  }
+}`
+
+type graphqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphqlCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+type graphqlName struct {
+	Name string `json:"name"`
+}
+
+type graphqlRepo struct {
+	DatabaseID    int64        `json:"databaseId"`
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	NameWithOwner string       `json:"nameWithOwner"`
+	Description   *string      `json:"description"`
+	HomepageURL   *string      `json:"homepageUrl"`
+	URL           string       `json:"url"`
+	IsPrivate     bool         `json:"isPrivate"`
+	IsFork        bool         `json:"isFork"`
+	IsArchived    bool         `json:"isArchived"`
+	IsDisabled    bool         `json:"isDisabled"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+	PushedAt      *time.Time   `json:"pushedAt"`
+	DiskUsage     int          `json:"diskUsage"`
+	ForkCount     int          `json:"forkCount"`
+	Stargazers    graphqlCount `json:"stargazers"`
+	Issues        graphqlCount `json:"issues"`
+	PullRequests  graphqlCount `json:"pullRequests"`
+
+	Owner struct {
+		Login     string `json:"login"`
+		AvatarURL string `json:"avatarUrl"`
+	} `json:"owner"`
+
+	DefaultBranchRef *graphqlName `json:"defaultBranchRef"`
+	PrimaryLanguage  *graphqlName `json:"primaryLanguage"`
+
+	LicenseInfo *struct {
+		Key    string `json:"key"`
+		Name   string `json:"name"`
+		SpdxID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic graphqlName `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+}
+
+type graphqlMember struct {
+	DatabaseID  int64  `json:"databaseId"`
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	AvatarURL   string `json:"avatarUrl"`
+	URL         string `json:"url"`
+	IsSiteAdmin bool   `json:"isSiteAdmin"`
+}
+
+// getRepositoriesGraphQL fetches all repositories of org through graphql, normalized into the
+// shape of the rest endpoint
+func (gc *GithubClient) getRepositoriesGraphQL(org string) ([]*github.Repository, error) {
+
+	variables := map[string]interface{}{"org": org, "size": graphqlPageSize}
+
+	// keep private repositories out of shared cache when clients bring their own tokens
+	if config.GetConfig().GetPassthroughConfig().PublicOnly() {
+		variables["privacy"] = "PUBLIC"
+	}
+
+	var allRepos []*github.Repository
+	for {
+		var data struct {
+			Organization *struct {
+				Repositories struct {
+					PageInfo graphqlPageInfo `json:"pageInfo"`
+					Nodes    []graphqlRepo   `json:"nodes"`
+				} `json:"repositories"`
+			} `json:"organization"`
+		}
+
+		if err := gc.queryGraphQL(reposQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		if data.Organization == nil {
+			return nil, errors.New("organization " + org + " not found")
+		}
+
+		for _, node := range data.Organization.Repositories.Nodes {
+			allRepos = append(allRepos, node.normalize())
+		}
+
+		pageInfo := data.Organization.Repositories.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = pageInfo.EndCursor
+	}
+
+	return allRepos, nil
+}
+
+// getMembersGraphQL fetches all members of org through graphql, normalized into the shape of the rest endpoint
+func (gc *GithubClient) getMembersGraphQL(org string) ([]*github.User, error) {
+
+	variables := map[string]interface{}{"org": org, "size": graphqlPageSize}
+
+	var allMembers []*github.User
+	for {
+		var data struct {
+			Organization *struct {
+				MembersWithRole struct {
+					PageInfo graphqlPageInfo `json:"pageInfo"`
+					Nodes    []graphqlMember `json:"nodes"`
+				} `json:"membersWithRole"`
+			} `json:"organization"`
+		}
+
+		if err := gc.queryGraphQL(membersQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		if data.Organization == nil {
+			return nil, errors.New("organization " + org + " not found")
+		}
+
+		for _, node := range data.Organization.MembersWithRole.Nodes {
+			allMembers = append(allMembers, node.normalize())
+		}
+
+		pageInfo := data.Organization.MembersWithRole.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = pageInfo.EndCursor
+	}
+
+	return allMembers, nil
+}
+
+// normalize converts the graphql repository into the shape returned by the rest endpoint
+func (gr *graphqlRepo) normalize() *github.Repository {
+
+	apiURL := config.GetConfig().GetTargetBaseURL() + "/repos/" + gr.NameWithOwner
+
+	repo := &github.Repository{
+		ID:          github.Int64(gr.DatabaseID),
+		NodeID:      github.String(gr.ID),
+		Name:        github.String(gr.Name),
+		FullName:    github.String(gr.NameWithOwner),
+		Description: gr.Description,
+		Homepage:    gr.HomepageURL,
+		HTMLURL:     github.String(gr.URL),
+		URL:         github.String(apiURL),
+		Private:     github.Bool(gr.IsPrivate),
+		Fork:        github.Bool(gr.IsFork),
+		Archived:    github.Bool(gr.IsArchived),
+		Disabled:    github.Bool(gr.IsDisabled),
+		CreatedAt:   &github.Timestamp{Time: gr.CreatedAt},
+		UpdatedAt:   &github.Timestamp{Time: gr.UpdatedAt},
+		Size:        github.Int(gr.DiskUsage),
+		ForksCount:  github.Int(gr.ForkCount),
+
+		// rest reports stars as watchers too, and counts open pull requests as issues
+		StargazersCount: github.Int(gr.Stargazers.TotalCount),
+		WatchersCount:   github.Int(gr.Stargazers.TotalCount),
+		OpenIssuesCount: github.Int(gr.Issues.TotalCount + gr.PullRequests.TotalCount),
+
+		Owner: &github.User{
+			Login:     github.String(gr.Owner.Login),
+			AvatarURL: github.String(gr.Owner.AvatarURL),
+			Type:      github.String("Organization"),
+		},
+		Topics: []string{},
+	}
+
+	if gr.PushedAt != nil {
+		repo.PushedAt = &github.Timestamp{Time: *gr.PushedAt}
+	}
+	if gr.DefaultBranchRef != nil {
+		repo.DefaultBranch = github.String(gr.DefaultBranchRef.Name)
+	}
+	if gr.PrimaryLanguage != nil {
+		repo.Language = github.String(gr.PrimaryLanguage.Name)
+	}
+	if gr.LicenseInfo != nil {
+		repo.License = &github.License{
+			Key:    github.String(gr.LicenseInfo.Key),
+			Name:   github.String(gr.LicenseInfo.Name),
+			SPDXID: github.String(gr.LicenseInfo.SpdxID),
+		}
+	}
+	for _, node := range gr.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, node.Topic.Name)
+	}
+	return repo
+}
+
+// normalize converts the graphql member into the shape returned by the rest endpoint
+func (gm *graphqlMember) normalize() *github.User {
+	return &github.User{
+		Login:     github.String(gm.Login),
+		ID:        github.Int64(gm.DatabaseID),
+		NodeID:    github.String(gm.ID),
+		AvatarURL: github.String(gm.AvatarURL),
+		HTMLURL:   github.String(gm.URL),
+		URL:       github.String(config.GetConfig().GetTargetBaseURL() + "/users/" + gm.Login),
+		Type:      github.String("User"),
+		SiteAdmin: github.Bool(gm.IsSiteAdmin),
+	}
+}
+
+// queryGraphQL posts query to github's graphql api and decodes data of the response into out
+func (gc *GithubClient) queryGraphQL(query string, variables map[string]interface{}, out interface{}) error {
+
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", config.GetConfig().GetGraphQLURL(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorizeUpstream(req)
+
+	resp, err := gc.httpClient.Do(req.WithContext(gc.ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return &UpstreamStatusError{StatusCode: resp.StatusCode, Body: body}
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		var messages []string
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return errors.New("graphql: " + strings.Join(messages, "; "))
+	}
+	return json.Unmarshal(result.Data, out)
+}
@@ -0,0 +1,698 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/graphql"
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// maxGraphQLBody is the largest graphql request accepted
+const maxGraphQLBody = 1 << 20
+
+// graphqlRequest is the body of graphql requests
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// orgSource is an organization being resolved, its json is nil until the org endpoint is cached
+type orgSource struct {
+	conf config.OrgConfig
+	data map[string]interface{}
+}
+
+// rankedRepo is an entry of a view being resolved
+type rankedRepo struct {
+	result ViewResult
+}
+
+// graphql enums of views and repository listing, mapped to the names used by the rest of the cache
+var (
+	graphqlMetrics = map[string]string{
+		"STARS":        ViewByStars,
+		"FORKS":        ViewByForks,
+		"OPEN_ISSUES":  ViewByOpenIssues,
+		"LAST_UPDATED": ViewByLastUpdated,
+	}
+	graphqlRepoTypes  = []string{"ALL", "PUBLIC", "PRIVATE", "FORKS", "SOURCES"}
+	graphqlRepoSorts  = []string{"CREATED", "UPDATED", "PUSHED", "FULL_NAME"}
+	graphqlDirections = []string{"ASC", "DESC"}
+)
+
+// NewGraphQLSchema returns the schema of the graphql api, resolving organizations, their repositories,
+// members, views and aggregates entirely from data cached by cc
+func NewGraphQLSchema(cc *Cacher) *graphql.Schema {
+
+	var metrics []string
+	for metric := range graphqlMetrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	member := &graphql.Object{Name: "Member", Fields: jsonFieldDefs(
+		"login", "id", "avatarUrl", "htmlUrl", "type", "siteAdmin")}
+
+	repository := &graphql.Object{Name: "Repository", Fields: jsonFieldDefs(
+		"id", "name", "fullName", "description", "language", "htmlUrl", "homepage", "defaultBranch",
+		"stargazersCount", "forksCount", "openIssuesCount", "watchersCount", "size", "archived", "disabled",
+		"fork", "private", "topics", "createdAt", "updatedAt", "pushedAt")}
+	repository.Fields["owner"] = &graphql.FieldDef{Resolve: func(p graphql.Params) (interface{}, error) {
+		owner, _ := sourceJSON(p.Source)["owner"].(map[string]interface{})
+		return owner["login"], nil
+	}}
+
+	ranked := &graphql.Object{Name: "RankedRepository", Fields: map[string]*graphql.FieldDef{
+		"name": {Resolve: func(p graphql.Params) (interface{}, error) {
+			return p.Source.(*rankedRepo).result.Repo, nil
+		}},
+		"score": {Resolve: func(p graphql.Params) (interface{}, error) {
+			return p.Source.(*rankedRepo).result.Count, nil
+		}},
+		"repository": {Type: repository, Resolve: func(p graphql.Params) (interface{}, error) {
+			data, err := cc.GetRepo(p.Source.(*rankedRepo).result.Repo)
+			if err != nil || data == nil {
+				return nil, err
+			}
+			return decodeObject(data)
+		}},
+	}}
+
+	languageStat := &graphql.Object{Name: "LanguageStat", Fields: jsonFieldDefs("name", "repositories", "stars")}
+
+	stats := &graphql.Object{Name: "OrgStats", Fields: jsonFieldDefs(
+		"repositories", "members", "stars", "forks", "openIssues", "archived")}
+	stats.Fields["languages"] = &graphql.FieldDef{
+		Type: languageStat,
+		Args: map[string]*graphql.Arg{"limit": {Kind: graphql.KindInt}},
+		Resolve: func(p graphql.Params) (interface{}, error) {
+			languages, _ := sourceJSON(p.Source)["languages"].([]map[string]interface{})
+			return limitList(languages, 0, p.Int("limit")), nil
+		},
+	}
+
+	topArgs := func(org bool) map[string]*graphql.Arg {
+		args := map[string]*graphql.Arg{
+			"metric": {Kind: graphql.KindEnum, Values: metrics, Required: true},
+			"limit":  {Kind: graphql.KindInt, Default: 10},
+		}
+		if org {
+			args["org"] = &graphql.Arg{Kind: graphql.KindString}
+		}
+		return args
+	}
+
+	organization := &graphql.Object{Name: "Organization", Fields: jsonFieldDefs(
+		"id", "name", "description", "company", "blog", "location", "email", "htmlUrl", "avatarUrl",
+		"publicRepos", "followers", "following", "createdAt", "updatedAt")}
+
+	organization.Fields["login"] = &graphql.FieldDef{Resolve: func(p graphql.Params) (interface{}, error) {
+		return p.Source.(*orgSource).conf.Name, nil
+	}}
+
+	organization.Fields["repositories"] = &graphql.FieldDef{
+		Type: repository,
+		Args: map[string]*graphql.Arg{
+			"type":      {Kind: graphql.KindEnum, Values: graphqlRepoTypes, Default: "ALL"},
+			"sort":      {Kind: graphql.KindEnum, Values: graphqlRepoSorts},
+			"direction": {Kind: graphql.KindEnum, Values: graphqlDirections},
+			"language":  {Kind: graphql.KindString},
+			"topic":     {Kind: graphql.KindString},
+			"archived":  {Kind: graphql.KindBoolean},
+			"limit":     {Kind: graphql.KindInt},
+			"offset":    {Kind: graphql.KindInt},
+		},
+		Resolve: func(p graphql.Params) (interface{}, error) {
+
+			// type, sort and direction are those of the org repos endpoint
+			query := url.Values{}
+			for _, param := range repoListParams {
+				if value := p.String(param); value != "" {
+					query.Set(param, strings.ToLower(value))
+				}
+			}
+
+			org := p.Source.(*orgSource).conf
+			items, err := cc.cachedList(p.Context, org.GetReposURL())
+			if err != nil {
+				return nil, err
+			}
+			if items == nil {
+				return nil, errors.New("repositories of " + org.Name + " are not cached yet")
+			}
+
+			fields, err := cc.cachedRepoFields(p.Context, org.GetReposURL())
+			if err != nil {
+				return nil, err
+			}
+			order, err := orderRepos(fields, query)
+			if err != nil {
+				return nil, err
+			}
+
+			archived, filterArchived := p.Bool("archived")
+			var repos []map[string]interface{}
+			for _, index := range order {
+
+				repo := items[index]
+
+				language, _ := repo["language"].(string)
+				if p.String("language") != "" && !strings.EqualFold(language, p.String("language")) {
+					continue
+				}
+				if filterArchived && repo["archived"] != archived {
+					continue
+				}
+				if topic := p.String("topic"); topic != "" && !hasTopic(repo, topic) {
+					continue
+				}
+				repos = append(repos, repo)
+			}
+			return limitList(repos, p.Int("offset"), p.Int("limit")), nil
+		},
+	}
+
+	organization.Fields["members"] = &graphql.FieldDef{
+		Type: member,
+		Args: map[string]*graphql.Arg{
+			"limit":  {Kind: graphql.KindInt},
+			"offset": {Kind: graphql.KindInt},
+		},
+		Resolve: func(p graphql.Params) (interface{}, error) {
+
+			org := p.Source.(*orgSource).conf
+			members, err := cc.cachedList(p.Context, org.GetMembersURL())
+			if err != nil {
+				return nil, err
+			}
+			if members == nil {
+				return nil, errors.New("members of " + org.Name + " are not cached yet")
+			}
+			return limitList(members, p.Int("offset"), p.Int("limit")), nil
+		},
+	}
+
+	organization.Fields["top"] = &graphql.FieldDef{
+		Type: ranked,
+		Args: topArgs(false),
+		Resolve: func(p graphql.Params) (interface{}, error) {
+			return cc.resolveView(p, p.Source.(*orgSource).conf.Name)
+		},
+	}
+
+	organization.Fields["stats"] = &graphql.FieldDef{
+		Type: stats,
+		Resolve: func(p graphql.Params) (interface{}, error) {
+			return cc.orgStats(p.Context, p.Source.(*orgSource).conf)
+		},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.FieldDef{
+
+		"organization": {
+			Type: organization,
+			Args: map[string]*graphql.Arg{"login": {Kind: graphql.KindString, Required: true}},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				org, ok := config.GetConfig().FindOrg(p.String("login"))
+				if !ok {
+					return nil, nil
+				}
+				return cc.resolveOrg(p.Context, org)
+			},
+		},
+
+		"organizations": {
+			Type: organization,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				var orgs []*orgSource
+				for _, org := range config.GetConfig().GetOrgs() {
+					source, err := cc.resolveOrg(p.Context, org)
+					if err != nil {
+						return nil, err
+					}
+					orgs = append(orgs, source)
+				}
+				return orgs, nil
+			},
+		},
+
+		"repository": {
+			Type: repository,
+			Args: map[string]*graphql.Arg{
+				"owner": {Kind: graphql.KindString, Required: true},
+				"name":  {Kind: graphql.KindString, Required: true},
+			},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				data, err := cc.GetRepo(p.String("owner") + "/" + p.String("name"))
+				if err != nil || data == nil {
+					return nil, err
+				}
+				return decodeObject(data)
+			},
+		},
+
+		"top": {
+			Type: ranked,
+			Args: topArgs(true),
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				org := p.String("org")
+				if _, ok := config.GetConfig().FindOrg(org); org != "" && !ok {
+					return nil, errors.New("org " + org + " is not cached")
+				}
+				return cc.resolveView(p, org)
+			},
+		},
+
+		"searchRepositories": {
+			Type: repository,
+			Args: map[string]*graphql.Arg{
+				"query": {Kind: graphql.KindString, Required: true},
+				"sort":  {Kind: graphql.KindString},
+				"order": {Kind: graphql.KindString},
+				"limit": {Kind: graphql.KindInt, Default: defaultPerPage},
+			},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				items, err := cc.Search.SearchRepos(p.String("query"), p.String("sort"), p.String("order"))
+				if err != nil {
+					return nil, err
+				}
+				return decodeObjects(limitList(items, 0, p.Int("limit")))
+			},
+		},
+
+		"searchMembers": {
+			Type: member,
+			Args: map[string]*graphql.Arg{
+				"query": {Kind: graphql.KindString, Required: true},
+				"limit": {Kind: graphql.KindInt, Default: defaultPerPage},
+			},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				items, err := cc.Search.SearchMembers(p.String("query"), "", "")
+				if err != nil {
+					return nil, err
+				}
+				return decodeObjects(limitList(items, 0, p.Int("limit")))
+			},
+		},
+	}}
+
+	return &graphql.Schema{Query: query}
+}
+
+// jsonFieldDefs returns scalar fields resolving to the keys of json objects they are named after, in snake case
+func jsonFieldDefs(names ...string) map[string]*graphql.FieldDef {
+
+	fields := make(map[string]*graphql.FieldDef, len(names))
+	for _, name := range names {
+		key := snakeCase(name)
+		fields[name] = &graphql.FieldDef{Resolve: func(p graphql.Params) (interface{}, error) {
+			return sourceJSON(p.Source)[key], nil
+		}}
+	}
+	return fields
+}
+
+// sourceJSON returns the decoded json of the object being resolved
+func sourceJSON(source interface{}) map[string]interface{} {
+	switch source := source.(type) {
+	case map[string]interface{}:
+		return source
+	case *orgSource:
+		return source.data
+	}
+	return nil
+}
+
+// snakeCase turns camel case field names into the snake case keys of github json
+func snakeCase(name string) string {
+
+	var b strings.Builder
+	for _, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func hasTopic(repo map[string]interface{}, topic string) bool {
+	topics, _ := repo["topics"].([]interface{})
+	for _, t := range topics {
+		if s, ok := t.(string); ok && strings.EqualFold(s, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitList returns at most limit elements of list starting at offset, all of them if limit is not positive
+func limitList(list interface{}, offset int, limit int) interface{} {
+
+	switch list := list.(type) {
+	case []map[string]interface{}:
+		start, end := listBounds(len(list), offset, limit)
+		return list[start:end]
+	case []json.RawMessage:
+		start, end := listBounds(len(list), offset, limit)
+		return list[start:end]
+	}
+	return list
+}
+
+func listBounds(total int, offset int, limit int) (int, int) {
+	if offset < 0 || offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	var object map[string]interface{}
+	err := json.Unmarshal(data, &object)
+	return object, err
+}
+
+func decodeObjects(items interface{}) ([]map[string]interface{}, error) {
+
+	raw, _ := items.([]json.RawMessage)
+	objects := make([]map[string]interface{}, len(raw))
+	for i, item := range raw {
+		object, err := decodeObject(item)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = object
+	}
+	return objects, nil
+}
+
+type graphqlCtxKeyType int
+
+const graphqlMemoCtxKey graphqlCtxKeyType = iota
+
+// graphqlMemo holds cached data decoded while executing a single graphql request, so that fields
+// selected many times, such as under aliases, decode each cached blob once
+type graphqlMemo struct {
+	blobs   map[string][]byte
+	objects map[string]map[string]interface{}
+	lists   map[string][]map[string]interface{}
+	repos   map[string][]repoFields
+}
+
+func withGraphQLMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, graphqlMemoCtxKey, &graphqlMemo{
+		blobs:   make(map[string][]byte),
+		objects: make(map[string]map[string]interface{}),
+		lists:   make(map[string][]map[string]interface{}),
+		repos:   make(map[string][]repoFields),
+	})
+}
+
+// graphqlMemoFrom returns the memo of the request executing in ctx, a throwaway one outside of requests
+func graphqlMemoFrom(ctx context.Context) *graphqlMemo {
+	if memo, ok := ctx.Value(graphqlMemoCtxKey).(*graphqlMemo); ok {
+		return memo
+	}
+	return graphqlMemoFrom(withGraphQLMemo(context.Background()))
+}
+
+// cachedBlob returns what is cached under url, read once per request so that every field sees the same data
+func (cc *Cacher) cachedBlob(ctx context.Context, url string) []byte {
+
+	memo := graphqlMemoFrom(ctx)
+	data, ok := memo.blobs[url]
+	if !ok {
+		data = cc.GetCachedEndpoint(url)
+		memo.blobs[url] = data
+	}
+	return data
+}
+
+// cachedObject returns the decoded object cached under url, nil if it is not cached yet
+func (cc *Cacher) cachedObject(ctx context.Context, url string) (map[string]interface{}, error) {
+
+	memo := graphqlMemoFrom(ctx)
+	if object, ok := memo.objects[url]; ok {
+		return object, nil
+	}
+
+	data := cc.cachedBlob(ctx, url)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	object, err := decodeObject(data)
+	if err != nil {
+		return nil, err
+	}
+	memo.objects[url] = object
+	return object, nil
+}
+
+// cachedList returns the decoded list cached under url, nil if it is not cached yet
+func (cc *Cacher) cachedList(ctx context.Context, url string) ([]map[string]interface{}, error) {
+
+	memo := graphqlMemoFrom(ctx)
+	if list, ok := memo.lists[url]; ok {
+		return list, nil
+	}
+
+	data := cc.cachedBlob(ctx, url)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var list []map[string]interface{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []map[string]interface{}{}
+	}
+	memo.lists[url] = list
+	return list, nil
+}
+
+// cachedRepoFields returns the fields the org repos endpoint filters and sorts by of every repository
+// in the list cached under url, in the order of cachedList
+func (cc *Cacher) cachedRepoFields(ctx context.Context, url string) ([]repoFields, error) {
+
+	memo := graphqlMemoFrom(ctx)
+	if fields, ok := memo.repos[url]; ok {
+		return fields, nil
+	}
+
+	var fields []repoFields
+	if err := json.Unmarshal(cc.cachedBlob(ctx, url), &fields); err != nil {
+		return nil, err
+	}
+	memo.repos[url] = fields
+	return fields, nil
+}
+
+// resolveOrg returns org along with its cached json
+func (cc *Cacher) resolveOrg(ctx context.Context, org config.OrgConfig) (*orgSource, error) {
+
+	object, err := cc.cachedObject(ctx, org.GetURL())
+	if err != nil {
+		return nil, err
+	}
+	return &orgSource{conf: org, data: object}, nil
+}
+
+// resolveView returns the top entries of the view asked for in p, of org or aggregated across orgs
+func (cc *Cacher) resolveView(p graphql.Params, org string) ([]*rankedRepo, error) {
+
+	if config.GetConfig().GetAuthConfig().Enabled && !HasScope(APIKeyFromContext(p.Context), ScopeViews) {
+		return nil, errors.New("api key lacks scope " + ScopeViews)
+	}
+
+	view := graphqlMetrics[p.String("metric")]
+	if !cc.IsWarm(ViewKey(view, org)) {
+		return nil, errors.New("view is not built yet")
+	}
+
+	limit := p.Int("limit")
+	if limit < 1 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	results, err := cc.GetView(p.Context, view, org, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]*rankedRepo, len(results))
+	for i, result := range results {
+		ranked[i] = &rankedRepo{result: result}
+	}
+	return ranked, nil
+}
+
+// orgStats aggregates the cached repositories and members of org
+func (cc *Cacher) orgStats(ctx context.Context, org config.OrgConfig) (map[string]interface{}, error) {
+
+	repos, err := cc.cachedList(ctx, org.GetReposURL())
+	if err != nil {
+		return nil, err
+	}
+	if repos == nil {
+		return nil, errors.New("repositories of " + org.Name + " are not cached yet")
+	}
+
+	members, err := cc.cachedList(ctx, org.GetMembersURL())
+	if err != nil {
+		return nil, err
+	}
+
+	count := func(repo map[string]interface{}, key string) float64 {
+		n, _ := repo[key].(float64)
+		return n
+	}
+
+	var stars, forks, openIssues, archived float64
+	languages := make(map[string]map[string]interface{})
+	for _, repo := range repos {
+
+		stars += count(repo, "stargazers_count")
+		forks += count(repo, "forks_count")
+		openIssues += count(repo, "open_issues_count")
+		if repo["archived"] == true {
+			archived++
+		}
+
+		name, _ := repo["language"].(string)
+		if name == "" {
+			continue
+		}
+		if languages[name] == nil {
+			languages[name] = map[string]interface{}{"name": name, "repositories": 0.0, "stars": 0.0}
+		}
+		languages[name]["repositories"] = languages[name]["repositories"].(float64) + 1
+		languages[name]["stars"] = languages[name]["stars"].(float64) + count(repo, "stargazers_count")
+	}
+
+	// languages used by most repositories come first
+	byUse := make([]map[string]interface{}, 0, len(languages))
+	for _, language := range languages {
+		byUse = append(byUse, language)
+	}
+	sort.Slice(byUse, func(i, j int) bool {
+		a, b := byUse[i]["repositories"].(float64), byUse[j]["repositories"].(float64)
+		if a != b {
+			return a > b
+		}
+		return byUse[i]["name"].(string) < byUse[j]["name"].(string)
+	})
+
+	stats := map[string]interface{}{
+		"repositories": len(repos),
+		"stars":        stars,
+		"forks":        forks,
+		"open_issues":  openIssues,
+		"archived":     archived,
+		"languages":    byUse,
+	}
+	if members != nil {
+		stats["members"] = len(members)
+	}
+	return stats, nil
+}
+
+// isGraphQLPath reports whether path is the graphql api, which is only served over github data
+func isGraphQLPath(path string) bool {
+	return path == "/graphql" && config.GetConfig().GetProvider() == config.ProviderGitHub
+}
+
+// HandleGraphQL executes graphql queries sent as json in POST bodies or in query parameters of GET
+// requests, resolving them entirely from cached data
+func (hh *Handlers) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+
+	var req graphqlRequest
+
+	switch r.Method {
+	case "GET", "HEAD":
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeGraphQLError(w, 400, "variables must be a json object")
+				return
+			}
+		}
+
+	case "POST":
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBody))
+		if err != nil {
+			writeGraphQLError(w, 413, "request body is too large")
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			writeGraphQLError(w, 400, "request body must be a json object")
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQLError(w, 405, "graphql api accepts GET and POST requests")
+		return
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQLError(w, 400, "query is required")
+		return
+	}
+
+	// cached data is decoded once for the whole query
+	resp := graphql.Execute(withGraphQLMemo(r.Context()), hh.schema, req.Query, req.OperationName, req.Variables)
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		writeGraphQLError(w, 500, err.Error())
+		return
+	}
+
+	// queries which could not be executed at all are the client's fault
+	if resp.Data == nil {
+		logging.Logger(r.Context()).Info("Invalid graphql query",
+										 zap.String("msg", resp.Errors[0].Message))
+
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(400)
+		w.Write(data)
+		return
+	}
+
+	serveCached(w, r, data, model.EncodingIdentity, nil, sharedMaxAge(0))
+}
+
+// writeGraphQLError writes a response carrying a single error the way graphql responses do
+func writeGraphQLError(w http.ResponseWriter, status int, msg string) {
+
+	data, _ := json.Marshal(&graphql.Response{Errors: []*graphql.Error{{Message: msg}}})
+
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
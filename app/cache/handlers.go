@@ -2,6 +2,9 @@ package cache
 
 import (
 	"fmt"
+	"context"
+	"time"
+	"expvar"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,7 +13,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/gorilla/mux"
+	"github.com/aniketalshi/go_rest_cache/app/graphql"
 	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
 	"github.com/aniketalshi/go_rest_cache/config"
 )
 
@@ -19,69 +24,221 @@ type Handlers struct
 {
 	stub *httputil.ReverseProxy
 	cacher *Cacher
+	auth *Authenticator
+	policy *ProxyPolicy
+	schema *graphql.Schema
 }
 
-// HandleCachedAPI handles the api responses for path which are pre-cached in redis
+// isReadRequest reports whether r only reads, the only requests answered from cache
+func isReadRequest(r *http.Request) bool {
+	return r.Method == "GET" || r.Method == "HEAD"
+}
+
+// HandleCachedAPI handles the api responses for path which are pre-cached in redis, proxying
+// requests which don't match any cached route. Writes to cached routes are always proxied
 func (hh *Handlers) HandleCachedAPI(w http.ResponseWriter, r *http.Request) {
 
-	for _, url := range config.GetConfig().GetCachedURLs() {
-		if r.URL.Path == url {
+	if route, url, ok := CachedRoutes().Match(r.URL); ok && isReadRequest(r) {
+
+		if isPartitioned(r) {
+			hh.HandlePartitioned(w, r, route, url, passthroughAuthorization(r))
+			return
+		}
+
+		meta, err := hh.cacher.DBClient.GetMeta(url)
+		if route.ReadThrough && err == nil && (meta == nil || meta.Age() > config.GetConfig().GetRefreshInterval(url)) {
+			if meta = hh.readThrough(w, r, url, meta); meta == nil {
+				return
+			}
+		}
+		if err != nil || meta == nil {
+			// endpoints of repositories missing once their org is warm are not cached at all
+			if hh.cacher.IsRepoURLWarm(url) {
+				hh.forward(w, r)
+				return
+			}
+			hh.HandleCold(w, r)
+			return
+		}
 
-			logging.Logger(r.Context()).Info("Path is cached, serving the response from redis.", 
-											 zap.String("path", r.URL.Path))
+		logging.Logger(r.Context()).Info("Path is cached, serving the response from redis.", 
+										 zap.String("path", r.URL.Path),
+										 zap.String("key", url))
 
-			response := hh.cacher.GetCachedEndpoint(url)
+		response, encoding := hh.cacher.GetCachedEncoded(url)
+		if response == nil {
+			// book-keeping outlived the data, such as of a repository removed in between
+			logging.Logger(r.Context()).Warn("Cached response is missing, serving the response from upstream",
+											 zap.String("key", url))
+			hh.forward(w, r)
+			return
+		}
 
-			w.WriteHeader(200)
-			w.Write(response)
+		// clients may reuse the response until the next refresh is due
+		refresh := config.GetConfig().GetRefreshInterval(url)
+		cacheControl := sharedMaxAge(refresh - meta.Age())
 
+		// pages of lists are sliced out of the cached list, anything it can't answer goes upstream
+		if isListQuery(r, route) {
+			if !serveList(w, r, route, response, encoding, meta, cacheControl) {
+				hh.forward(w, r)
+			}
 			return
 		}
+
+		serveCached(w, r, response, encoding, meta, cacheControl)
+		return
 	}
 	
     logging.Logger(r.Context()).Info("The requested path is not supposed to be cached",
     								 zap.String("path", r.URL.Path))
-    hh.stub.ServeHTTP(w, r)	
+    hh.forward(w, r)	
+}
+
+// HandleCold serves a cached route whose key has not been populated yet, either by proxying
+// it to upstream or by asking client to retry once the first refresh is expected to complete
+func (hh *Handlers) HandleCold(w http.ResponseWriter, r *http.Request) {
+
+	if config.GetConfig().GetCacheConfig().GetColdStart() == config.ColdStartProxy {
+		logging.Logger(r.Context()).Info("Path is not warm yet, serving the response from upstream",
+										 zap.String("path", r.URL.Path))
+		hh.forward(w, r)
+		return
+	}
+
+	hh.Unavailable(w, r)
+}
+
+// Unavailable responds with 503 asking client to retry after next refresh
+func (hh *Handlers) Unavailable(w http.ResponseWriter, r *http.Request) {
+
+	logging.Logger(r.Context()).Info("Path is not warm yet, responding unavailable",
+									 zap.String("path", r.URL.Path))
+
+	retryAfter := config.GetConfig().GetCacheConfig().RefreshInterval
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(503)
+	w.Write([]byte("cache is warming up, retry later"))
+}
+
+// HandleUpstreamError is invoked by the proxy when upstream could not be reached. While the breaker is open
+// reads of paths we hold data for are served stale from redis and the rest are failed fast
+func (hh *Handlers) HandleUpstreamError(w http.ResponseWriter, r *http.Request, err error) {
+
+	if err != ErrCircuitOpen {
+		logging.Logger(r.Context()).Error("Error proxying request to upstream",
+										  zap.String("path", r.URL.Path),
+										  zap.String("msg", err.Error()))
+		w.WriteHeader(502)
+		return
+	}
+
+	// responses cached for other queries of the path would be wrong, writes must learn they failed and
+	// clients with their own token in partition mode may not see data cached with ours
+	_, key, cached := CachedRoutes().Match(r.URL)
+	cached = cached && isReadRequest(r) && !isPartitioned(r)
+
+	if response, encoding := hh.cacher.GetCachedEncoded(key); cached && response != nil {
+		logging.Logger(r.Context()).Warn("Upstream breaker is open, serving stale response from redis",
+										 zap.String("path", r.URL.Path))
+
+		meta, _ := hh.cacher.DBClient.GetMeta(key)
+
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+		serveCached(w, r, response, encoding, meta, maxAge(0))
+		return
+	}
+
+	logging.Logger(r.Context()).Warn("Upstream breaker is open, failing fast",
+									 zap.String("path", r.URL.Path))
+
+	retryAfter := hh.cacher.Breaker.RetryAfter() / time.Second + 1
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+	w.WriteHeader(503)
+	w.Write([]byte(err.Error()))
 }
 
-// HandleDefaults is the default http handler
+// HandleDefaults is the default http handler, serving cached routes from cache and proxying the rest
 func (hh *Handlers) HandleDefaults (w http.ResponseWriter, r *http.Request) {
-	hh.stub.ServeHTTP(w, r)	
+	hh.HandleCachedAPI(w, r)
 }
 
 func (hh *Handlers) GetTopForkedRepos (w http.ResponseWriter, r *http.Request) {
-	hh.HandleViews(w, r, "top-repo-by-forks")
+	hh.HandleViews(w, r, ViewByForks)
 }
 
 func (hh *Handlers) GetLastUpdatedRepos (w http.ResponseWriter, r *http.Request) {
-	hh.HandleViews(w, r, "top-repo-by-lastupdated")
+	hh.HandleViews(w, r, ViewByLastUpdated)
 }
 
 func (hh *Handlers) GetTopOpenIssuesRepos (w http.ResponseWriter, r *http.Request) {
-	hh.HandleViews(w, r, "top-repo-by-openissues")
+	hh.HandleViews(w, r, ViewByOpenIssues)
 }
 
 func (hh *Handlers) GetTopStarredRepos (w http.ResponseWriter, r *http.Request) {
-	hh.HandleViews(w, r, "top-repo-by-stars")
+	hh.HandleViews(w, r, ViewByStars)
+}
+
+func (hh *Handlers) GetTopMembersByPublicRepos (w http.ResponseWriter, r *http.Request) {
+	hh.HandleViews(w, r, ViewMembersByPublicRepos)
+}
+
+func (hh *Handlers) GetTopMembersByFollowers (w http.ResponseWriter, r *http.Request) {
+	hh.HandleViews(w, r, ViewMembersByFollowers)
+}
+
+func (hh *Handlers) GetOldestMembers (w http.ResponseWriter, r *http.Request) {
+	hh.HandleViews(w, r, ViewMembersByAge)
+}
+
+func (hh *Handlers) GetMemberAdmins (w http.ResponseWriter, r *http.Request) {
+	hh.HandleViews(w, r, ViewMemberAdmins)
+}
+
+func (hh *Handlers) GetMemberTimeline (w http.ResponseWriter, r *http.Request) {
+	hh.HandleViews(w, r, ViewMemberTimeline)
 }
 
 
-func (hh *Handlers) HandleViews(w http.ResponseWriter, r *http.Request, key string) {
+// HandleViews serves view of the org named in path, or view aggregated across orgs if none is named
+func (hh *Handlers) HandleViews(w http.ResponseWriter, r *http.Request, view string) {
 
 	vars := mux.Vars(r)
 
+	org := vars["org"]
+	orgConf, ok := config.GetConfig().FindOrg(org)
+	if org != "" && !ok {
+		w.WriteHeader(404)
+		w.Write([]byte("org " + org + " is not cached"))
+		return
+	}
+
+	// members of an org are only viewed if cached, and ranked by their profiles only if fetched
+	if org != "" && isMemberView(view) && !containsString(orgMemberViews(orgConf), view) {
+		w.WriteHeader(404)
+		w.Write([]byte("view " + view + " is not built for org " + org))
+		return
+	}
+
 	limit, err := strconv.Atoi(vars["id"])
 	if err != nil || limit < 1 {
 
-		logging.Logger(r.Context()).Error("Wrong count specified", zap.String("msg", err.Error()))
+		logging.Logger(r.Context()).Error("Wrong count specified", zap.String("count", vars["id"]))
 
 		w.WriteHeader(400)
 		w.Write([]byte("count incorrect in request"))
 		return
 	}
 	
+	// views can't be served from upstream, so ask client to come back once they are built
+	meta, err := hh.cacher.DBClient.GetMeta(ViewKey(view, org))
+	if err != nil || meta == nil {
+		hh.Unavailable(w, r)
+		return
+	}
+
 	// fetch the vewi from redis
-	response, err := hh.cacher.GetView(r.Context(), key, limit)
+	response, err := hh.cacher.GetView(r.Context(), view, org, limit)
 
 	if err != nil {
 		w.WriteHeader(400)		
@@ -104,8 +261,19 @@ func (hh *Handlers) HandleViews(w http.ResponseWriter, r *http.Request, key stri
 	logging.Logger(r.Context()).Info("custom view response", 
 									  zap.Int("len", len(response)))
 
-	w.WriteHeader(200)
-	w.Write([]byte(craftedResp.String()))
+	// views are rebuilt whenever repositories or members are refreshed, aggregated views as often as cache.refresh
+	source := orgConf.GetReposURL()
+	if isMemberView(view) {
+		source = orgConf.GetMembersURL()
+	}
+	refresh := config.GetConfig().GetRefreshInterval(source)
+	if org == "" {
+		refresh = config.GetConfig().GetRefreshInterval("")
+	}
+
+	// etag is derived from the crafted response since it depends on the count requested
+	served := &model.KeyMeta{UpdatedAt: meta.UpdatedAt, ModifiedAt: meta.ModifiedAt}
+	serveCached(w, r, []byte(craftedResp.String()), model.EncodingIdentity, served, sharedMaxAge(refresh - meta.Age()))
 }
 
 // a health check endpoint to let others know service is up and running
@@ -113,30 +281,84 @@ func (hh *Handlers) Healthcheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)	
 }
 
+// viewPrefixes returns the paths views are served under, views are only built over github repositories
+func viewPrefixes() []string {
+	if !BuildsViews() {
+		return nil
+	}
+	return []string{"/top", "/{org}/top"}
+}
+
 // Setuphandlers sets up the mux router with appropriate paths and handlers
 func SetupHandlers(cacher *Cacher) http.Handler{
 	r := mux.NewRouter()
 
+	policy, err := NewProxyPolicy()
+	if err != nil {
+		logging.Logger(context.Background()).Fatal("Error compiling proxy policy",
+												   zap.String("msg", err.Error()))
+	}
+
 	proxy := &Handlers{
-		stub: GenerateProxy(),
+		stub: GenerateProxy(cacher.Breaker),
 		cacher: cacher,	
+		auth: NewAuthenticator(cacher.DBClient),
+		policy: policy,
+		schema: NewGraphQLSchema(cacher),
 	}
+	proxy.stub.ErrorHandler = proxy.HandleUpstreamError
 
 	r.HandleFunc("/healthcheck", proxy.Healthcheck)
+	r.HandleFunc("/healthz", proxy.Liveness)
+	r.HandleFunc("/readyz", proxy.Readiness)
+	r.Handle("/metrics", expvar.Handler())
 
-	for _, url := range config.GetConfig().GetCachedURLs() {
-		r.HandleFunc(url, proxy.HandleCachedAPI)
-	}
 	
-	// handlers for views we have constructed over repository data
+	// handlers for views we have constructed over repository data, aggregated across orgs
+	// under /view/top and of a single org under /view/{org}/top. Views over members are
+	// under /view/members, matched first so that they don't get taken for an org named members
 	viewr := r.PathPrefix("/view").Subrouter()
-	viewr.HandleFunc("/top/{id}/forks", proxy.GetTopForkedRepos)
-	viewr.HandleFunc("/top/{id}/last_updated", proxy.GetLastUpdatedRepos)
-	viewr.HandleFunc("/top/{id}/open_issues", proxy.GetTopOpenIssuesRepos)
-	viewr.HandleFunc("/top/{id}/stars", proxy.GetTopStarredRepos)
+	viewr.Use(SharedDataOnly)
+	for _, prefix := range viewPrefixes() {
+		viewr.HandleFunc("/members" + prefix + "/{id}/public_repos", proxy.GetTopMembersByPublicRepos)
+		viewr.HandleFunc("/members" + prefix + "/{id}/followers", proxy.GetTopMembersByFollowers)
+		viewr.HandleFunc("/members" + prefix + "/{id}/account_age", proxy.GetOldestMembers)
+		viewr.HandleFunc("/members" + prefix + "/{id}/admins", proxy.GetMemberAdmins)
+		viewr.HandleFunc("/members" + prefix + "/{id}/timeline", proxy.GetMemberTimeline)
+	}
+	for _, prefix := range viewPrefixes() {
+		viewr.HandleFunc(prefix + "/{id}/forks", proxy.GetTopForkedRepos)
+		viewr.HandleFunc(prefix + "/{id}/last_updated", proxy.GetLastUpdatedRepos)
+		viewr.HandleFunc(prefix + "/{id}/open_issues", proxy.GetTopOpenIssuesRepos)
+		viewr.HandleFunc(prefix + "/{id}/stars", proxy.GetTopStarredRepos)
+	}
+
+	// search and graphql api are served from data cached of github repositories and members
+	if config.GetConfig().GetProvider() == config.ProviderGitHub {
+		r.Handle("/search/{kind:repos|members}", SharedDataOnly(http.HandlerFunc(proxy.HandleSearch)))
+		r.Handle("/graphql", SharedDataOnly(http.HandlerFunc(proxy.HandleGraphQL)))
+	}
 
-	// fallback to default handler for all the rest of paths
+	// operator facing api for inspecting and controlling the cache
+	adminr := r.PathPrefix("/admin").Subrouter()
+	adminr.Use(AdminAuth)
+	adminr.HandleFunc("/keys", proxy.ListKeys).Methods("GET")
+	adminr.HandleFunc("/keys", proxy.InvalidateKeys).Methods("DELETE")
+	adminr.HandleFunc("/key", proxy.DumpKey).Methods("GET")
+	adminr.HandleFunc("/jobs", proxy.ListJobs).Methods("GET")
+	adminr.HandleFunc("/jobs/{action:refresh|pause|resume}", proxy.ControlJob).Methods("POST")
+	adminr.HandleFunc("/snapshot", proxy.ExportSnapshot).Methods("GET")
+	adminr.HandleFunc("/snapshot", proxy.ImportSnapshot).Methods("POST")
+	adminr.HandleFunc("/apikeys", proxy.ListAPIKeys).Methods("GET")
+	adminr.HandleFunc("/apikeys", proxy.CreateAPIKey).Methods("POST")
+	adminr.HandleFunc("/apikeys", proxy.DeleteAPIKey).Methods("DELETE")
+
+	// fallback to default handler for all the rest of paths, cached routes are matched there
 	r.PathPrefix("/").HandlerFunc(proxy.HandleDefaults)
 
-	return SetupInterceptor(r)
+	limiter := cacher.Limiter
+
+	// limits apply per authenticated key, so requests are authenticated first. Those failing to
+	// authenticate would never be limited, so every request is limited per ip ahead of that
+	return SetupInterceptor(r, limiter.AuthMiddleware, proxy.auth.Middleware, limiter.Middleware)
 }
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+func TestHandleViewsCount(t *testing.T) {
+
+	logging.InitLogger()
+
+	config.SetConfig(&config.Config{})
+	defer config.SetConfig(nil)
+
+	hh := &Handlers{cacher: &Cacher{}}
+
+	tests := []struct {
+		name  string
+		view  string
+		count string
+	}{
+		{"zero repositories", ViewByStars, "0"},
+		{"negative", ViewByForks, "-3"},
+		{"not a number", ViewByOpenIssues, "ten"},
+		{"zero members", ViewMembersByFollowers, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := mux.SetURLVars(httptest.NewRequest("GET", "/view/top/"+tt.count+"/stars", nil),
+				map[string]string{"id": tt.count})
+			w := httptest.NewRecorder()
+
+			hh.HandleViews(w, r, tt.view)
+			if w.Code != 400 {
+				t.Errorf("HandleViews() with count %q responded with %d, want 400", tt.count, w.Code)
+			}
+		})
+	}
+}
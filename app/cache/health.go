@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+
+	// StatusDegraded reports trouble which doesn't stop us from serving, such as upstream being down
+	// while cached data is served stale
+	StatusDegraded = "degraded"
+)
+
+// ComponentStatus reports the health of a single dependency
+type ComponentStatus struct {
+	Name    string      `json:"name"`
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// HealthReport is the document served by health endpoints
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components,omitempty"`
+}
+
+// add appends the component to report and downgrades overall status if component is failing or degraded
+func (hr *HealthReport) add(cs ComponentStatus) {
+	switch {
+	case cs.Status == StatusFail:
+		hr.Status = StatusFail
+	case cs.Status == StatusDegraded && hr.Status == StatusOK:
+		hr.Status = StatusDegraded
+	}
+	hr.Components = append(hr.Components, cs)
+}
+
+// Liveness lets others know the process is up and able to serve requests
+func (hh *Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, r, &HealthReport{Status: StatusOK})
+}
+
+// Readiness reports whether the cache is able to serve fresh data.
+// Checks redis, staleness of every cached key, upstream reachability and refresh jobs. Upstream
+// being unreachable only degrades readiness, cached data is still served while it is down
+func (hh *Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
+
+	report := &HealthReport{Status: StatusOK}
+
+	report.add(hh.checkRedis())
+	report.add(hh.checkCachedKeys())
+	report.add(hh.checkWarmup())
+	report.add(hh.checkUpstream(r.Context()))
+	report.add(hh.checkBreaker())
+	report.add(hh.checkJobs())
+
+	writeHealthReport(w, r, report)
+}
+
+// checkRedis verifies redis instance is reachable
+func (hh *Handlers) checkRedis() ComponentStatus {
+
+	cs := ComponentStatus{Name: "redis", Status: StatusOK}
+
+	if err := hh.cacher.DBClient.Ping(); err != nil {
+		cs.Status = StatusFail
+		cs.Message = err.Error()
+	}
+	return cs
+}
+
+// keyStatus is the staleness report of a single cached key
+type keyStatus struct {
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Age       string    `json:"age,omitempty"`
+}
+
+// checkCachedKeys verifies every configured cached key exists and is younger than max staleness
+func (hh *Handlers) checkCachedKeys() ComponentStatus {
+
+	cs := ComponentStatus{Name: "cache", Status: StatusOK}
+
+	details := make(map[string]keyStatus)
+	for _, url := range config.GetConfig().GetCachedURLs() {
+
+		// orgs refreshing less often than others are given longer to go stale
+		maxStaleness := config.GetConfig().GetKeyMaxStaleness(url)
+		meta, err := hh.cacher.DBClient.GetMeta(url)
+
+		switch {
+		case err != nil:
+			details[url] = keyStatus{Status: err.Error()}
+		case meta == nil:
+			details[url] = keyStatus{Status: "missing"}
+		case meta.Age() > maxStaleness:
+			details[url] = keyStatus{Status: "stale", UpdatedAt: meta.UpdatedAt, Age: meta.Age().String()}
+		default:
+			details[url] = keyStatus{Status: StatusOK, UpdatedAt: meta.UpdatedAt, Age: meta.Age().String()}
+			continue
+		}
+
+		cs.Status = StatusFail
+	}
+
+	if cs.Status != StatusOK {
+		cs.Message = "cached keys missing or older than their max staleness"
+	}
+	cs.Details = details
+	return cs
+}
+
+// checkWarmup verifies every cached key and view has been populated at least once
+func (hh *Handlers) checkWarmup() ComponentStatus {
+
+	cs := ComponentStatus{Name: "warmup", Status: StatusOK}
+
+	keys := append(append([]string{}, config.GetConfig().GetCachedURLs()...), AllViewKeys()...)
+
+	cold := []string{}
+	for _, key := range keys {
+		if !hh.cacher.IsWarm(key) {
+			cold = append(cold, key)
+		}
+	}
+
+	if len(cold) > 0 {
+		cs.Status = StatusFail
+		cs.Message = fmt.Sprintf("%d of %d keys not populated yet", len(cold), len(keys))
+	}
+	cs.Details = map[string]interface{}{"warm": len(keys) - len(cold), "cold": cold}
+	return cs
+}
+
+// checkUpstream reports whether upstream target is reachable, degraded if not
+func (hh *Handlers) checkUpstream(ctx context.Context) ComponentStatus {
+
+	cs := ComponentStatus{Name: "upstream", Status: StatusOK}
+
+	if err := hh.cacher.Provider.Ping(ctx); err != nil {
+		cs.Status = StatusDegraded
+		cs.Message = err.Error()
+	}
+	return cs
+}
+
+// checkBreaker reports the state of breaker guarding upstream calls, degraded while it is open
+func (hh *Handlers) checkBreaker() ComponentStatus {
+
+	status := hh.cacher.Breaker.Status()
+	cs := ComponentStatus{Name: "breaker", Status: StatusOK, Details: status}
+
+	if status.State == BreakerOpen {
+		cs.Status = StatusDegraded
+		cs.Message = ErrCircuitOpen.Error()
+	}
+	return cs
+}
+
+// checkJobs verifies every refresh job has succeeded within max staleness of its interval. Jobs which
+// haven't succeeded yet are given as long from their first scheduled run
+func (hh *Handlers) checkJobs() ComponentStatus {
+
+	cs := ComponentStatus{Name: "jobs", Status: StatusOK}
+
+	jobs := hh.cacher.Jobs.Status()
+	for _, js := range jobs {
+		if jobStale(js, time.Now()) {
+			cs.Status = StatusFail
+			cs.Message = fmt.Sprintf("job %s has not succeeded within %s", js.Name, jobMaxStaleness(js))
+		}
+	}
+
+	cs.Details = jobs
+	return cs
+}
+
+// jobMaxStaleness returns how long job may go without succeeding
+func jobMaxStaleness(js JobStatus) time.Duration {
+	if js.interval > 0 {
+		return config.GetConfig().GetMaxStalenessFor(js.interval)
+	}
+	// jobs run on demand are named by the key they build
+	return config.GetConfig().GetKeyMaxStaleness(js.Name)
+}
+
+// jobStale reports whether job has gone without succeeding for longer than its max staleness at now
+func jobStale(js JobStatus, now time.Time) bool {
+
+	since := js.LastSuccess
+	if since.IsZero() && !js.FirstRun.IsZero() {
+		since = js.FirstRun
+	}
+	return now.Sub(since) > jobMaxStaleness(js)
+}
+
+// writeHealthReport serializes the report, responding with 503 if any component is failing. Degraded
+// reports are served with 200
+func writeHealthReport(w http.ResponseWriter, r *http.Request, report *HealthReport) {
+
+	js, err := json.Marshal(report)
+	if err != nil {
+		logging.Logger(r.Context()).Error("Error trying to marshal health report",
+										  zap.String("msg", err.Error()))
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == StatusFail {
+		w.WriteHeader(503)
+	} else {
+		w.WriteHeader(200)
+	}
+	w.Write(js)
+}
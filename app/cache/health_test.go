@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+func TestJobStale(t *testing.T) {
+
+	conf := &config.Config{}
+	conf.Cache.RefreshInterval = 60
+	conf.Cache.MaxStaleness = 300
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	now := time.Now()
+	hour := time.Hour
+
+	tests := []struct {
+		name  string
+		job   JobStatus
+		stale bool
+	}{
+		{"succeeded recently", JobStatus{Name: "/", interval: time.Minute, FirstRun: now.Add(-hour),
+			LastSuccess: now.Add(-time.Minute)}, false},
+		{"not succeeded within max staleness", JobStatus{Name: "/", interval: time.Minute, FirstRun: now.Add(-hour),
+			LastSuccess: now.Add(-10 * time.Minute)}, true},
+		{"staleness follows the job's own interval", JobStatus{Name: "/orgs/Netflix/members/*",
+			interval: hour, FirstRun: now.Add(-5 * hour), LastSuccess: now.Add(-2 * hour)}, false},
+		{"first run not due yet", JobStatus{Name: "/orgs/Netflix/members/*", interval: hour,
+			FirstRun: now.Add(hour)}, false},
+		{"first run due recently", JobStatus{Name: "/orgs/Netflix/members/*", interval: hour,
+			FirstRun: now.Add(-hour)}, false},
+		{"never succeeded since first run", JobStatus{Name: "/orgs/Netflix/members/*", interval: hour,
+			FirstRun: now.Add(-4 * hour)}, true},
+		{"jobs run on demand never succeeded", JobStatus{Name: ViewsJob}, true},
+		{"jobs run on demand succeeded", JobStatus{Name: ViewsJob, LastSuccess: now.Add(-time.Minute)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobStale(tt.job, now); got != tt.stale {
+				t.Errorf("jobStale() = %v, want %v", got, tt.stale)
+			}
+		})
+	}
+}
+
+func TestHealthReport(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+		code     int
+	}{
+		{"all ok", []string{StatusOK, StatusOK}, StatusOK, 200},
+		{"degraded is still ready", []string{StatusOK, StatusDegraded}, StatusDegraded, 200},
+		{"failing", []string{StatusDegraded, StatusFail, StatusOK}, StatusFail, 503},
+		{"failing wins over later degraded", []string{StatusFail, StatusDegraded}, StatusFail, 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			report := &HealthReport{Status: StatusOK}
+			for _, status := range tt.statuses {
+				report.add(ComponentStatus{Name: "component", Status: status})
+			}
+			if report.Status != tt.want {
+				t.Errorf("Status = %s, want %s", report.Status, tt.want)
+			}
+
+			w := httptest.NewRecorder()
+			writeHealthReport(w, httptest.NewRequest("GET", "/readyz", nil), report)
+			if w.Code != tt.code {
+				t.Errorf("writeHealthReport() responded with %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestCheckBreaker(t *testing.T) {
+
+	logging.InitLogger()
+
+	tests := []struct {
+		name     string
+		failures int
+		want     string
+	}{
+		{"closed", 0, StatusOK},
+		{"open degrades without failing", 2, StatusDegraded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			breaker := newTestBreaker()
+			for i := 0; i < tt.failures; i++ {
+				breaker.Record(false)
+			}
+
+			hh := &Handlers{cacher: &Cacher{Breaker: breaker}}
+			if cs := hh.checkBreaker(); cs.Status != tt.want {
+				t.Errorf("checkBreaker() = %s, want %s", cs.Status, tt.want)
+			}
+		})
+	}
+}
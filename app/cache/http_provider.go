@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// HTTPProvider fetches endpoints of any json api, authenticating and paging through lists
+// as configured under target
+type HTTPProvider struct {
+	httpClient *http.Client
+}
+
+// NewHTTPProvider returns provider whose calls to upstream wait for the shared budget and go through the breaker
+func NewHTTPProvider(breaker *CircuitBreaker, budget *UpstreamBudget) *HTTPProvider {
+	return &HTTPProvider{
+		httpClient: &http.Client{Transport: budget.Transport(breaker.Transport(http.DefaultTransport))},
+	}
+}
+
+// Fetch queries path paging through it as configured under target.pagination
+func (hp *HTTPProvider) Fetch(ctx context.Context, path string, authorization string) ([]byte, error) {
+	return fetchPages(ctx, hp.httpClient, path, authorization, config.GetConfig().GetPaginationConfig())
+}
+
+// Ping checks whether the upstream target is reachable and healthy, bypassing the breaker
+func (hp *HTTPProvider) Ping(ctx context.Context) error {
+	return pingUpstream(ctx)
+}
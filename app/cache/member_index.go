@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// Member views are built like repository views, as sorted sets of member logins scored by the metric
+// they rank by. The members endpoint only tells logins and whether members are site admins, so views
+// ranking by public repos, followers and account age are built from profiles fetched when configured
+
+// keys under which views of members are cached
+const (
+	ViewMembersByPublicRepos = "top-member-by-publicrepos"
+	ViewMembersByFollowers   = "top-member-by-followers"
+	ViewMembersByAge         = "top-member-by-age"
+	ViewMemberAdmins         = "member-admins"
+	ViewMemberTimeline       = "member-timeline"
+)
+
+// MemberViewKeys lists the keys of all views built from member data
+var MemberViewKeys = []string{ViewMembersByPublicRepos, ViewMembersByFollowers, ViewMembersByAge,
+	ViewMemberAdmins, ViewMemberTimeline}
+
+// profileViews are the member views needing profiles of members
+var profileViews = []string{ViewMembersByPublicRepos, ViewMembersByFollowers, ViewMembersByAge}
+
+// memberTimelineLength is how many of the latest joins and leaves of an org are kept
+const memberTimelineLength = 1000
+
+// orgMembersKey returns the key of the set naming every member of org as of the last refresh
+func orgMembersKey(org string) string {
+	return model.InternalPrefix + "members:" + org
+}
+
+// memberProfilesKey returns the key of the hash holding profiles of members of org by login
+func memberProfilesKey(org string) string {
+	return model.InternalPrefix + "member-profiles:" + org
+}
+
+// isMemberView reports whether view is built from member data
+func isMemberView(view string) bool {
+	return containsString(MemberViewKeys, view)
+}
+
+// orgMemberViews returns the views of members built for org
+func orgMemberViews(org config.OrgConfig) []string {
+	if !org.CachesMembers() {
+		return nil
+	}
+	if !org.Members.Profiles {
+		return []string{ViewMemberAdmins, ViewMemberTimeline}
+	}
+	return MemberViewKeys
+}
+
+// memberScore returns the value of the metric view ranks member by, false if member is not part of view.
+// Older accounts and admins with lower ids rank first, so they are scored negated
+func memberScore(view string, member *github.User) (float64, bool) {
+	switch view {
+	case ViewMembersByPublicRepos:
+		return float64(member.GetPublicRepos()), true
+	case ViewMembersByFollowers:
+		return float64(member.GetFollowers()), true
+	case ViewMembersByAge:
+		if member.CreatedAt == nil {
+			return 0, false
+		}
+		return -float64(member.GetCreatedAt().Unix()), true
+	case ViewMemberAdmins:
+		return -float64(member.GetID()), member.GetSiteAdmin()
+	}
+	return 0, false
+}
+
+// timelineEvent returns the member of the timeline of org recording login joining or leaving at unix
+// time at. The time is part of it so that members leaving and joining again don't overwrite earlier events
+func timelineEvent(org string, login string, event string, at int64) string {
+	return strconv.FormatInt(at, 10) + " " + org + "/" + login + " " + event
+}
+
+// viewMemberName returns the name member of view is served as, events of timelines without their time
+// which is served as their score
+func viewMemberName(view string, member string) string {
+	if view != ViewMemberTimeline {
+		return member
+	}
+	// events recorded before their time was part of them are served as they are
+	at := strings.SplitN(member, " ", 2)
+	if _, err := strconv.ParseInt(at[0], 10, 64); err != nil || len(at) < 2 {
+		return member
+	}
+	return at[1]
+}
+
+// membershipEvents returns the timeline events of members of org in current but not in previous
+// joining and of those in previous but not in current leaving at unix time now, along with the logins
+// of members who left
+func membershipEvents(org string, previous []string, current []string, now int64) ([]model.ScoredMember, []string) {
+
+	known := make(map[string]bool, len(previous))
+	for _, login := range previous {
+		known[login] = true
+	}
+	members := make(map[string]bool, len(current))
+	for _, login := range current {
+		members[login] = true
+	}
+
+	var events []model.ScoredMember
+	var left []string
+	for _, login := range previous {
+		if !members[login] {
+			left = append(left, login)
+			events = append(events, model.ScoredMember{Member: timelineEvent(org, login, "left", now), Score: float64(now)})
+		}
+	}
+	for _, login := range current {
+		if !known[login] {
+			events = append(events, model.ScoredMember{Member: timelineEvent(org, login, "joined", now), Score: float64(now)})
+		}
+	}
+	return events, left
+}
+
+// IndexMembers records users as the members of org, adding those who joined or left since the previous
+// refresh to the timeline of org, and ranks them into the member views of org. Only refreshes fetching
+// members from upstream index them, members cached otherwise have their views built by BuildMemberViews
+func (cc *Cacher) IndexMembers(org config.OrgConfig, users []*github.User) error {
+
+	logins := make([]string, 0, len(users))
+	for _, user := range users {
+		if user.GetLogin() != "" {
+			logins = append(logins, user.GetLogin())
+		}
+	}
+
+	previous, err := cc.DBClient.SetMembers(orgMembersKey(org.Name))
+	if err != nil {
+		return err
+	}
+
+	// the first index of org has no membership to compare with
+	meta, err := cc.DBClient.GetMeta(ViewKey(ViewMemberTimeline, org.Name))
+	if err != nil {
+		return err
+	}
+
+	var events []model.ScoredMember
+	var left []string
+	if meta != nil {
+		events, left = membershipEvents(org.Name, previous, logins, time.Now().Unix())
+	}
+
+	if err := cc.DBClient.ReplaceSet(orgMembersKey(org.Name), logins); err != nil {
+		return err
+	}
+	if len(left) > 0 {
+		if err := cc.DBClient.HDel(memberProfilesKey(org.Name), left...); err != nil {
+			return err
+		}
+	}
+
+	timelineKey := viewIndexKey(ViewMemberTimeline, org.Name)
+	if err := cc.DBClient.AddScored(timelineKey, events, memberTimelineLength); err != nil {
+		return err
+	}
+	timeline, err := cc.DBClient.TopScored(timelineKey, 0)
+	if err != nil {
+		return err
+	}
+	if err := cc.DBClient.Touch(ViewKey(ViewMemberTimeline, org.Name), scoresHash(timeline)); err != nil {
+		return err
+	}
+	cc.notify(ViewKey(ViewMemberTimeline, org.Name))
+
+	logging.Logger(context.Background()).Info("Members indexed",
+											  zap.String("org", org.Name),
+											  zap.Int("members", len(logins)),
+											  zap.Int("events", len(events)))
+
+	return cc.buildMemberViews(org, users)
+}
+
+// buildMemberViews ranks members of org into views, those needing profiles by the profiles fetched so far.
+// Members whose profile is not fetched yet are left out of them
+func (cc *Cacher) buildMemberViews(org config.OrgConfig, users []*github.User) error {
+
+	views := []string{ViewMemberAdmins}
+	var profiles map[string]string
+	if org.Members.Profiles {
+		views = append(views, profileViews...)
+
+		var err error
+		if profiles, err = cc.DBClient.HGetAll(memberProfilesKey(org.Name)); err != nil {
+			return err
+		}
+	}
+
+	scores := make(map[string][]model.ScoredMember, len(views))
+	for _, user := range users {
+
+		login := user.GetLogin()
+		if login == "" {
+			continue
+		}
+
+		profile := &github.User{}
+		if data, ok := profiles[login]; ok {
+			if err := json.Unmarshal([]byte(data), profile); err != nil {
+				return err
+			}
+		}
+
+		for _, view := range views {
+
+			// whether members are admins is told by the members endpoint, the rest by their profiles
+			member := profile
+			if view == ViewMemberAdmins {
+				member = user
+			} else if profile.Login == nil {
+				continue
+			}
+
+			if score, ok := memberScore(view, member); ok {
+				scores[view] = append(scores[view], model.ScoredMember{Member: login, Score: score})
+			}
+		}
+	}
+
+	for _, view := range views {
+		if err := cc.DBClient.ReplaceSortedSet(viewIndexKey(view, org.Name), scores[view]); err != nil {
+			return err
+		}
+		if err := cc.DBClient.Touch(ViewKey(view, org.Name), scoresHash(scores[view])); err != nil {
+			return err
+		}
+		cc.notify(ViewKey(view, org.Name))
+	}
+	return nil
+}
+
+// BuildMemberViews ranks the cached members of org into the member views of org. Refreshes build them
+// as they fetch members, so this is only needed for members cached otherwise, such as from a snapshot.
+// The timeline is left alone, members cached otherwise never joined nor left as far as it knows
+func (cc *Cacher) BuildMemberViews(org config.OrgConfig) error {
+
+	users, err := cc.cachedMembers(org)
+	if err != nil {
+		return err
+	}
+	return cc.buildMemberViews(org, users)
+}
+
+// cachedMembers returns the members of org currently cached
+func (cc *Cacher) cachedMembers(org config.OrgConfig) ([]*github.User, error) {
+
+	var users []*github.User
+	if err := json.Unmarshal(cc.GetCachedEndpoint(org.GetMembersURL()), &users); err != nil {
+		logging.Logger(context.Background()).Error("Error unmarshalling members",
+												   zap.String("org", org.Name),
+												   zap.String("msg", err.Error()))
+		return nil, err
+	}
+	return users, nil
+}
+
+// CacheMemberProfiles periodically fetches profiles of members of org and rebuilds the member views
+// ranking them, along with those aggregated across orgs. Profiles are fetched for the cached members,
+// so the first fetch waits a refresh interval for members to be cached
+func (cc *Cacher) CacheMemberProfiles(org config.OrgConfig) {
+
+	refresh := config.GetConfig().GetRefreshInterval(org.GetMemberProfilesJob())
+	cc.Jobs.ScheduleAfter(org.GetMemberProfilesJob(), refresh, refresh, func() error {
+		if err := cc.RefreshMemberProfiles(org); err != nil {
+			return err
+		}
+		cc.Jobs.Run(ViewsJob)
+		return nil
+	})
+}
+
+// RefreshMemberProfiles fetches the profile of every cached member of org once and rebuilds member views
+// of org from them. Profiles upstream has nothing for, such as of deleted accounts, are skipped. Returns
+// the first error encountered after trying every member
+func (cc *Cacher) RefreshMemberProfiles(org config.OrgConfig) error {
+
+	if !org.Members.Profiles {
+		return nil
+	}
+
+	users, err := cc.cachedMembers(org)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, user := range users {
+
+		url := "/users/" + user.GetLogin()
+		resp, err := cc.Provider.Fetch(context.Background(), url, "")
+
+		if statusErr, ok := err.(*UpstreamStatusError); ok && statusErr.StatusCode < 500 {
+			logging.Logger(context.Background()).Info("Skipping member profile",
+													  zap.String("url", url),
+													  zap.Int("status", statusErr.StatusCode))
+			continue
+		}
+		if err != nil {
+			logging.Logger(context.Background()).Error("Error fetching member profile",
+													   zap.String("url", url),
+													   zap.String("msg", err.Error()))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if err := cc.DBClient.HSet(memberProfilesKey(org.Name), user.GetLogin(), resp); err != nil {
+			return err
+		}
+	}
+
+	if err := cc.buildMemberViews(org, users); err != nil {
+		return err
+	}
+	return firstErr
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+
+	"github.com/aniketalshi/go_rest_cache/app/model"
+)
+
+func TestMemberScore(t *testing.T) {
+
+	created := github.Timestamp{Time: time.Unix(1500000000, 0)}
+	member := &github.User{
+		ID:          github.Int64(42),
+		PublicRepos: github.Int(7),
+		Followers:   github.Int(120),
+		CreatedAt:   &created,
+		SiteAdmin:   github.Bool(true),
+	}
+
+	tests := []struct {
+		name   string
+		view   string
+		member *github.User
+		score  float64
+		ok     bool
+	}{
+		{"public repos", ViewMembersByPublicRepos, member, 7, true},
+		{"followers", ViewMembersByFollowers, member, 120, true},
+		{"older accounts rank first", ViewMembersByAge, member, -1500000000, true},
+		{"account age unknown", ViewMembersByAge, &github.User{}, 0, false},
+		{"admins with lower ids rank first", ViewMemberAdmins, member, -42, true},
+		{"members who are not admins", ViewMemberAdmins, &github.User{ID: github.Int64(1)}, -1, false},
+		{"views not ranking members", ViewByStars, member, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, ok := memberScore(tt.view, tt.member)
+			if score != tt.score || ok != tt.ok {
+				t.Errorf("memberScore() = %v, %v, want %v, %v", score, ok, tt.score, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMembershipEvents(t *testing.T) {
+
+	const now = 1600000000
+
+	tests := []struct {
+		name     string
+		previous []string
+		current  []string
+		events   []string
+		left     []string
+	}{
+		{
+			name:     "unchanged",
+			previous: []string{"alice", "bob"},
+			current:  []string{"bob", "alice"},
+		},
+		{
+			name:     "joined",
+			previous: []string{"alice"},
+			current:  []string{"alice", "bob", "carol"},
+			events:   []string{"1600000000 Netflix/bob joined", "1600000000 Netflix/carol joined"},
+		},
+		{
+			name:     "left",
+			previous: []string{"alice", "bob", "carol"},
+			current:  []string{"bob"},
+			events:   []string{"1600000000 Netflix/alice left", "1600000000 Netflix/carol left"},
+			left:     []string{"alice", "carol"},
+		},
+		{
+			name:     "left and joined",
+			previous: []string{"alice", "bob"},
+			current:  []string{"bob", "dave"},
+			events:   []string{"1600000000 Netflix/alice left", "1600000000 Netflix/dave joined"},
+			left:     []string{"alice"},
+		},
+		{
+			name:    "everyone joined an org without members",
+			current: []string{"alice"},
+			events:  []string{"1600000000 Netflix/alice joined"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, left := membershipEvents("Netflix", tt.previous, tt.current, now)
+
+			var names []string
+			for _, event := range events {
+				if event.Score != now {
+					t.Errorf("event %s scored %v, want %v", event.Member, event.Score, now)
+				}
+				names = append(names, event.Member)
+			}
+			if !reflect.DeepEqual(names, tt.events) {
+				t.Errorf("membershipEvents() events = %q, want %q", names, tt.events)
+			}
+			if !reflect.DeepEqual(left, tt.left) {
+				t.Errorf("membershipEvents() left = %q, want %q", left, tt.left)
+			}
+		})
+	}
+}
+
+func TestMembershipEventsOfRejoins(t *testing.T) {
+
+	// leaving and joining again are recorded apart from the first join instead of replacing it
+	joined, _ := membershipEvents("Netflix", nil, []string{"alice"}, 100)
+	left, _ := membershipEvents("Netflix", []string{"alice"}, nil, 200)
+	rejoined, _ := membershipEvents("Netflix", nil, []string{"alice"}, 300)
+
+	seen := make(map[string]bool)
+	for _, events := range [][]model.ScoredMember{joined, left, rejoined} {
+		for _, event := range events {
+			if seen[event.Member] {
+				t.Errorf("event %s recorded twice", event.Member)
+			}
+			seen[event.Member] = true
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("got %d distinct events, want 3", len(seen))
+	}
+}
+
+func TestViewMemberName(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		view   string
+		member string
+		want   string
+	}{
+		{"timeline events without their time", ViewMemberTimeline, "1600000000 Netflix/alice joined", "Netflix/alice joined"},
+		{"timeline events recorded without time", ViewMemberTimeline, "Netflix/alice left", "Netflix/alice left"},
+		{"members of other views", ViewMembersByFollowers, "1600000000 alice", "1600000000 alice"},
+		{"repositories", ViewByStars, "Netflix/zuul", "Netflix/zuul"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := viewMemberName(tt.view, tt.member); got != tt.want {
+				t.Errorf("viewMemberName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
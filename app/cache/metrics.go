@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"expvar"
+)
+
+// metrics are published through expvar and served as json on /metrics
+var metrics = expvar.NewMap("cache")
+
+// PublishMetrics exposes state of the cacher's breaker and refresh jobs in metrics
+func PublishMetrics(cc *Cacher) {
+
+	metrics.Set("upstream_breaker", expvar.Func(func() interface{} {
+		return cc.Breaker.Status()
+	}))
+
+	metrics.Set("jobs", expvar.Func(func() interface{} {
+		return cc.Jobs.Status()
+	}))
+}
@@ -1,11 +1,10 @@
 package cache
 
 import (
-	"time"
-	"net"
+	"strings"
+	"crypto/subtle"
 	"net/http"
 	"context"
-	"net/http/httputil"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -18,8 +17,14 @@ import (
 var httpContext = context.Background()
 
 // SetupInterceptor is a middleware function which acts like a wrapper over handler
-// Generates request id, associate it with the context which is passed along api calls
-func SetupInterceptor(next http.Handler) http.Handler {
+// Generates request id, associate it with the context which is passed along api calls.
+// Middlewares are run in order after the request id is generated and before next
+func SetupInterceptor(next http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	
 		// generate a unique request id
@@ -37,29 +42,34 @@ func SetupInterceptor(next http.Handler) http.Handler {
 	})
 }
 
-func GenerateProxy() *httputil.ReverseProxy {
-	
-	// get the configuration parameters about the upstream target 
-	token := config.GetConfig().GetTargetToken()
-	url := config.GetConfig().GetTargetUrl()
+// AdminAuth is a middleware guarding the admin api. Requests must carry the configured
+// admin token as a bearer token or be authenticated with an api key granted admin scope.
+// Without either configured admin api is disabled altogether
+func AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-	if token == "" {
-		logging.Logger(context.Background()).Info("Token is not set")
-	}
+		if HasScope(APIKeyFromContext(r.Context()), ScopeAdmin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		adminToken := config.GetConfig().GetAdminToken()
+		if adminToken == "" {
+			w.WriteHeader(404)
+			w.Write([]byte("admin api is disabled"))
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
 
-	proxy := &httputil.ReverseProxy{Director: func(req *http.Request) {
-		req.Header.Add("X-Forwarded-Host", req.Host)
-		req.Header.Add("X-Origin-Host", url)
-		req.Header.Add("Authorization", token)
-		req.Host = url
-		req.URL.Host = url
-		req.URL.Scheme = "https"
-
-	}, Transport: &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: time.Duration(config.GetConfig().GetTargetTimeout()) * time.Second,
-		}).Dial,
-	}}
-
-	return proxy
+			logging.Logger(r.Context()).Warn("Unauthorized admin request",
+											 zap.String("uri", r.RequestURI))
+			w.WriteHeader(401)
+			w.Write([]byte("unauthorized"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
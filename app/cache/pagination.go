@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// paging of cached lists follows github, 30 items per page unless asked for up to 100
+const (
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+// errUnsupportedQuery is returned for list queries the cache can't answer
+var errUnsupportedQuery = errors.New("query is not supported on cached list")
+
+// repoListParams are query parameters of org repos endpoint applied to the cached list
+var repoListParams = []string{"type", "sort", "direction"}
+
+// repoFields holds fields of a repository the org repos endpoint filters and sorts by
+type repoFields struct {
+	FullName  string    `json:"full_name"`
+	Private   bool      `json:"private"`
+	Fork      bool      `json:"fork"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	PushedAt  time.Time `json:"pushed_at"`
+}
+
+// isListQuery reports whether request asks for a page of the cached list, or for the list filtered
+// or sorted by parameters the route applies itself
+func isListQuery(r *http.Request, route *Route) bool {
+
+	query := r.URL.Query()
+	if query.Get("page") != "" || query.Get("per_page") != "" {
+		return true
+	}
+	for _, param := range route.Params {
+		if query.Get(param) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveList serves the page of cached list body requested, filtered and sorted as asked, along with
+// github compatible Link header. Returns false without writing anything if the body is not a list or
+// the request asks for something the cache can't answer, in which case it is to be proxied
+func serveList(w http.ResponseWriter, r *http.Request, route *Route, body []byte, encoding string,
+	meta *model.KeyMeta, cacheControl string) bool {
+
+	content, err := model.Decompress(body, encoding)
+	if err != nil {
+		return false
+	}
+
+	var items []json.RawMessage
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) || json.Unmarshal(content, &items) != nil {
+		return false
+	}
+
+	query := r.URL.Query()
+	if len(route.Params) > 0 {
+		if items, err = filterRepos(items, query); err != nil {
+			return false
+		}
+	}
+
+	page, lastPage, start, end := paginate(query, len(items))
+	pageItems := items[start:end]
+
+	data, err := marshalItems(pageItems)
+	if err != nil {
+		return false
+	}
+
+	if link := listLinks(r, page, lastPage); link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	// etag is derived from the page since every page is a representation of its own
+	var served *model.KeyMeta
+	if meta != nil {
+		served = &model.KeyMeta{UpdatedAt: meta.UpdatedAt, ModifiedAt: meta.ModifiedAt}
+	}
+	serveCached(w, r, data, model.EncodingIdentity, served, cacheControl)
+	return true
+}
+
+// paginate returns the page of a list of total items requested by query, the last page of the list
+// and bounds of the requested page within the list, empty for pages past the last one
+func paginate(query url.Values, total int) (page int, lastPage int, start int, end int) {
+
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	page, err = strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	lastPage = (total + perPage - 1) / perPage
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	start, end = total, total
+	if (page-1)*perPage < total {
+		start = (page - 1) * perPage
+		if start+perPage < total {
+			end = start + perPage
+		}
+	}
+	return page, lastPage, start, end
+}
+
+// filterRepos applies type, sort and direction parameters of org repos endpoint to items. Values
+// which depend on who is asking, such as type=member, are reported as errors
+func filterRepos(items []json.RawMessage, query url.Values) ([]json.RawMessage, error) {
+
+	fields := make([]repoFields, len(items))
+	for i, item := range items {
+		if err := json.Unmarshal(item, &fields[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	indices, err := orderRepos(fields, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]json.RawMessage, len(indices))
+	for i, index := range indices {
+		result[i] = items[index]
+	}
+	return result, nil
+}
+
+// orderRepos returns indices of the repositories kept by type parameter of query, in the order
+// sort and direction parameters ask for
+func orderRepos(fields []repoFields, query url.Values) ([]int, error) {
+
+	var keep func(repoFields) bool
+	switch query.Get("type") {
+	case "", "all":
+	case "public":
+		keep = func(rf repoFields) bool { return !rf.Private }
+	case "private":
+		keep = func(rf repoFields) bool { return rf.Private }
+	case "forks":
+		keep = func(rf repoFields) bool { return rf.Fork }
+	case "sources":
+		keep = func(rf repoFields) bool { return !rf.Fork }
+	default:
+		return nil, errUnsupportedQuery
+	}
+
+	var less func(a, b repoFields) bool
+	switch query.Get("sort") {
+	case "":
+	case "created":
+		less = func(a, b repoFields) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case "updated":
+		less = func(a, b repoFields) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case "pushed":
+		less = func(a, b repoFields) bool { return a.PushedAt.Before(b.PushedAt) }
+	case "full_name":
+		less = func(a, b repoFields) bool { return strings.ToLower(a.FullName) < strings.ToLower(b.FullName) }
+	default:
+		return nil, errUnsupportedQuery
+	}
+
+	// github sorts by creation date unless told otherwise, which is how the list is cached already
+	direction := query.Get("direction")
+	if less == nil && direction != "" {
+		less = func(a, b repoFields) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	}
+	switch direction {
+	case "", "asc", "desc":
+	default:
+		return nil, errUnsupportedQuery
+	}
+	descending := direction == "desc" || (direction == "" && query.Get("sort") != "full_name")
+
+	indices := make([]int, 0, len(fields))
+	for i := range fields {
+		if keep == nil || keep(fields[i]) {
+			indices = append(indices, i)
+		}
+	}
+
+	if less != nil {
+		sort.SliceStable(indices, func(i, j int) bool {
+			a, b := fields[indices[i]], fields[indices[j]]
+			if descending {
+				return less(b, a)
+			}
+			return less(a, b)
+		})
+	}
+	return indices, nil
+}
+
+// listLinks returns Link header pointing at the first, previous, next and last pages of the list
+// requested, the way github does
+func listLinks(r *http.Request, page int, lastPage int) string {
+
+	pageURL := func(n int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(n))
+		return requestBase(r) + r.URL.Path + "?" + query.Encode()
+	}
+
+	var links []string
+	if page < lastPage {
+		links = append(links, "<"+pageURL(page+1)+`>; rel="next"`, "<"+pageURL(lastPage)+`>; rel="last"`)
+	}
+	if page > 1 {
+		prev := page - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		links = append(links, "<"+pageURL(1)+`>; rel="first"`, "<"+pageURL(prev)+`>; rel="prev"`)
+	}
+	return strings.Join(links, ", ")
+}
+
+// requestBase returns the base url clients reach the cache at, the configured external url if any
+func requestBase(r *http.Request) string {
+
+	if external := config.GetConfig().GetExternalURL(); external != "" {
+		return strings.TrimSuffix(external, "/")
+	}
+
+	return requestScheme(r) + "://" + requestHost(r)
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+func TestPaginate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		query    string
+		total    int
+		page     int
+		lastPage int
+		start    int
+		end      int
+	}{
+		{"first page by default", "", 75, 1, 3, 0, 30},
+		{"middle page", "page=2", 75, 2, 3, 30, 60},
+		{"last page is partial", "page=3", 75, 3, 3, 60, 75},
+		{"past the last page", "page=9", 75, 9, 3, 75, 75},
+		{"per page asked for", "per_page=10&page=2", 75, 2, 8, 10, 20},
+		{"per page is capped", "per_page=500", 250, 1, 3, 0, 100},
+		{"invalid values fall back to defaults", "per_page=-1&page=abc", 40, 1, 2, 0, 30},
+		{"empty list has a single page", "", 0, 1, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page, lastPage, start, end := paginate(query, tt.total)
+			if page != tt.page || lastPage != tt.lastPage || start != tt.start || end != tt.end {
+				t.Errorf("paginate(%q, %d) = %d, %d, %d, %d, want %d, %d, %d, %d", tt.query, tt.total,
+					page, lastPage, start, end, tt.page, tt.lastPage, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestListLinks(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		external string
+		trust    bool
+		target   string
+		headers  map[string]string
+		page     int
+		lastPage int
+		want     string
+	}{
+		{
+			name: "single page", target: "/orgs/Netflix/repos", page: 1, lastPage: 1,
+			want: "",
+		},
+		{
+			name: "first page", target: "/orgs/Netflix/repos?per_page=10", page: 1, lastPage: 3,
+			want: `<http://example.com/orgs/Netflix/repos?page=2&per_page=10>; rel="next", ` +
+				`<http://example.com/orgs/Netflix/repos?page=3&per_page=10>; rel="last"`,
+		},
+		{
+			name: "middle page", target: "/orgs/Netflix/repos?page=2", page: 2, lastPage: 3,
+			want: `<http://example.com/orgs/Netflix/repos?page=3>; rel="next", ` +
+				`<http://example.com/orgs/Netflix/repos?page=3>; rel="last", ` +
+				`<http://example.com/orgs/Netflix/repos?page=1>; rel="first", ` +
+				`<http://example.com/orgs/Netflix/repos?page=1>; rel="prev"`,
+		},
+		{
+			name: "past the last page points back at it", target: "/orgs/Netflix/repos?page=7", page: 7, lastPage: 3,
+			want: `<http://example.com/orgs/Netflix/repos?page=1>; rel="first", ` +
+				`<http://example.com/orgs/Netflix/repos?page=3>; rel="prev"`,
+		},
+		{
+			name: "external url", external: "https://cache.example.org/", target: "/orgs/Netflix/repos",
+			page: 1, lastPage: 2,
+			want: `<https://cache.example.org/orgs/Netflix/repos?page=2>; rel="next", ` +
+				`<https://cache.example.org/orgs/Netflix/repos?page=2>; rel="last"`,
+		},
+		{
+			name: "forwarded headers of untrusted clients are ignored", target: "/orgs/Netflix/repos",
+			headers: map[string]string{"X-Forwarded-Host": "evil.example", "X-Forwarded-Proto": "https"},
+			page:    1, lastPage: 2,
+			want: `<http://example.com/orgs/Netflix/repos?page=2>; rel="next", ` +
+				`<http://example.com/orgs/Netflix/repos?page=2>; rel="last"`,
+		},
+		{
+			name: "forwarded headers behind a trusted proxy", trust: true, target: "/orgs/Netflix/repos",
+			headers: map[string]string{"X-Forwarded-Host": "cache.example.org, proxy", "X-Forwarded-Proto": "https"},
+			page:    1, lastPage: 2,
+			want: `<https://cache.example.org/orgs/Netflix/repos?page=2>; rel="next", ` +
+				`<https://cache.example.org/orgs/Netflix/repos?page=2>; rel="last"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			conf := &config.Config{}
+			conf.Server.ExternalURL = tt.external
+			conf.Server.TrustForwarded = tt.trust
+			config.SetConfig(conf)
+			defer config.SetConfig(nil)
+
+			r := httptest.NewRequest("GET", tt.target, nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if got := listLinks(r, tt.page, tt.lastPage); got != tt.want {
+				t.Errorf("listLinks() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderRepos(t *testing.T) {
+
+	day := func(n int) time.Time { return time.Date(2020, 1, n, 0, 0, 0, 0, time.UTC) }
+	fields := []repoFields{
+		{FullName: "Netflix/zuul", CreatedAt: day(3), UpdatedAt: day(4), PushedAt: day(9)},
+		{FullName: "Netflix/Hystrix", Fork: true, CreatedAt: day(1), UpdatedAt: day(8), PushedAt: day(5)},
+		{FullName: "Netflix/eureka", Private: true, CreatedAt: day(2), UpdatedAt: day(6), PushedAt: day(7)},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+		err   error
+	}{
+		{"cached order by default", "", []int{0, 1, 2}, nil},
+		{"public", "type=public", []int{0, 1}, nil},
+		{"private", "type=private", []int{2}, nil},
+		{"forks", "type=forks", []int{1}, nil},
+		{"sources", "type=sources", []int{0, 2}, nil},
+		{"dates sort newest first", "sort=updated", []int{1, 2, 0}, nil},
+		{"ascending", "sort=pushed&direction=asc", []int{1, 2, 0}, nil},
+		{"names sort ignoring case", "sort=full_name", []int{2, 1, 0}, nil},
+		{"direction alone sorts by creation", "direction=asc", []int{1, 2, 0}, nil},
+		{"types depending on the client", "type=member", nil, errUnsupportedQuery},
+		{"unknown sort", "sort=stars", nil, errUnsupportedQuery},
+		{"unknown direction", "direction=up", nil, errUnsupportedQuery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := orderRepos(fields, query)
+			if err != tt.err {
+				t.Fatalf("orderRepos(%q) error = %v, want %v", tt.query, err, tt.err)
+			}
+			if tt.err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderRepos(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// partitionPrefix prefixes keys holding responses fetched with a client's own token. Being internal
+// keeps private data out of admin listings and snapshots
+const partitionPrefix = model.InternalPrefix + "partition:"
+
+// passthroughAuthorization returns the Authorization header client wants forwarded upstream,
+// empty unless passthrough is enabled
+func passthroughAuthorization(r *http.Request) string {
+	if !config.GetConfig().GetPassthroughConfig().Enabled {
+		return ""
+	}
+	return r.Header.Get("Authorization")
+}
+
+// isPartitioned reports whether r carries the client's own token while cached routes are partitioned
+// by token, in which case nothing built from data cached with our token may be served to it
+func isPartitioned(r *http.Request) bool {
+	return passthroughAuthorization(r) != "" &&
+		config.GetConfig().GetPassthroughConfig().GetCached() == config.PassthroughPartition
+}
+
+// SharedDataOnly guards routes served from data cached with our token, such as views, search and
+// graphql. Clients bringing their own token in partition mode get 403 there, since that data may hold
+// what their token can't see
+func SharedDataOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPartitioned(r) {
+			logging.Logger(r.Context()).Warn("Refusing shared data to client with its own token",
+											 zap.String("path", r.URL.Path))
+			w.WriteHeader(403)
+			w.Write([]byte("not available to clients passing their own token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// partitionKey returns the key response for path is cached under for the client holding authorization
+func partitionKey(authorization, path string) string {
+	sum := sha256.Sum256([]byte(authorization))
+	return partitionPrefix + hex.EncodeToString(sum[:8]) + ":" + path
+}
+
+// HandlePartitioned serves a cached route for client bringing its own token. Responses are fetched
+// on demand with that token and cached for the refresh interval under a key derived from it, so that
+// clients only ever see data their token grants them. url is the normalized path and query requested
+// of route, the response is fetched with the path as requested
+func (hh *Handlers) HandlePartitioned(w http.ResponseWriter, r *http.Request, route *Route, url string,
+	authorization string) {
+
+	key := partitionKey(authorization, url)
+	ttl := time.Duration(config.GetConfig().GetCacheConfig().RefreshInterval) * time.Second
+
+	if response, encoding := hh.cacher.GetCachedEncoded(key); response != nil {
+		logging.Logger(r.Context()).Info("Serving response from client partition",
+										 zap.String("path", r.URL.Path))
+		hh.servePartitioned(w, r, route, response, encoding, "private, "+maxAge(ttl))
+		return
+	}
+
+	response, err := hh.cacher.Provider.Fetch(r.Context(), CachedRoutes().UpstreamPath(r.URL, route), authorization)
+	if err != nil {
+		// never fall back to shared data here, it may hold what the client's token can't see
+		hh.writeFetchError(w, r, err)
+		return
+	}
+
+	response = StoreRewriter().RewriteBody(response)
+
+	if err := hh.cacher.DBClient.SetWithTTL(key, response, ttl); err != nil {
+		logging.Logger(r.Context()).Error("Error caching response in client partition",
+										  zap.String("path", r.URL.Path),
+										  zap.String("msg", err.Error()))
+	}
+
+	hh.servePartitioned(w, r, route, response, model.EncodingIdentity, "private, "+maxAge(ttl))
+}
+
+// servePartitioned serves response cached for a client, sliced out as requested if it is a list
+func (hh *Handlers) servePartitioned(w http.ResponseWriter, r *http.Request, route *Route, response []byte,
+	encoding string, cacheControl string) {
+
+	if isListQuery(r, route) {
+		if !serveList(w, r, route, response, encoding, nil, cacheControl) {
+			// proxied requests carry the client's token in passthrough mode
+			hh.forward(w, r)
+		}
+		return
+	}
+	serveCached(w, r, response, encoding, nil, cacheControl)
+}
+
+// writeFetchError responds to request whose response could not be fetched from upstream on demand
+func (hh *Handlers) writeFetchError(w http.ResponseWriter, r *http.Request, err error) {
+
+	if statusErr, ok := err.(*UpstreamStatusError); ok {
+		// relay rejections such as bad credentials as they are
+		w.WriteHeader(statusErr.StatusCode)
+		w.Write(statusErr.Body)
+		return
+	}
+
+	logging.Logger(r.Context()).Error("Error fetching response from upstream",
+									  zap.String("path", r.URL.Path),
+									  zap.String("msg", err.Error()))
+
+	if err == ErrCircuitOpen {
+		retryAfter := hh.cacher.Breaker.RetryAfter()/time.Second + 1
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		w.WriteHeader(503)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(502)
+}
+
+// readThrough fetches the response of read through route cached under url and caches it, returning
+// its book-keeping information. When upstream fails stale data is served if there is any, otherwise
+// the error is written to client and nil returned
+func (hh *Handlers) readThrough(w http.ResponseWriter, r *http.Request, url string, meta *model.KeyMeta) *model.KeyMeta {
+
+	response, err := hh.cacher.Provider.Fetch(r.Context(), url, "")
+	if err != nil {
+		if meta != nil {
+			logging.Logger(r.Context()).Warn("Error refreshing read through route, serving stale response",
+											 zap.String("key", url),
+											 zap.String("msg", err.Error()))
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			return meta
+		}
+		hh.writeFetchError(w, r, err)
+		return nil
+	}
+
+	hh.cacher.store(url, response)
+
+	meta, err = hh.cacher.DBClient.GetMeta(url)
+	if err != nil || meta == nil {
+		w.WriteHeader(502)
+		return nil
+	}
+	return meta
+}
@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+	"github.com/google/go-github/v28/github"
+)
+
+// Provider fetches data to be cached from an upstream api
+type Provider interface {
+
+	// Fetch returns the response of path, paging through list endpoints and merging their pages into
+	// a single json array. authorization is sent instead of our token when not empty
+	Fetch(ctx context.Context, path string, authorization string) ([]byte, error)
+
+	// Ping checks whether upstream is reachable and healthy
+	Ping(ctx context.Context) error
+}
+
+// OrgProvider is implemented by providers with their own way of listing repositories and members of
+// orgs, such as the github graphql api. Orgs of other providers are fetched from the same endpoints
+// through Fetch
+type OrgProvider interface {
+	GetRepositories(org string) ([]*github.Repository, error)
+	GetMembers(org string) ([]*github.User, error)
+	GetOrgDetails(url string) ([]byte, error)
+	GetRootInfo() ([]byte, error)
+}
+
+// maxPages bounds the number of pages fetched for a single endpoint, in case upstream keeps
+// handing out the same cursor
+const maxPages = 1000
+
+// UpstreamStatusError is returned when upstream answers a request with anything but 2xx,
+// so that the status can be relayed back to clients
+type UpstreamStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (ue *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream returned status %d", ue.StatusCode)
+}
+
+// authorizeUpstream presents our token on req the way upstream expects it
+func authorizeUpstream(req *http.Request) {
+	if name, value := config.GetConfig().GetUpstreamAuthorization(); name != "" {
+		req.Header.Set(name, value)
+	}
+}
+
+// fetchPages fetches path with client paging through it as described by pagination. Requests carry
+// authorization if not empty, our token otherwise
+func fetchPages(ctx context.Context, client *http.Client, path string, authorization string,
+	pagination config.PaginationConfig) ([]byte, error) {
+
+	next, err := url.Parse(config.GetConfig().GetTargetBaseURL() + path)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := pagination.GetStrategy()
+	if strategy != config.PaginateNone {
+		query := next.Query()
+		query.Set(pagination.GetSizeParam(), strconv.Itoa(pagination.GetSize()))
+		next.RawQuery = query.Encode()
+	}
+
+	var merged []json.RawMessage
+	offset := 0
+
+	for page := 1; page <= maxPages; page++ {
+
+		switch strategy {
+		case config.PaginatePage:
+			setQuery(next, pagination.GetPageParam(), strconv.Itoa(page))
+		case config.PaginateOffset:
+			setQuery(next, pagination.GetPageParam(), strconv.Itoa(offset))
+		}
+
+		body, header, err := fetchPage(ctx, client, next.String(), authorization)
+		if err != nil {
+			return nil, err
+		}
+
+		items, isList, err := listItems(body, pagination.ItemsField)
+		if err != nil {
+			return nil, err
+		}
+
+		// single objects are not paginated
+		if !isList {
+			return body, nil
+		}
+		merged = append(merged, items...)
+
+		done := len(items) < pagination.GetSize()
+		switch strategy {
+		case config.PaginateLink:
+			link := ParseLinkHeader(header.Get("Link"))["next"]
+			if link == "" {
+				return marshalItems(merged)
+			}
+			if next, err = url.Parse(link); err != nil {
+				return nil, err
+			}
+			continue
+
+		case config.PaginateCursor:
+			cursor, err := cursorValue(body, pagination.CursorField)
+			if err != nil {
+				return nil, err
+			}
+			if cursor == "" || len(items) == 0 {
+				return marshalItems(merged)
+			}
+			setQuery(next, pagination.GetPageParam(), cursor)
+			continue
+
+		case config.PaginateNone:
+			done = true
+		}
+
+		if done {
+			return marshalItems(merged)
+		}
+		offset += len(items)
+	}
+
+	return nil, fmt.Errorf("%s has more than %d pages", path, maxPages)
+}
+
+// fetchPage issues a single GET request for target
+func fetchPage(ctx context.Context, client *http.Client, target string, authorization string) ([]byte, http.Header, error) {
+
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if authorization != "" {
+		// requests on behalf of clients don't spend our budget
+		req.Header.Set("Authorization", authorization)
+		ctx = withoutBudget(ctx)
+	} else {
+		authorizeUpstream(req)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &UpstreamStatusError{StatusCode: resp.StatusCode, Body: body}
+	}
+	return body, resp.Header, nil
+}
+
+// listItems returns the list held in body at field, or the body itself if field is empty.
+// Reports false if there is no list, in which case the body is a single object
+func listItems(body []byte, field string) ([]json.RawMessage, bool, error) {
+
+	list, err := lookupField(body, field)
+	if err != nil || list == nil {
+		return nil, false, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(list), []byte("[")) {
+		return nil, false, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(list, &items); err != nil {
+		return nil, false, err
+	}
+	return items, true, nil
+}
+
+// lookupField returns the value at dot separated path within json body, nil if it doesn't exist
+func lookupField(body []byte, path string) (json.RawMessage, error) {
+
+	value := json.RawMessage(body)
+	if path == "" {
+		return value, nil
+	}
+
+	for _, name := range strings.Split(path, ".") {
+
+		if !bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) {
+			return nil, nil
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return nil, err
+		}
+
+		var ok bool
+		if value, ok = object[name]; !ok {
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// cursorValue returns the cursor at dot separated path within json body, empty if there is none
+func cursorValue(body []byte, path string) (string, error) {
+
+	raw, err := lookupField(body, path)
+	if err != nil || raw == nil {
+		return "", err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+
+	switch cursor := value.(type) {
+	case string:
+		return cursor, nil
+	case float64:
+		return strconv.FormatFloat(cursor, 'f', -1, 64), nil
+	}
+	return "", nil
+}
+
+func marshalItems(items []json.RawMessage) ([]byte, error) {
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	return json.Marshal(items)
+}
+
+func setQuery(target *url.URL, name string, value string) {
+	query := target.Query()
+	query.Set(name, value)
+	target.RawQuery = query.Encode()
+}
+
+// ParseLinkHeader parses Link header into a map of relation to url
+func ParseLinkHeader(header string) map[string]string {
+
+	links := make(map[string]string)
+	for _, link := range strings.Split(header, ",") {
+
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+
+		target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "rel=") {
+				links[strings.Trim(strings.TrimPrefix(param, "rel="), `"`)] = target
+			}
+		}
+	}
+	return links
+}
+
+// pingUpstream checks whether the upstream target is reachable and healthy.
+// Issues request against configured health path and treats 5xx responses as failures.
+// Deliberately bypasses the breaker so that it reports the actual state of upstream
+func pingUpstream(ctx context.Context) error {
+
+	target := config.GetConfig().GetTargetBaseURL() + config.GetConfig().GetTargetHealthPath()
+
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return err
+	}
+	authorizeUpstream(req)
+
+	client := &http.Client{
+		Timeout: time.Duration(config.GetConfig().GetTargetTimeout()) * time.Second,
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+	return nil
+}
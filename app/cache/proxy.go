@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// hopByHopHeaders are meaningful only for a single connection and must not be forwarded
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// clientCredentialHeaders carry credentials of the client which must never reach upstream,
+// upstream only ever sees the token configured for the server unless passthrough is enabled
+var clientCredentialHeaders = []string{
+	"Authorization",
+	"Cookie",
+	APIKeyHeader,
+}
+
+// GenerateProxy builds the reverse proxy which forwards non cached requests to upstream target
+// through the breaker
+func GenerateProxy(breaker *CircuitBreaker) *httputil.ReverseProxy {
+
+	// get the configuration parameters about the upstream target
+	token := config.GetConfig().GetTargetToken()
+	if token == "" {
+		logging.Logger(context.Background()).Info("Token is not set")
+	}
+
+	return &httputil.ReverseProxy{
+		Director:       directUpstream,
+		Transport:      breaker.Transport(newUpstreamTransport()),
+		ModifyResponse: rewriteUpstreamURLs,
+	}
+}
+
+// newUpstreamTransport returns the pooled transport used for talking to upstream
+func newUpstreamTransport() *http.Transport {
+
+	proxyConf := config.GetConfig().GetProxyConfig()
+	timeout := time.Duration(config.GetConfig().GetTargetTimeout()) * time.Second
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: proxyConf.GetResponseHeaderTimeout(),
+		IdleConnTimeout:       proxyConf.GetIdleConnTimeout(),
+		MaxIdleConns:          proxyConf.GetMaxIdleConns(),
+		MaxIdleConnsPerHost:   proxyConf.GetMaxIdleConnsPerHost(),
+		MaxConnsPerHost:       proxyConf.GetMaxConnsPerHost(),
+	}
+}
+
+// directUpstream rewrites the incoming request to be sent to upstream target
+func directUpstream(req *http.Request) {
+
+	host := config.GetConfig().GetTargetUrl()
+
+	// remember where the client reached us so that links in response can point back at the cache
+	forwardedHost, forwardedProto := requestHost(req), requestScheme(req)
+	req.Header.Set("X-Forwarded-Host", forwardedHost)
+	req.Header.Set("X-Forwarded-Proto", forwardedProto)
+
+	clientAuth := passthroughAuthorization(req)
+
+	stripHeaders(req.Header)
+
+	if clientAuth != "" {
+		req.Header.Set("Authorization", clientAuth)
+	} else {
+		authorizeUpstream(req)
+	}
+
+	// let transport negotiate and transparently decompress the response so that it can be rewritten
+	req.Header.Del("Accept-Encoding")
+
+	req.Host = host
+	req.URL.Host = host
+	req.URL.Scheme = config.GetConfig().GetTargetScheme()
+	req.URL.Path = config.GetConfig().GetTargetBasePath() + req.URL.Path
+	if req.URL.RawPath != "" {
+		req.URL.RawPath = config.GetConfig().GetTargetBasePath() + req.URL.RawPath
+	}
+}
+
+// stripHeaders removes hop-by-hop headers, including those named in Connection header,
+// and credentials of the client
+func stripHeaders(header http.Header) {
+
+	for _, value := range header["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+
+	for _, name := range clientCredentialHeaders {
+		header.Del(name)
+	}
+
+	// header our token is presented in, in case upstream takes it elsewhere than Authorization
+	header.Del(config.GetConfig().GetUpstreamAuthConfig().GetHeader())
+}
+
+// forwardedHeader returns the value a proxy in front of us set for header, the one closest to the client
+// if proxies are chained. Empty unless such proxies are trusted, clients may send anything in it
+func forwardedHeader(req *http.Request, header string) string {
+	if !config.GetConfig().TrustsForwarded() {
+		return ""
+	}
+	return strings.TrimSpace(strings.Split(req.Header.Get(header), ",")[0])
+}
+
+// requestScheme returns the scheme the client used to reach us
+func requestScheme(req *http.Request) string {
+	if proto := forwardedHeader(req, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		return proto
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// requestHost returns the host the client used to reach us
+func requestHost(req *http.Request) string {
+	if host := forwardedHeader(req, "X-Forwarded-Host"); host != "" {
+		return host
+	}
+	return req.Host
+}
+
+// rewriteUpstreamURLs rewrites urls pointing at upstream in Link header and json body
+// so that clients following them keep going through the cache
+func rewriteUpstreamURLs(resp *http.Response) error {
+
+	requestBase := ""
+	if host := resp.Request.Header.Get("X-Forwarded-Host"); host != "" {
+		requestBase = resp.Request.Header.Get("X-Forwarded-Proto") + "://" + host
+	}
+
+	rewriter := ProxyRewriter(requestBase)
+	if rewriter == nil {
+		return nil
+	}
+
+	if link := resp.Header.Get("Link"); link != "" {
+		resp.Header.Set("Link", rewriter.RewriteLink(link))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		logging.Logger(resp.Request.Context()).Error("Error reading upstream response",
+													 zap.String("msg", err.Error()))
+		return err
+	}
+
+	body = rewriter.RewriteBody(body)
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// pathMatcher matches request path against a glob pattern or regular expression
+type pathMatcher struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func newPathMatcher(pattern string) (*pathMatcher, error) {
+
+	if !strings.HasPrefix(pattern, "regex:") {
+		return &pathMatcher{pattern: pattern}, nil
+	}
+
+	re, err := regexp.Compile(strings.TrimPrefix(pattern, "regex:"))
+	if err != nil {
+		return nil, err
+	}
+	return &pathMatcher{pattern: pattern, re: re}, nil
+}
+
+func (pm *pathMatcher) match(urlPath string) bool {
+	if pm.re != nil {
+		return pm.re.MatchString(urlPath)
+	}
+	matched, _ := path.Match(pm.pattern, urlPath)
+	return matched
+}
+
+// ProxyPolicy decides which requests may be proxied upstream with the server's token
+type ProxyPolicy struct {
+	methods      []string
+	allow        []*pathMatcher
+	deny         []*pathMatcher
+	maxBodyBytes int64
+}
+
+// NewProxyPolicy compiles the policy from config
+func NewProxyPolicy() (*ProxyPolicy, error) {
+
+	conf := config.GetConfig().GetProxyConfig().Policy
+
+	pp := &ProxyPolicy{
+		methods:      conf.GetMethods(),
+		maxBodyBytes: conf.GetMaxBodyBytes(),
+	}
+
+	for _, pattern := range conf.Allow {
+		matcher, err := newPathMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		pp.allow = append(pp.allow, matcher)
+	}
+
+	for _, pattern := range conf.Deny {
+		matcher, err := newPathMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		pp.deny = append(pp.deny, matcher)
+	}
+	return pp, nil
+}
+
+// Check returns the status to reject request with and why, 0 if request may be proxied
+func (pp *ProxyPolicy) Check(r *http.Request) (int, string) {
+
+	if !containsFold(pp.methods, r.Method) {
+		return 405, "method " + r.Method + " may not be proxied"
+	}
+
+	if r.ContentLength > pp.maxBodyBytes {
+		return 413, "request body larger than " + strconv.FormatInt(pp.maxBodyBytes, 10) + " bytes"
+	}
+
+	for _, matcher := range pp.deny {
+		if matcher.match(r.URL.Path) {
+			return 403, "path denied by " + matcher.pattern
+		}
+	}
+
+	if len(pp.allow) == 0 {
+		return 0, ""
+	}
+	for _, matcher := range pp.allow {
+		if matcher.match(r.URL.Path) {
+			return 0, ""
+		}
+	}
+	return 403, "path not allowed to be proxied"
+}
+
+// forward proxies request upstream if the policy allows it
+func (hh *Handlers) forward(w http.ResponseWriter, r *http.Request) {
+
+	if code, reason := hh.policy.Check(r); code != 0 {
+
+		logging.Logger(r.Context()).Warn("Proxy request rejected by policy",
+										 zap.String("method", r.Method),
+										 zap.String("path", r.URL.Path),
+										 zap.Int("code", code),
+										 zap.String("reason", reason))
+
+		if code == 405 {
+			w.Header().Set("Allow", strings.Join(hh.policy.methods, ", "))
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(reason))
+		return
+	}
+
+	// bodies without content length are only caught while being streamed upstream
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, hh.policy.maxBodyBytes)
+	}
+
+	hh.stub.ServeHTTP(w, r)
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// classes of routes which are limited and authorized separately
+const (
+	RouteCached  = "cached"
+	RouteViews   = "views"
+	RouteProxied = "proxied"
+	RouteAdmin   = "admin"
+)
+
+// RouteAuth names limits applied per client ip to every request ahead of authentication, so that
+// floods of invalid api keys are turned away before keys are looked up in redis
+const RouteAuth = "auth"
+
+// APIKeyHeader is the header clients identify themselves with
+const APIKeyHeader = "X-API-Key"
+
+// tokenStore holds token buckets, either in memory of this process or shared through redis
+type tokenStore interface {
+
+	// take takes a token out of bucket under key, returning whether one was available and tokens left
+	take(key string, limit config.Limit) (bool, float64, error)
+}
+
+// RateLimiter applies token bucket limits to inbound requests per client and route class
+type RateLimiter struct {
+	conf  config.RateLimitConfig
+	store tokenStore
+}
+
+// NewRateLimiter returns limiter configured from config, keeping buckets in redis through db if configured
+func NewRateLimiter(db *model.DBClient) *RateLimiter {
+
+	conf := config.GetConfig().GetRateLimitConfig()
+
+	var store tokenStore = newMemoryTokenStore()
+	if conf.GetStore() == config.RateLimitStoreRedis {
+		store = &redisTokenStore{db: db}
+	}
+
+	return &RateLimiter{conf: conf, store: store}
+}
+
+// Middleware rejects requests of clients which ran out of tokens for the route class with 429.
+// Every limited response carries X-RateLimit-* headers
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl.limit(w, r, classifyRoute(r), rl.clientID(r), next)
+	})
+}
+
+// AuthMiddleware limits requests per client ip under RouteAuth before they are authenticated, api keys
+// aren't known yet at this point. Routes which are never authenticated are left alone
+func (rl *RateLimiter) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.GetConfig().GetAuthConfig().Enabled || classifyRoute(r) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rl.limit(w, r, RouteAuth, rl.clientIP(r), next)
+	})
+}
+
+// limit takes a token for class out of the bucket of client, serving the request with next if there was one
+func (rl *RateLimiter) limit(w http.ResponseWriter, r *http.Request, class string, client string, next http.Handler) {
+
+	limit, limited := rl.conf.GetLimit(class)
+	if !rl.conf.Enabled || !limited {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	allowed, remaining, err := rl.store.take(class+":"+client, limit)
+	if err != nil {
+		// fail open, an unavailable store shouldn't take down the service
+		logging.Logger(r.Context()).Error("Error checking rate limit, letting request through",
+										  zap.String("msg", err.Error()))
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// time until bucket is full again
+	refill := time.Duration((float64(limit.Burst) - remaining) / limit.Rate * float64(time.Second))
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Floor(remaining))))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(refill).Unix(), 10))
+
+	if !allowed {
+		// time until next token is available
+		retryAfter := math.Ceil((1 - remaining) / limit.Rate)
+
+		logging.Logger(r.Context()).Warn("Rate limit exceeded",
+										 zap.String("client", client),
+										 zap.String("class", class))
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		w.WriteHeader(429)
+		w.Write([]byte("rate limit exceeded"))
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// Allow takes a token for class of route out of the bucket of the client authenticated with key, or
+// of the client at addr without one, for servers other than http. Once the client ran out of tokens it
+// returns false along with the time until the next one is available. Like Middleware it fails open
+func (rl *RateLimiter) Allow(ctx context.Context, class string, key *APIKey, addr net.Addr) (bool, time.Duration) {
+
+	limit, limited := rl.conf.GetLimit(class)
+	if !rl.conf.Enabled || !limited {
+		return true, 0
+	}
+
+	client := "key:"
+	if key != nil {
+		client += key.ID
+	} else {
+		client = "ip:" + addrHost(addr)
+	}
+
+	allowed, remaining, err := rl.store.take(class+":"+client, limit)
+	if err != nil {
+		logging.Logger(ctx).Error("Error checking rate limit, letting request through",
+								  zap.String("msg", err.Error()))
+		return true, 0
+	}
+	if allowed {
+		return true, 0
+	}
+
+	logging.Logger(ctx).Warn("Rate limit exceeded",
+							 zap.String("client", client),
+							 zap.String("class", class))
+
+	return false, time.Duration(math.Ceil((1-remaining)/limit.Rate)) * time.Second
+}
+
+// addrHost returns the host of addr without its port, empty if addr is unknown
+func addrHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// clientID identifies the client by the api key it was authenticated with, by its ip otherwise.
+// Unauthenticated headers are never trusted, so a client can't pick the bucket it draws from
+func (rl *RateLimiter) clientID(r *http.Request) string {
+
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return "key:" + key.ID
+	}
+	return rl.clientIP(r)
+}
+
+// clientIP identifies the client by its ip, taken from X-Forwarded-For when behind a trusted load balancer
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+
+	if rl.conf.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// classifyRoute returns the class of route the request is headed to, empty for health and metrics
+// routes which are never limited nor authenticated
+func classifyRoute(r *http.Request) string {
+
+	path := r.URL.Path
+
+	switch {
+	case path == "/healthcheck" || path == "/healthz" || path == "/readyz" || path == "/metrics":
+		return ""
+	case strings.HasPrefix(path, "/admin/"):
+		return RouteAdmin
+	case strings.HasPrefix(path, "/view/"):
+		return RouteViews
+	case isSearchPath(path) || isGraphQLPath(path):
+		// search and graphql answer from cached data whatever the method, views queried through graphql
+		// are checked for scope by their resolvers
+		return RouteCached
+	}
+
+	// writes to cached routes go upstream like any other proxied request
+	if _, _, ok := CachedRoutes().Match(r.URL); ok && isReadRequest(r) {
+		return RouteCached
+	}
+	return RouteProxied
+}
+
+// redisTokenStore keeps buckets in redis so that limits are shared between replicas
+type redisTokenStore struct {
+	db *model.DBClient
+}
+
+func (rs *redisTokenStore) take(key string, limit config.Limit) (bool, float64, error) {
+	return rs.db.TakeToken("ratelimit:"+key, limit.Rate, limit.Burst)
+}
+
+// maxIdleBuckets is the number of buckets after which full buckets are evicted from memory
+const maxIdleBuckets = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryTokenStore keeps buckets in memory of this process
+type memoryTokenStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (ms *memoryTokenStore) take(key string, limit config.Limit) (bool, float64, error) {
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+
+	bucket, ok := ms.buckets[key]
+	if !ok {
+		if len(ms.buckets) >= maxIdleBuckets {
+			ms.evict(now)
+		}
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		ms.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, bucket.tokens, nil
+	}
+
+	bucket.tokens -= 1
+	return true, bucket.tokens, nil
+}
+
+// evict drops buckets which have been idle long enough to be full again, those are
+// indistinguishable from a fresh bucket
+func (ms *memoryTokenStore) evict(now time.Time) {
+
+	limits := config.GetConfig().GetRateLimitConfig().Limits
+
+	for key, bucket := range ms.buckets {
+		class := strings.SplitN(key, ":", 2)[0]
+		limit := limits[class]
+
+		if limit.Rate <= 0 || bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(ms.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+func TestMemoryTokenStoreTake(t *testing.T) {
+
+	limit := config.Limit{Rate: 2, Burst: 3}
+
+	// steps run in order against the same bucket, idle backdates the bucket before taking
+	tests := []struct {
+		name      string
+		idle      time.Duration
+		allowed   bool
+		remaining float64
+	}{
+		{"new bucket starts full", 0, true, 2},
+		{"second token", 0, true, 1},
+		{"last token", 0, true, 0},
+		{"empty bucket", 0, false, 0},
+		{"refills at rate", time.Second, true, 1},
+		{"refill is capped at burst", time.Hour, true, 2},
+	}
+
+	ms := newMemoryTokenStore()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if bucket := ms.buckets["cached:ip:10.0.0.1"]; bucket != nil {
+				bucket.last = bucket.last.Add(-tt.idle)
+			}
+
+			allowed, remaining, err := ms.take("cached:ip:10.0.0.1", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// allow for time passing between steps
+			if allowed != tt.allowed || remaining < tt.remaining || remaining > tt.remaining+0.01 {
+				t.Errorf("take() = %v, %.3f, want %v, %.0f", allowed, remaining, tt.allowed, tt.remaining)
+			}
+		})
+	}
+
+	if allowed, _, _ := ms.take("cached:ip:10.0.0.2", limit); !allowed {
+		t.Error("take() of another client drew from the same bucket")
+	}
+}
+
+func TestMemoryTokenStoreEvict(t *testing.T) {
+
+	conf := &config.Config{}
+	conf.RateLimit.Limits = map[string]config.Limit{
+		RouteCached: {Rate: 1, Burst: 10},
+		RouteViews:  {Rate: 1, Burst: 10},
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	now := time.Now()
+	ms := newMemoryTokenStore()
+	ms.buckets = map[string]*tokenBucket{
+		"cached:ip:full":         {tokens: 10, last: now},
+		"cached:ip:refilled":     {tokens: 0, last: now.Add(-time.Minute)},
+		"views:key:draining":     {tokens: 2, last: now.Add(-time.Second)},
+		"proxied:ip:not-limited": {tokens: 0, last: now},
+	}
+
+	ms.evict(now)
+
+	for key, kept := range map[string]bool{
+		"cached:ip:full":         false,
+		"cached:ip:refilled":     false,
+		"views:key:draining":     true,
+		"proxied:ip:not-limited": false,
+	} {
+		if _, ok := ms.buckets[key]; ok != kept {
+			t.Errorf("bucket %s kept = %v, want %v", key, ok, kept)
+		}
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+
+	logging.InitLogger()
+
+	conf := &config.Config{}
+	conf.RateLimit.Enabled = true
+	conf.RateLimit.Limits = map[string]config.Limit{
+		RouteCached: {Rate: 0.5, Burst: 1},
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	rl := NewRateLimiter(nil)
+	first := &APIKey{ID: "first"}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}
+	otherPort := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 6000}
+
+	// calls run in order, drawing from the same buckets
+	tests := []struct {
+		name    string
+		class   string
+		key     *APIKey
+		addr    net.Addr
+		allowed bool
+		retry   time.Duration
+	}{
+		{"key", RouteCached, first, addr, true, 0},
+		{"key out of tokens", RouteCached, first, otherPort, false, 2 * time.Second},
+		{"another key", RouteCached, &APIKey{ID: "second"}, addr, true, 0},
+		{"ip without key", RouteCached, nil, addr, true, 0},
+		{"ip from another port", RouteCached, nil, otherPort, false, 2 * time.Second},
+		{"classes without limits", RouteViews, first, addr, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, retry := rl.Allow(context.Background(), tt.class, tt.key, tt.addr)
+			if allowed != tt.allowed || retry != tt.retry {
+				t.Errorf("Allow() = %v, %v, want %v, %v", allowed, retry, tt.allowed, tt.retry)
+			}
+		})
+	}
+}
+
+func TestClientID(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		trust     bool
+		key       *APIKey
+		forwarded string
+		want      string
+	}{
+		{"authenticated key", false, &APIKey{ID: "reader"}, "", "key:reader"},
+		{"key wins over forwarded address", true, &APIKey{ID: "reader"}, "10.1.1.1", "key:reader"},
+		{"remote address", false, nil, "", "ip:192.0.2.1"},
+		{"forwarded address is ignored unless trusted", false, nil, "10.1.1.1", "ip:192.0.2.1"},
+		{"first forwarded address when trusted", true, nil, "10.1.1.1, 10.2.2.2", "ip:10.1.1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rl := &RateLimiter{conf: config.RateLimitConfig{TrustForwardedFor: tt.trust}}
+
+			r := httptest.NewRequest("GET", "/orgs/Netflix", nil)
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.key != nil {
+				r = r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, tt.key))
+			}
+
+			if got := rl.clientID(r); got != tt.want {
+				t.Errorf("clientID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyRoute(t *testing.T) {
+
+	conf := &config.Config{}
+	conf.Orgs = []config.OrgConfig{{Name: "Netflix"}}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	// the table of cached routes is compiled once, make sure it is compiled from this config
+	cachedRoutesOnce.Do(func() {})
+	cachedRoutes = NewRouteTable()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   string
+	}{
+		{"health", "GET", "/healthz", ""},
+		{"metrics", "GET", "/metrics", ""},
+		{"admin", "GET", "/admin/keys", RouteAdmin},
+		{"views", "GET", "/view/top/5/stars", RouteViews},
+		{"search", "GET", "/search/repos?q=zuul", RouteCached},
+		{"graphql queries posted", "POST", "/graphql", RouteCached},
+		{"cached route", "GET", "/orgs/Netflix/repos", RouteCached},
+		{"writes to cached routes", "PATCH", "/orgs/Netflix", RouteProxied},
+		{"routes which are not cached", "GET", "/rate_limit", RouteProxied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if got := classifyRoute(r); got != tt.want {
+				t.Errorf("classifyRoute(%s %s) = %q, want %q", tt.method, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+
+	logging.InitLogger()
+
+	conf := &config.Config{}
+	conf.Auth.Enabled = true
+	conf.RateLimit.Enabled = true
+	conf.RateLimit.Limits = map[string]config.Limit{
+		RouteAuth: {Rate: 0.001, Burst: 2},
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	rl := NewRateLimiter(nil)
+
+	// stands in for authentication turning every request away
+	authenticated := 0
+	handler := rl.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated += 1
+		w.WriteHeader(401)
+	}))
+
+	// requests run in order, drawing from the same buckets
+	tests := []struct {
+		name          string
+		addr          string
+		target        string
+		code          int
+		authenticated int
+	}{
+		{"first invalid key", "192.0.2.1:5000", "/orgs/Netflix", 401, 1},
+		{"second invalid key", "192.0.2.1:5001", "/orgs/Netflix", 401, 2},
+		{"ip out of tokens is not authenticated", "192.0.2.1:5002", "/orgs/Netflix", 429, 2},
+		{"another ip", "192.0.2.2:5000", "/orgs/Netflix", 401, 3},
+		{"routes which are never authenticated", "192.0.2.1:5003", "/healthz", 401, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest("GET", tt.target, nil)
+			r.RemoteAddr = tt.addr
+			r.Header.Set(APIKeyHeader, "invalid")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("responded with %d, want %d", w.Code, tt.code)
+			}
+			if authenticated != tt.authenticated {
+				t.Errorf("authenticated %d requests so far, want %d", authenticated, tt.authenticated)
+			}
+		})
+	}
+}
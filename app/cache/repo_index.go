@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// Repositories are indexed in redis so that views never deserialize the repositories of a whole org.
+// Every repository is a hash holding its json and the metrics views rank by, every org has a set naming
+// its repositories and every view a sorted set of repository names scored by the metric it ranks by
+
+// repoKey returns the key of the hash holding repository named fullName. Github matches names case
+// insensitively, so they are lowercased for any spelling to find the same repository
+func repoKey(fullName string) string {
+	return model.InternalPrefix + "repo:" + strings.ToLower(fullName)
+}
+
+// repoURLKey returns the key the endpoint of a repository of org named fullName, or its sub-resource
+// under resource, is cached under. Like repoKey names of repositories are lowercased, the org is kept
+// as configured so that the key is matched by the config of the org
+func repoURLKey(org config.OrgConfig, fullName string, resource string) string {
+	return org.GetRepoURL(strings.ToLower(path.Base(fullName))) + resource
+}
+
+// cacheKey returns the key response of url is cached under, that of repoURLKey for endpoints of
+// repositories and url itself otherwise
+func cacheKey(url string) string {
+	for _, org := range config.GetConfig().GetOrgs() {
+		if repo, resource, ok := org.ParseRepoURL(url); ok {
+			return repoURLKey(org, repo, resource)
+		}
+	}
+	return url
+}
+
+// orgReposKey returns the key of the set naming every indexed repository of org
+func orgReposKey(org string) string {
+	return model.InternalPrefix + "repos:" + org
+}
+
+// viewIndexKey returns the key of the sorted set backing view of org, or view aggregated across orgs
+// if org is empty. Book-keeping of the view is recorded under its view key
+func viewIndexKey(view string, org string) string {
+	return model.InternalPrefix + ViewKey(view, org)
+}
+
+// viewScore returns the value of the metric view ranks repo by
+func viewScore(view string, repo *github.Repository) float64 {
+	switch view {
+	case ViewByForks:
+		return float64(repo.GetForksCount())
+	case ViewByLastUpdated:
+		return float64(repo.GetUpdatedAt().Unix())
+	case ViewByOpenIssues:
+		return float64(repo.GetOpenIssuesCount())
+	case ViewByStars:
+		return float64(repo.GetStargazersCount())
+	}
+	return 0
+}
+
+// formatScore renders the score of a repository or member in view the way it is served to clients
+func formatScore(view string, score float64) string {
+	switch view {
+	case ViewByLastUpdated, ViewMemberTimeline:
+		return time.Unix(int64(score), 0).UTC().Format(time.RFC3339)
+	case ViewMembersByAge:
+		return time.Unix(-int64(score), 0).UTC().Format(time.RFC3339)
+	case ViewMemberAdmins:
+		return strconv.FormatInt(-int64(score), 10)
+	}
+	return strconv.FormatInt(int64(score), 10)
+}
+
+// IndexRepos replaces the indexed repositories of org with repos and rebuilds the views of org from them.
+// The endpoint of every repository is served from its hash
+func (cc *Cacher) IndexRepos(org config.OrgConfig, repos []*github.Repository) error {
+
+	hashes := make(map[string]map[string]interface{}, len(repos))
+	names := make([]string, 0, len(repos))
+	scores := make(map[string][]model.ScoredMember, len(ViewKeys))
+	etags := make(map[string]string, len(repos))
+
+	for _, repo := range repos {
+
+		name := repo.GetFullName()
+		if name == "" {
+			continue
+		}
+
+		js, err := json.Marshal(repo)
+		if err != nil {
+			return err
+		}
+
+		data := StoreRewriter().RewriteBody(js)
+		fields := map[string]interface{}{
+			"org":  org.Name,
+			"data": data,
+		}
+		for _, view := range ViewKeys {
+			score := viewScore(view, repo)
+			fields[view] = score
+			scores[view] = append(scores[view], model.ScoredMember{Member: name, Score: score})
+		}
+
+		hashes[repoKey(name)] = fields
+		names = append(names, name)
+		etags[repoURLKey(org, name, "")] = model.ContentHash(data)
+	}
+
+	// repositories gone from org since the previous refresh
+	indexed := make(map[string]bool, len(names))
+	for _, name := range names {
+		indexed[name] = true
+	}
+
+	previous, err := cc.DBClient.SetMembers(orgReposKey(org.Name))
+	if err != nil {
+		return err
+	}
+
+	var stale, staleURLs []string
+	for _, name := range previous {
+		if indexed[name] {
+			continue
+		}
+		stale = append(stale, repoKey(name))
+
+		// endpoint of the repository along with its sub-resources
+		staleURLs = append(staleURLs, repoURLKey(org, name, ""))
+		for _, resource := range org.Repos.GetResourcePaths() {
+			staleURLs = append(staleURLs, repoURLKey(org, name, resource))
+		}
+	}
+
+	if err := cc.DBClient.ReplaceHashes(hashes, stale...); err != nil {
+		return err
+	}
+	if err := cc.DBClient.ReplaceSet(orgReposKey(org.Name), names); err != nil {
+		return err
+	}
+	if err := cc.DBClient.TouchMany(etags); err != nil {
+		return err
+	}
+	if _, err := cc.DBClient.Delete(staleURLs...); err != nil {
+		return err
+	}
+
+	for _, view := range ViewKeys {
+
+		// views used to be stored as json under their view key
+		if err := cc.DBClient.DeleteValues(ViewKey(view, org.Name)); err != nil {
+			return err
+		}
+		if err := cc.DBClient.ReplaceSortedSet(viewIndexKey(view, org.Name), scores[view]); err != nil {
+			return err
+		}
+		if err := cc.DBClient.Touch(ViewKey(view, org.Name), scoresHash(scores[view])); err != nil {
+			return err
+		}
+		cc.notify(ViewKey(view, org.Name))
+	}
+
+	logging.Logger(context.Background()).Info("Repositories indexed",
+											  zap.String("org", org.Name),
+											  zap.Int("repos", len(names)),
+											  zap.Int("removed", len(stale)))
+
+	return nil
+}
+
+// scoresHash returns the content hash of a view made of members
+func scoresHash(members []model.ScoredMember) string {
+
+	lines := make([]string, len(members))
+	for i, member := range members {
+		lines[i] = fmt.Sprintf("%s %v", member.Member, member.Score)
+	}
+	sort.Strings(lines)
+
+	return model.ContentHash([]byte(strings.Join(lines, "\n")))
+}
+
+// GetRepo returns the json of the repository named fullName, nil if it is not indexed
+func (cc *Cacher) GetRepo(fullName string) ([]byte, error) {
+	return cc.DBClient.HGet(repoKey(fullName), "data")
+}
+
+// IsRepoURLWarm reports whether url is an endpoint of a repository of an org whose repositories
+// have been indexed at least once
+func (cc *Cacher) IsRepoURLWarm(url string) bool {
+	for _, org := range config.GetConfig().GetOrgs() {
+		if _, _, ok := org.ParseRepoURL(url); ok {
+			return cc.IsWarm(org.GetReposURL())
+		}
+	}
+	return false
+}
+
+// IndexedRepos returns names of the indexed repositories of org
+func (cc *Cacher) IndexedRepos(org string) ([]string, error) {
+	return cc.DBClient.SetMembers(orgReposKey(org))
+}
+
+// CacheRepoResources caches the configured sub-resources of every indexed repository of org
+func (cc *Cacher) CacheRepoResources(org config.OrgConfig) {
+
+	cc.schedule(org.GetRepoResourcesJob(), func() error {
+		return cc.RefreshRepoResources(org)
+	})
+}
+
+// RefreshRepoResources fetches the configured sub-resources of every indexed repository of org once
+// and caches them. Resources upstream has nothing for, such as commits of an empty repository, are
+// skipped. Returns the first error encountered after trying every resource
+func (cc *Cacher) RefreshRepoResources(org config.OrgConfig) error {
+
+	paths := org.Repos.GetResourcePaths()
+	if len(paths) == 0 {
+		return nil
+	}
+
+	names, err := cc.IndexedRepos(org.Name)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, name := range names {
+		for _, path := range paths {
+
+			url := "/repos/" + name + path
+			resp, err := cc.Provider.Fetch(context.Background(), url, "")
+
+			if statusErr, ok := err.(*UpstreamStatusError); ok && statusErr.StatusCode < 500 {
+				logging.Logger(context.Background()).Info("Skipping repository resource",
+														  zap.String("url", url),
+														  zap.Int("status", statusErr.StatusCode))
+				continue
+			}
+			if err != nil {
+				logging.Logger(context.Background()).Error("Error fetching repository resource",
+														   zap.String("url", url),
+														   zap.String("msg", err.Error()))
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+
+			// statistics such as contributors are computed upstream on demand and come back empty at first
+			if len(resp) == 0 {
+				continue
+			}
+			cc.store(repoURLKey(org, name, path), resp)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// URLRewriter rewrites upstream base urls in payloads and Link headers to point at the cache,
+// so that clients following hypermedia links keep going through the cache
+type URLRewriter struct {
+	upstreamBase string
+	cacheBase    string
+}
+
+// NewURLRewriter returns rewriter pointing upstream urls at cacheBase
+func NewURLRewriter(cacheBase string) *URLRewriter {
+	return &URLRewriter{
+		upstreamBase: config.GetConfig().GetTargetBaseURL(),
+		cacheBase:    cacheBase,
+	}
+}
+
+// StoreRewriter returns the rewriter applied to payloads before caching them,
+// nil if cached payloads are to be stored as received from upstream
+func StoreRewriter() *URLRewriter {
+	if config.GetConfig().GetRewriteURLs() != config.RewriteAll {
+		return nil
+	}
+	return NewURLRewriter(config.GetConfig().GetExternalURL())
+}
+
+// ProxyRewriter returns the rewriter applied to proxied responses, nil if they are to be passed through.
+// Falls back to requestBase when no external url is configured
+func ProxyRewriter(requestBase string) *URLRewriter {
+	if config.GetConfig().GetRewriteURLs() == config.RewriteOff {
+		return nil
+	}
+	if external := config.GetConfig().GetExternalURL(); external != "" {
+		return NewURLRewriter(external)
+	}
+	return NewURLRewriter(requestBase)
+}
+
+// RewriteBody rewrites upstream urls in body. Safe to call on nil rewriter
+func (ur *URLRewriter) RewriteBody(body []byte) []byte {
+	if ur == nil || ur.cacheBase == "" {
+		return body
+	}
+	return bytes.Replace(body, []byte(ur.upstreamBase), []byte(ur.cacheBase), -1)
+}
+
+// RewriteLink rewrites upstream urls in Link header value. Safe to call on nil rewriter
+func (ur *URLRewriter) RewriteLink(link string) string {
+	if ur == nil || ur.cacheBase == "" {
+		return link
+	}
+	return strings.Replace(link, ur.upstreamBase, ur.cacheBase, -1)
+}
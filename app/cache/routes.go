@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// Route is a definition of paths served from cache
+type Route struct {
+	Pattern string
+
+	// ReadThrough routes are fetched from upstream when first requested and whenever they are older
+	// than their refresh interval, instead of being kept warm by refresh jobs
+	ReadThrough bool
+
+	// Params lists query parameters applied to the cached response rather than being part of its key
+	Params []string
+}
+
+// RouteTable matches requests against cached routes. Exact paths are looked up in a map, templates
+// and globs by walking a trie of path segments, and regular expressions are tried last
+type RouteTable struct {
+	exact   map[string]*Route
+	root    *routeNode
+	regexes []*regexRoute
+
+	// ignoredQuery holds query parameters dropped when normalizing query strings
+	ignoredQuery map[string]bool
+}
+
+// routeNode is a node of the trie, reached by matching a path segment against its parent
+type routeNode struct {
+	literals map[string]*routeNode
+
+	// param matches any single segment, {name} in templates
+	param *routeNode
+
+	// globs match a single segment with path.Match
+	globs []*globNode
+
+	// rest is the route of a trailing ** segment, matching one or more remaining segments
+	rest *Route
+
+	route *Route
+}
+
+type globNode struct {
+	pattern string
+	node    *routeNode
+}
+
+type regexRoute struct {
+	re    *regexp.Regexp
+	route *Route
+}
+
+var (
+	cachedRoutes     *RouteTable
+	cachedRoutesOnce sync.Once
+)
+
+// CachedRoutes returns the table of cached routes compiled from config
+func CachedRoutes() *RouteTable {
+	cachedRoutesOnce.Do(func() {
+		cachedRoutes = NewRouteTable()
+	})
+	return cachedRoutes
+}
+
+// NewRouteTable compiles cached routes from config. Endpoints configured as is and endpoints of
+// repositories are kept warm by refresh jobs, patterns under cache.routes are read through
+func NewRouteTable() *RouteTable {
+
+	rt := &RouteTable{
+		exact:        make(map[string]*Route),
+		root:         &routeNode{},
+		ignoredQuery: make(map[string]bool),
+	}
+
+	for _, param := range config.GetConfig().GetIgnoredQueryParams() {
+		rt.ignoredQuery[param] = true
+	}
+
+	listParams := make(map[string][]string)
+	for _, org := range config.GetConfig().GetOrgs() {
+		listParams[org.GetReposURL()] = repoListParams
+	}
+
+	for _, url := range config.GetConfig().GetCachedURLs() {
+		rt.add(&Route{Pattern: url, Params: listParams[url]})
+	}
+	for _, template := range config.GetConfig().GetRepoRoutes() {
+		rt.add(&Route{Pattern: template})
+	}
+	for _, pattern := range config.GetConfig().GetCacheConfig().Routes {
+		rt.add(&Route{Pattern: pattern, ReadThrough: true})
+	}
+	return rt
+}
+
+// add inserts route into the table, routes added first win over later ones matching the same paths
+func (rt *RouteTable) add(route *Route) {
+
+	if strings.HasPrefix(route.Pattern, "regex:") {
+		re, err := regexp.Compile(strings.TrimPrefix(route.Pattern, "regex:"))
+		if err != nil {
+			// the server refuses to start with these, so only tooling which skips validation gets here
+			logging.Logger(context.Background()).Error("Skipping invalid cached route",
+													   zap.String("pattern", route.Pattern),
+													   zap.String("msg", err.Error()))
+			return
+		}
+		rt.regexes = append(rt.regexes, &regexRoute{re: re, route: route})
+		return
+	}
+
+	if !isPattern(route.Pattern) {
+		if _, ok := rt.exact[route.Pattern]; !ok {
+			rt.exact[route.Pattern] = route
+		}
+		return
+	}
+
+	node := rt.root
+	segments := splitPath(route.Pattern)
+	for i, segment := range segments {
+
+		switch {
+		case segment == "**" && i == len(segments)-1:
+			if node.rest == nil {
+				node.rest = route
+			}
+			return
+
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			if node.param == nil {
+				node.param = &routeNode{}
+			}
+			node = node.param
+
+		case strings.ContainsAny(segment, "*?["):
+			var next *routeNode
+			for _, glob := range node.globs {
+				if glob.pattern == segment {
+					next = glob.node
+				}
+			}
+			if next == nil {
+				next = &routeNode{}
+				node.globs = append(node.globs, &globNode{pattern: segment, node: next})
+			}
+			node = next
+
+		default:
+			if node.literals == nil {
+				node.literals = make(map[string]*routeNode)
+			}
+			if node.literals[segment] == nil {
+				node.literals[segment] = &routeNode{}
+			}
+			node = node.literals[segment]
+		}
+	}
+
+	if node.route == nil {
+		node.route = route
+	}
+}
+
+// Match returns the route serving u from cache along with the key its response is cached under, see cacheKey.
+// Routes kept warm by refresh jobs only cache responses without query parameters, so they don't
+// match requests whose normalized query is not empty
+func (rt *RouteTable) Match(u *url.URL) (*Route, string, bool) {
+
+	route := rt.match(u.Path)
+	if route == nil {
+		return nil, "", false
+	}
+
+	key := cacheKey(u.Path)
+	query := rt.normalizeQuery(u.Query(), route)
+	if query == "" {
+		return route, key, true
+	}
+	if !route.ReadThrough {
+		return nil, "", false
+	}
+	return route, key + "?" + query, true
+}
+
+// UpstreamPath returns the path and query to fetch from upstream for u matching route. The path is
+// kept as requested, unlike in keys, and the query is normalized the same way as in keys since
+// responses are cached whole with every page
+func (rt *RouteTable) UpstreamPath(u *url.URL, route *Route) string {
+
+	if query := rt.normalizeQuery(u.Query(), route); query != "" {
+		return u.Path + "?" + query
+	}
+	return u.Path
+}
+
+func (rt *RouteTable) match(urlPath string) *Route {
+
+	if route, ok := rt.exact[urlPath]; ok {
+		return route
+	}
+
+	if route := rt.root.match(splitPath(urlPath)); route != nil {
+		return route
+	}
+
+	for _, regex := range rt.regexes {
+		if regex.re.MatchString(urlPath) {
+			return regex.route
+		}
+	}
+	return nil
+}
+
+// match walks the trie preferring literal segments over parameters, parameters over globs and
+// globs over trailing wildcards, backtracking when a branch doesn't lead to a route
+func (rn *routeNode) match(segments []string) *Route {
+
+	if len(segments) == 0 {
+		return rn.route
+	}
+
+	segment, rest := segments[0], segments[1:]
+
+	if next, ok := rn.literals[segment]; ok {
+		if route := next.match(rest); route != nil {
+			return route
+		}
+	}
+
+	if rn.param != nil && segment != "" {
+		if route := rn.param.match(rest); route != nil {
+			return route
+		}
+	}
+
+	for _, glob := range rn.globs {
+		if matched, _ := path.Match(glob.pattern, segment); matched {
+			if route := glob.node.match(rest); route != nil {
+				return route
+			}
+		}
+	}
+	return rn.rest
+}
+
+// normalizeQuery drops ignored parameters and those route applies itself from query and encodes
+// the rest sorted by name, so that equivalent requests share a key
+func (rt *RouteTable) normalizeQuery(query url.Values, route *Route) string {
+
+	for name := range query {
+		if rt.ignoredQuery[name] {
+			delete(query, name)
+		}
+	}
+	for _, name := range route.Params {
+		delete(query, name)
+	}
+
+	for _, values := range query {
+		sort.Strings(values)
+	}
+
+	// Encode sorts by name
+	return query.Encode()
+}
+
+// isPattern reports whether route pattern matches more than a single path
+func isPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "{*?[")
+}
+
+func splitPath(urlPath string) []string {
+	return strings.Split(strings.TrimPrefix(urlPath, "/"), "/")
+}
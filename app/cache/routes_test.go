@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+func TestRouteTableMatch(t *testing.T) {
+
+	logging.InitLogger()
+
+	conf := &config.Config{}
+	conf.Orgs = []config.OrgConfig{
+		{Name: "Netflix", Repos: config.RepoConfig{Resources: []string{config.RepoLanguages}}},
+	}
+	conf.Cache.Routes = []string{
+		"/users/{user}/repos",
+		"/gists/*.json",
+		"/teams/**",
+		"regex:^/emojis/[a-z]+$",
+		"/users/{user}/orgs",
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	rt := NewRouteTable()
+
+	tests := []struct {
+		name        string
+		url         string
+		pattern     string
+		key         string
+		readThrough bool
+		ok          bool
+	}{
+		{"root endpoint", "/", "/", "/", false, true},
+		{"exact endpoint of org", "/orgs/Netflix/repos", "/orgs/Netflix/repos", "/orgs/Netflix/repos", false, true},
+		{"list parameters are left out of key", "/orgs/Netflix/repos?sort=updated&type=public",
+			"/orgs/Netflix/repos", "/orgs/Netflix/repos", false, true},
+		{"paging parameters are ignored", "/orgs/Netflix/members?page=2&per_page=5",
+			"/orgs/Netflix/members", "/orgs/Netflix/members", false, true},
+		{"refreshed routes are not served with other parameters", "/orgs/Netflix?since=1", "", "", false, false},
+		{"repository template", "/repos/Netflix/zuul", "/repos/Netflix/{repo}", "/repos/Netflix/zuul", false, true},
+		{"names of repositories are lowercased in keys", "/repos/Netflix/Zuul", "/repos/Netflix/{repo}",
+			"/repos/Netflix/zuul", false, true},
+		{"sub-resource template", "/repos/Netflix/zuul/languages", "/repos/Netflix/{repo}/languages",
+			"/repos/Netflix/zuul/languages", false, true},
+		{"sub-resource not cached", "/repos/Netflix/zuul/topics", "", "", false, false},
+		{"parameters don't match empty segments", "/users//repos", "", "", false, false},
+		{"read through template", "/users/octocat/repos", "/users/{user}/repos", "/users/octocat/repos", true, true},
+		{"read through keys keep normalized query", "/users/octocat/repos?type=owner&sort=created&page=3",
+			"/users/{user}/repos", "/users/octocat/repos?sort=created&type=owner", true, true},
+		{"templates sharing a parameter", "/users/octocat/orgs", "/users/{user}/orgs",
+			"/users/octocat/orgs", true, true},
+		{"glob", "/gists/abc.json", "/gists/*.json", "/gists/abc.json", true, true},
+		{"glob not matching", "/gists/abc.xml", "", "", false, false},
+		{"trailing wildcard matches several segments", "/teams/1/members/2", "/teams/**",
+			"/teams/1/members/2", true, true},
+		{"trailing wildcard needs a segment", "/teams", "", "", false, false},
+		{"regular expression", "/emojis/smile", "regex:^/emojis/[a-z]+$", "/emojis/smile", true, true},
+		{"regular expression not matching", "/emojis/Smile", "", "", false, false},
+		{"unknown path", "/rate_limit", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			route, key, ok := rt.Match(u)
+			if ok != tt.ok {
+				t.Fatalf("Match(%q) matched = %v, want %v", tt.url, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if route.Pattern != tt.pattern || route.ReadThrough != tt.readThrough {
+				t.Errorf("Match(%q) route = %q read through %v, want %q read through %v",
+					tt.url, route.Pattern, route.ReadThrough, tt.pattern, tt.readThrough)
+			}
+			if key != tt.key {
+				t.Errorf("Match(%q) key = %q, want %q", tt.url, key, tt.key)
+			}
+		})
+	}
+}
+
+func TestRouteTableUpstreamPath(t *testing.T) {
+
+	conf := &config.Config{}
+	conf.Orgs = []config.OrgConfig{{Name: "Netflix"}}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	rt := NewRouteTable()
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"path is kept as requested", "/repos/Netflix/Zuul", "/repos/Netflix/Zuul"},
+		{"list and paging parameters are left out", "/orgs/Netflix/repos?type=public&page=2", "/orgs/Netflix/repos"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			route, _, ok := rt.Match(u)
+			if !ok {
+				t.Fatalf("Match(%q) found no route", tt.url)
+			}
+			if got := rt.UpstreamPath(u, route); got != tt.want {
+				t.Errorf("UpstreamPath(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
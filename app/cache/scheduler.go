@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+)
+
+// JobStatus is a point in time snapshot of a refresh job's state
+type JobStatus struct {
+	Name        string    `json:"name"`
+	Interval    string    `json:"interval"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+	LastRun     time.Time `json:"last_run"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	Paused      bool      `json:"paused"`
+
+	// FirstRun is when the first scheduled run is due, zero for jobs only run on demand
+	FirstRun time.Time `json:"first_run,omitempty"`
+
+	interval time.Duration
+}
+
+// ErrUnknownJob is returned when operating on a job which was never registered
+var ErrUnknownJob = errors.New("no such job registered")
+
+// job is a single refresh routine registered with the scheduler
+type job struct {
+	run func() error
+
+	// runMu serializes runs so that a manual trigger doesn't overlap with the ticker,
+	// mu guards status which is read while a run may be in progress
+	runMu  sync.Mutex
+	mu     sync.Mutex
+	status JobStatus
+}
+
+// Scheduler keeps track of all refresh jobs and the outcome of their runs
+type Scheduler struct {
+	mu   sync.RWMutex
+	jobs map[string]*job
+
+	// order in which jobs were registered, used for stable listing
+	names []string
+}
+
+// NewScheduler returns an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs: make(map[string]*job),
+	}
+}
+
+// Register adds the job under name without scheduling it. Such jobs are run
+// on demand through Run or Trigger
+func (ss *Scheduler) Register(name string, run func() error) {
+	ss.register(name, 0, 0, run)
+}
+
+// Schedule registers the job under name and runs it at every interval. Blocks forever,
+// so callers are expected to invoke it in its own go routine
+func (ss *Scheduler) Schedule(name string, interval time.Duration, run func() error) {
+	ss.ScheduleAfter(name, 0, interval, run)
+}
+
+// ScheduleAfter is like Schedule, except that the job first runs once delay passed. It is registered
+// right away, so it may be triggered before that
+func (ss *Scheduler) ScheduleAfter(name string, delay time.Duration, interval time.Duration, run func() error) {
+
+	ss.register(name, delay, interval, run)
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	// ticker goes of at fixed intervals
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		ss.Run(name)
+	}
+}
+
+func (ss *Scheduler) register(name string, delay time.Duration, interval time.Duration, run func() error) {
+
+	jj := &job{
+		run: run,
+		status: JobStatus{Name: name},
+	}
+
+	if interval > 0 {
+		jj.status.Interval = interval.String()
+		jj.status.FirstRun = time.Now().UTC().Add(delay)
+		jj.status.interval = interval
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if old, ok := ss.jobs[name]; ok {
+		// keep the paused state across re-registration
+		jj.status.Paused = old.isPaused()
+	} else {
+		ss.names = append(ss.names, name)
+	}
+	ss.jobs[name] = jj
+}
+
+func (ss *Scheduler) get(name string) (*job, error) {
+
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	jj, ok := ss.jobs[name]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return jj, nil
+}
+
+// Run runs the job as part of its regular schedule. Paused jobs are skipped
+func (ss *Scheduler) Run(name string) error {
+
+	jj, err := ss.get(name)
+	if err != nil {
+		return err
+	}
+
+	if jj.isPaused() {
+		logging.Logger(context.Background()).Debug("Skipping paused job", zap.String("job", name))
+		return nil
+	}
+	return jj.execute()
+}
+
+// Trigger runs the job immediately regardless of it being paused and waits for it to finish
+func (ss *Scheduler) Trigger(name string) error {
+
+	jj, err := ss.get(name)
+	if err != nil {
+		return err
+	}
+	return jj.execute()
+}
+
+// Pause stops the job from running on its schedule until resumed
+func (ss *Scheduler) Pause(name string) error {
+	return ss.setPaused(name, true)
+}
+
+// Resume lets a paused job run on its schedule again
+func (ss *Scheduler) Resume(name string) error {
+	return ss.setPaused(name, false)
+}
+
+func (ss *Scheduler) setPaused(name string, paused bool) error {
+
+	jj, err := ss.get(name)
+	if err != nil {
+		return err
+	}
+
+	jj.mu.Lock()
+	jj.status.Paused = paused
+	jj.mu.Unlock()
+
+	logging.Logger(context.Background()).Info("Job pause state changed",
+											  zap.String("job", name),
+											  zap.Bool("paused", paused))
+	return nil
+}
+
+func (jj *job) isPaused() bool {
+	jj.mu.Lock()
+	defer jj.mu.Unlock()
+	return jj.status.Paused
+}
+
+// execute runs the job once and records its outcome
+func (jj *job) execute() error {
+
+	jj.runMu.Lock()
+	defer jj.runMu.Unlock()
+
+	err := jj.run()
+
+	jj.mu.Lock()
+	defer jj.mu.Unlock()
+
+	jj.status.Runs += 1
+	jj.status.LastRun = time.Now().UTC()
+
+	if err != nil {
+		jj.status.Failures += 1
+		jj.status.LastError = err.Error()
+
+		logging.Logger(context.Background()).Error("Refresh job failed",
+												   zap.String("job", jj.status.Name),
+												   zap.String("msg", err.Error()))
+		return err
+	}
+
+	jj.status.LastError = ""
+	jj.status.LastSuccess = jj.status.LastRun
+	return nil
+}
+
+// Status returns the state of all registered jobs in registration order
+func (ss *Scheduler) Status() []JobStatus {
+
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	result := make([]JobStatus, 0, len(ss.names))
+	for _, name := range ss.names {
+		jj := ss.jobs[name]
+
+		jj.mu.Lock()
+		result = append(result, jj.status)
+		jj.mu.Unlock()
+	}
+	return result
+}
@@ -0,0 +1,884 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// SearchJob is the name under which the job rebuilding the search index is registered
+const SearchJob = "search"
+
+// kinds of documents the search index holds
+const (
+	SearchRepos   = "repos"
+	SearchMembers = "members"
+)
+
+// errSearchNotBuilt is returned when searching before the index has been built
+var errSearchNotBuilt = errors.New("search index is not built yet")
+
+// SearchQueryError reports a search query the index can't answer
+type SearchQueryError struct {
+	Msg string
+}
+
+func (e *SearchQueryError) Error() string {
+	return e.Msg
+}
+
+// SearchIndex is an in-memory index of cached repositories and members of every org. It is built
+// from data held in redis, so searching never costs an upstream request
+type SearchIndex struct {
+	mu      sync.RWMutex
+	repos   *repoCorpus
+	members *memberCorpus
+	builtAt time.Time
+}
+
+// NewSearchIndex returns an index which is empty until built
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{}
+}
+
+// BuiltAt returns the time index was last built, zero if it never was
+func (si *SearchIndex) BuiltAt() time.Time {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+	return si.builtAt
+}
+
+// repoDoc holds fields of a repository searched over, text fields lowercased
+type repoDoc struct {
+	data json.RawMessage
+
+	org         string
+	name        string
+	fullName    string
+	description string
+	language    string
+	topics      []string
+	stars       float64
+	forks       float64
+	size        float64
+	created     float64
+	pushed      float64
+	archived    bool
+	fork        bool
+	private     bool
+}
+
+// repoSearchFields are fields of a cached repository the index is built from
+type repoSearchFields struct {
+	Name            string    `json:"name"`
+	FullName        string    `json:"full_name"`
+	Description     string    `json:"description"`
+	Language        string    `json:"language"`
+	Topics          []string  `json:"topics"`
+	StargazersCount int       `json:"stargazers_count"`
+	ForksCount      int       `json:"forks_count"`
+	Size            int       `json:"size"`
+	CreatedAt       time.Time `json:"created_at"`
+	PushedAt        time.Time `json:"pushed_at"`
+	Archived        bool      `json:"archived"`
+	Fork            bool      `json:"fork"`
+	Private         bool      `json:"private"`
+}
+
+// memberDoc holds fields of a member searched over. Members of several orgs are indexed once
+type memberDoc struct {
+	data json.RawMessage
+
+	login string
+	orgs  []string
+	kind  string
+	admin bool
+}
+
+// memberSearchFields are fields of a cached member the index is built from
+type memberSearchFields struct {
+	Login     string `json:"login"`
+	Type      string `json:"type"`
+	SiteAdmin bool   `json:"site_admin"`
+}
+
+type repoCorpus struct {
+	docs         []*repoDoc
+	names        *textIndex
+	descriptions *textIndex
+}
+
+type memberCorpus struct {
+	docs   []*memberDoc
+	logins *textIndex
+}
+
+// textIndex maps words to the documents containing them. Words are kept sorted so that every word
+// starting with a term is found with a binary search
+type textIndex struct {
+	words    []string
+	postings map[string][]int
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{postings: make(map[string][]int)}
+}
+
+// add indexes words of text as found in document doc. Documents are expected in increasing order
+func (ti *textIndex) add(doc int, text string) {
+	for _, word := range tokenize(text) {
+		docs, ok := ti.postings[word]
+		if !ok {
+			ti.words = append(ti.words, word)
+		}
+		if len(docs) > 0 && docs[len(docs)-1] == doc {
+			continue
+		}
+		ti.postings[word] = append(docs, doc)
+	}
+}
+
+func (ti *textIndex) finish() {
+	sort.Strings(ti.words)
+}
+
+// match returns documents holding a word starting with term, weighing whole word matches 1 and
+// prefix matches half of that
+func (ti *textIndex) match(term string) map[int]float64 {
+
+	hits := make(map[int]float64)
+	for i := sort.SearchStrings(ti.words, term); i < len(ti.words) && strings.HasPrefix(ti.words[i], term); i++ {
+
+		weight := 0.5
+		if ti.words[i] == term {
+			weight = 1
+		}
+		for _, doc := range ti.postings[ti.words[i]] {
+			if weight > hits[doc] {
+				hits[doc] = weight
+			}
+		}
+	}
+	return hits
+}
+
+// tokenize splits text into lowercased words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// BuildSearchIndex rebuilds the search index from the repositories and members of every org currently cached.
+// Orgs which are not warm yet are left out
+func (cc *Cacher) BuildSearchIndex() error {
+
+	repos := &repoCorpus{names: newTextIndex(), descriptions: newTextIndex()}
+	members := &memberCorpus{logins: newTextIndex()}
+	memberIndex := make(map[string]*memberDoc)
+
+	for _, org := range config.GetConfig().GetOrgs() {
+
+		orgName := strings.ToLower(org.Name)
+
+		var items []json.RawMessage
+		if data := cc.GetCachedEndpoint(org.GetReposURL()); len(data) > 0 {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range items {
+
+			var fields repoSearchFields
+			if err := json.Unmarshal(item, &fields); err != nil {
+				return err
+			}
+
+			topics := make([]string, len(fields.Topics))
+			for i, topic := range fields.Topics {
+				topics[i] = strings.ToLower(topic)
+			}
+
+			doc := len(repos.docs)
+			repos.docs = append(repos.docs, &repoDoc{
+				data:        item,
+				org:         orgName,
+				name:        strings.ToLower(fields.Name),
+				fullName:    strings.ToLower(fields.FullName),
+				description: strings.ToLower(fields.Description),
+				language:    strings.ToLower(fields.Language),
+				topics:      topics,
+				stars:       float64(fields.StargazersCount),
+				forks:       float64(fields.ForksCount),
+				size:        float64(fields.Size),
+				created:     float64(fields.CreatedAt.Unix()),
+				pushed:      float64(fields.PushedAt.Unix()),
+				archived:    fields.Archived,
+				fork:        fields.Fork,
+				private:     fields.Private,
+			})
+			repos.names.add(doc, fields.Name)
+			repos.descriptions.add(doc, fields.Description)
+		}
+
+		items = nil
+		if data := cc.GetCachedEndpoint(org.GetMembersURL()); len(data) > 0 {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range items {
+
+			var fields memberSearchFields
+			if err := json.Unmarshal(item, &fields); err != nil {
+				return err
+			}
+
+			login := strings.ToLower(fields.Login)
+			if member, ok := memberIndex[login]; ok {
+				member.orgs = append(member.orgs, orgName)
+				continue
+			}
+
+			member := &memberDoc{
+				data:  item,
+				login: login,
+				orgs:  []string{orgName},
+				kind:  strings.ToLower(fields.Type),
+				admin: fields.SiteAdmin,
+			}
+			memberIndex[login] = member
+			members.logins.add(len(members.docs), fields.Login)
+			members.docs = append(members.docs, member)
+		}
+	}
+
+	repos.names.finish()
+	repos.descriptions.finish()
+	members.logins.finish()
+
+	si := cc.Search
+	si.mu.Lock()
+	si.repos, si.members, si.builtAt = repos, members, time.Now()
+	si.mu.Unlock()
+
+	logging.Logger(context.Background()).Info("Search index built",
+											  zap.Int("repos", len(repos.docs)),
+											  zap.Int("members", len(members.docs)))
+
+	return nil
+}
+
+// searchQuery is a parsed search query, free text terms along with qualifiers narrowing results
+type searchQuery struct {
+	terms    []string
+	excluded []string
+	phrase   string
+
+	qualifiers []qualifier
+}
+
+type qualifier struct {
+	key    string
+	value  string
+	negate bool
+}
+
+// parseSearchQuery splits q into words, keeping quoted phrases together. Words of the form key:value
+// are qualifiers, negated when prefixed with -, and the rest are matched against text
+func parseSearchQuery(q string) *searchQuery {
+
+	query := &searchQuery{}
+	var text []string
+
+	for _, word := range splitQuery(q) {
+
+		negate := strings.HasPrefix(word, "-") && len(word) > 1
+		if negate {
+			word = word[1:]
+		}
+
+		if i := strings.Index(word, ":"); i > 0 {
+			query.qualifiers = append(query.qualifiers, qualifier{
+				key:    strings.ToLower(word[:i]),
+				value:  strings.ToLower(strings.Trim(word[i+1:], `"`)),
+				negate: negate,
+			})
+			continue
+		}
+
+		if negate {
+			query.excluded = append(query.excluded, tokenize(word)...)
+			continue
+		}
+		query.terms = append(query.terms, tokenize(word)...)
+		text = append(text, strings.ToLower(strings.Trim(word, `"`)))
+	}
+
+	query.phrase = strings.Join(text, " ")
+	return query
+}
+
+// splitQuery splits q on white space outside of double quotes
+func splitQuery(q string) []string {
+
+	var words []string
+	var word strings.Builder
+	quoted := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// textScores returns documents matching every term of query in one of fields, scored by how well
+// they match. Matches in fields listed first weigh more. Every document matches a query without terms
+func textScores(query *searchQuery, total int, fields []*textIndex, weights []float64) map[int]float64 {
+
+	scores := make(map[int]float64)
+	if len(query.terms) == 0 {
+		for doc := 0; doc < total; doc++ {
+			scores[doc] = 0
+		}
+	}
+
+	for i, term := range query.terms {
+
+		termScores := make(map[int]float64)
+		for f, field := range fields {
+			for doc, weight := range field.match(term) {
+				termScores[doc] += weight * weights[f]
+			}
+		}
+
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		for doc := range scores {
+			if score, ok := termScores[doc]; ok {
+				scores[doc] += score
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	for _, term := range query.excluded {
+		for _, field := range fields {
+			for doc := range field.match(term) {
+				delete(scores, doc)
+			}
+		}
+	}
+	return scores
+}
+
+// searchField is a text field of documents, matches in which weigh weight
+type searchField struct {
+	name   string
+	index  *textIndex
+	weight float64
+}
+
+// searchFields returns the fields text is matched against, narrowed by the in qualifier if given
+func searchFields(query *searchQuery, all []searchField) ([]*textIndex, []float64, error) {
+
+	var in []string
+	for _, q := range query.qualifiers {
+		if q.key == "in" && !q.negate {
+			in = append(in, strings.Split(q.value, ",")...)
+		}
+	}
+
+	var names []string
+	var fields []*textIndex
+	var weights []float64
+	for _, field := range all {
+		names = append(names, field.name)
+		if len(in) > 0 && !containsString(in, field.name) {
+			continue
+		}
+		fields = append(fields, field.index)
+		weights = append(weights, field.weight)
+	}
+
+	for _, wanted := range in {
+		if !containsString(names, wanted) {
+			return nil, nil, &SearchQueryError{Msg: "in qualifier must be one of " + strings.Join(names, ", ")}
+		}
+	}
+	return fields, weights, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchRepos returns json of the cached repositories matching q, ordered by sort and order. Results
+// are ranked by relevance, then stars, unless sorted by stars, forks or updated. Forks are left out
+// unless asked for with the fork qualifier, like github does
+func (si *SearchIndex) SearchRepos(q string, sortBy string, order string) ([]json.RawMessage, error) {
+
+	si.mu.RLock()
+	corpus := si.repos
+	si.mu.RUnlock()
+
+	if corpus == nil {
+		return nil, errSearchNotBuilt
+	}
+
+	query := parseSearchQuery(q)
+
+	// words of the name tell more about a repository than those of its description
+	fields, weights, err := searchFields(query, []searchField{
+		{name: "name", index: corpus.names, weight: 3},
+		{name: "description", index: corpus.descriptions, weight: 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forks := "false"
+	var filters []func(*repoDoc) bool
+	for _, q := range query.qualifiers {
+
+		if q.key == "fork" {
+			if q.value != "true" && q.value != "false" && q.value != "only" {
+				return nil, &SearchQueryError{Msg: "fork qualifier must be true, false or only"}
+			}
+			forks = q.value
+			continue
+		}
+
+		filter, err := repoFilter(q)
+		if err != nil {
+			return nil, err
+		}
+		if filter == nil {
+			continue
+		}
+		if q.negate {
+			matches := filter
+			filter = func(doc *repoDoc) bool { return !matches(doc) }
+		}
+		filters = append(filters, filter)
+	}
+
+	var less func(a, b *repoDoc) bool
+	switch sortBy {
+	case "", "best-match":
+	case "stars":
+		less = func(a, b *repoDoc) bool { return a.stars < b.stars }
+	case "forks":
+		less = func(a, b *repoDoc) bool { return a.forks < b.forks }
+	case "updated":
+		less = func(a, b *repoDoc) bool { return a.pushed < b.pushed }
+	default:
+		return nil, &SearchQueryError{Msg: "sort must be one of stars, forks or updated"}
+	}
+
+	descending, err := searchOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []int
+	scores := textScores(query, len(corpus.docs), fields, weights)
+	for doc := range scores {
+
+		repo := corpus.docs[doc]
+		if (forks == "false" && repo.fork) || (forks == "only" && !repo.fork) {
+			continue
+		}
+
+		keep := true
+		for _, filter := range filters {
+			if !filter(repo) {
+				keep = false
+				break
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		// repositories named exactly as searched for come first
+		if query.phrase != "" && (repo.name == query.phrase || repo.fullName == query.phrase) {
+			scores[doc] += 10
+		}
+		docs = append(docs, doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		a, b := corpus.docs[docs[i]], corpus.docs[docs[j]]
+		if less != nil && less(a, b) != less(b, a) {
+			return less(a, b) != descending
+		}
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		if a.stars != b.stars {
+			return a.stars > b.stars
+		}
+		return a.fullName < b.fullName
+	})
+
+	items := make([]json.RawMessage, len(docs))
+	for i, doc := range docs {
+		items[i] = corpus.docs[doc].data
+	}
+	return items, nil
+}
+
+// repoFilter returns the filter repositories have to pass to be qualified by q, nil for qualifiers
+// which don't filter
+func repoFilter(q qualifier) (func(*repoDoc) bool, error) {
+
+	switch q.key {
+	case "in":
+		return nil, nil
+
+	case "org", "user":
+		return func(doc *repoDoc) bool { return doc.org == q.value }, nil
+
+	case "repo":
+		return func(doc *repoDoc) bool { return doc.fullName == q.value }, nil
+
+	case "language":
+		return func(doc *repoDoc) bool { return doc.language == q.value }, nil
+
+	case "topic":
+		return func(doc *repoDoc) bool { return containsString(doc.topics, q.value) }, nil
+
+	case "archived":
+		archived, err := strconv.ParseBool(q.value)
+		if err != nil {
+			return nil, &SearchQueryError{Msg: "archived qualifier must be true or false"}
+		}
+		return func(doc *repoDoc) bool { return doc.archived == archived }, nil
+
+	case "is":
+		switch q.value {
+		case "public":
+			return func(doc *repoDoc) bool { return !doc.private }, nil
+		case "private":
+			return func(doc *repoDoc) bool { return doc.private }, nil
+		case "archived":
+			return func(doc *repoDoc) bool { return doc.archived }, nil
+		}
+		return nil, &SearchQueryError{Msg: "is qualifier must be public, private or archived"}
+
+	case "stars", "forks", "size":
+		in, err := parseRange(q.key, q.value, parseNumber, 1)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *repoDoc) bool {
+			switch q.key {
+			case "stars":
+				return in(doc.stars)
+			case "forks":
+				return in(doc.forks)
+			}
+			return in(doc.size)
+		}, nil
+
+	case "created", "pushed":
+		// dates stand for the whole day
+		in, err := parseRange(q.key, q.value, parseDate, 24*60*60)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc *repoDoc) bool {
+			if q.key == "created" {
+				return in(doc.created)
+			}
+			return in(doc.pushed)
+		}, nil
+	}
+
+	return nil, &SearchQueryError{Msg: "unknown qualifier " + q.key}
+}
+
+// SearchMembers returns json of the cached members matching q, ranked by relevance, then login
+func (si *SearchIndex) SearchMembers(q string, sortBy string, order string) ([]json.RawMessage, error) {
+
+	si.mu.RLock()
+	corpus := si.members
+	si.mu.RUnlock()
+
+	if corpus == nil {
+		return nil, errSearchNotBuilt
+	}
+
+	if sortBy != "" && sortBy != "best-match" {
+		return nil, &SearchQueryError{Msg: "members can't be sorted by " + sortBy}
+	}
+	descending, err := searchOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	query := parseSearchQuery(q)
+
+	fields, weights, err := searchFields(query, []searchField{{name: "login", index: corpus.logins, weight: 1}})
+	if err != nil {
+		return nil, err
+	}
+
+	var filters []func(*memberDoc) bool
+	for _, q := range query.qualifiers {
+
+		var filter func(*memberDoc) bool
+		switch q.key {
+		case "in":
+			continue
+		case "org":
+			value := q.value
+			filter = func(doc *memberDoc) bool { return containsString(doc.orgs, value) }
+		case "type":
+			value := q.value
+			filter = func(doc *memberDoc) bool { return doc.kind == value }
+		case "is":
+			if q.value != "admin" {
+				return nil, &SearchQueryError{Msg: "is qualifier must be admin"}
+			}
+			filter = func(doc *memberDoc) bool { return doc.admin }
+		default:
+			return nil, &SearchQueryError{Msg: "unknown qualifier " + q.key}
+		}
+
+		if q.negate {
+			matches := filter
+			filter = func(doc *memberDoc) bool { return !matches(doc) }
+		}
+		filters = append(filters, filter)
+	}
+
+	var docs []int
+	scores := textScores(query, len(corpus.docs), fields, weights)
+	for doc := range scores {
+
+		member := corpus.docs[doc]
+		keep := true
+		for _, filter := range filters {
+			if !filter(member) {
+				keep = false
+				break
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		if query.phrase != "" && member.login == query.phrase {
+			scores[doc] += 10
+		}
+		docs = append(docs, doc)
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]] == descending
+		}
+		return corpus.docs[docs[i]].login < corpus.docs[docs[j]].login
+	})
+
+	items := make([]json.RawMessage, len(docs))
+	for i, doc := range docs {
+		items[i] = corpus.docs[doc].data
+	}
+	return items, nil
+}
+
+// searchOrder reports whether results are to be sorted in descending order, which is the default
+func searchOrder(order string) (bool, error) {
+	switch order {
+	case "", "desc":
+		return true, nil
+	case "asc":
+		return false, nil
+	}
+	return false, &SearchQueryError{Msg: "order must be asc or desc"}
+}
+
+// parseRange parses the range of values qualifier key accepts, written as N, >N, >=N, <N, <=N or N..M
+// where either bound of N..M may be *. Every bound stands for values from it up to width past it.
+// Returns a function reporting whether a value is in range
+func parseRange(key string, value string, parse func(string) (float64, error), width float64) (func(float64) bool, error) {
+
+	invalid := &SearchQueryError{Msg: fmt.Sprintf("invalid range %q for %s qualifier", value, key)}
+
+	bound := func(s string) (float64, error) {
+		v, err := parse(s)
+		if err != nil {
+			return 0, invalid
+		}
+		return v, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(value, op) {
+			continue
+		}
+		v, err := bound(strings.TrimPrefix(value, op))
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case ">=":
+			return func(x float64) bool { return x >= v }, nil
+		case "<=":
+			return func(x float64) bool { return x < v+width }, nil
+		case ">":
+			return func(x float64) bool { return x >= v+width }, nil
+		}
+		return func(x float64) bool { return x < v }, nil
+	}
+
+	if i := strings.Index(value, ".."); i >= 0 {
+		lo, hi := value[:i], value[i+2:]
+		min, max := -1e18, 1e18
+		var err error
+		if lo != "*" {
+			if min, err = bound(lo); err != nil {
+				return nil, err
+			}
+		}
+		if hi != "*" {
+			if max, err = bound(hi); err != nil {
+				return nil, err
+			}
+		}
+		return func(x float64) bool { return x >= min && x < max+width }, nil
+	}
+
+	v, err := bound(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(x float64) bool { return x >= v && x < v+width }, nil
+}
+
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseDate parses dates as YYYY-MM-DD or RFC3339 into unix seconds
+func parseDate(s string) (float64, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, strings.ToUpper(s)); err != nil {
+			return 0, err
+		}
+	}
+	return float64(t.Unix()), nil
+}
+
+// searchResult is the body of search responses, shaped like those of github search api
+type searchResult struct {
+	TotalCount        int               `json:"total_count"`
+	IncompleteResults bool              `json:"incomplete_results"`
+	Items             []json.RawMessage `json:"items"`
+}
+
+// isSearchPath reports whether path is served by the search index, which is only built over github data
+func isSearchPath(path string) bool {
+	if config.GetConfig().GetProvider() != config.ProviderGitHub {
+		return false
+	}
+	return path == "/search/"+SearchRepos || path == "/search/"+SearchMembers
+}
+
+// HandleSearch serves the page of repositories or members matching the query in q, as asked for
+// with page and per_page, along with github compatible Link header
+func (hh *Handlers) HandleSearch(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if strings.TrimSpace(q) == "" {
+		w.WriteHeader(422)
+		w.Write([]byte("q parameter is required"))
+		return
+	}
+
+	var items []json.RawMessage
+	var err error
+	if mux.Vars(r)["kind"] == SearchMembers {
+		items, err = hh.cacher.Search.SearchMembers(q, query.Get("sort"), query.Get("order"))
+	} else {
+		items, err = hh.cacher.Search.SearchRepos(q, query.Get("sort"), query.Get("order"))
+	}
+
+	// search can't be served from upstream, so ask client to come back once the index is built
+	if err == errSearchNotBuilt {
+		hh.Unavailable(w, r)
+		return
+	}
+	if err != nil {
+		logging.Logger(r.Context()).Info("Invalid search query",
+										 zap.String("q", q),
+										 zap.String("msg", err.Error()))
+
+		w.WriteHeader(422)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	page, lastPage, start, end := paginate(query, len(items))
+	data, err := json.Marshal(searchResult{TotalCount: len(items), Items: items[start:end]})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if link := listLinks(r, page, lastPage); link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	// index is rebuilt whenever repositories of an org are refreshed, results are reused for cache.refresh at most
+	builtAt := hh.cacher.Search.BuiltAt()
+	served := &model.KeyMeta{UpdatedAt: builtAt, ModifiedAt: builtAt}
+	refresh := config.GetConfig().GetRefreshInterval("")
+	serveCached(w, r, data, model.EncodingIdentity, served, sharedMaxAge(refresh-time.Since(builtAt)))
+}
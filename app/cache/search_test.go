@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		query string
+		want  *searchQuery
+	}{
+		{"words", "Zuul Gateway", &searchQuery{terms: []string{"zuul", "gateway"}, phrase: "zuul gateway"}},
+		{"qualifiers", "language:Java stars:>10", &searchQuery{qualifiers: []qualifier{
+			{key: "language", value: "java"},
+			{key: "stars", value: ">10"},
+		}}},
+		{"negated qualifier", "-fork:true", &searchQuery{qualifiers: []qualifier{
+			{key: "fork", value: "true", negate: true},
+		}}},
+		{"excluded words", "zuul -gateway", &searchQuery{terms: []string{"zuul"}, excluded: []string{"gateway"},
+			phrase: "zuul"}},
+		{"quoted phrase", `"api gateway" zuul`, &searchQuery{terms: []string{"api", "gateway", "zuul"},
+			phrase: "api gateway zuul"}},
+		{"quoted qualifier value", `topic:"Machine Learning"`, &searchQuery{qualifiers: []qualifier{
+			{key: "topic", value: "machine learning"},
+		}}},
+		{"empty", "   ", &searchQuery{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSearchQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSearchQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitQuery(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"white space", " zuul\tgateway  ", []string{"zuul", "gateway"}},
+		{"quotes keep words together", `a "b c"  d`, []string{"a", `"b c"`, "d"}},
+		{"quotes within a word", `topic:"b c" d`, []string{`topic:"b c"`, "d"}},
+		{"unterminated quote runs to the end", `a "b c`, []string{"a", `"b c`}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+
+	date := func(day int, hour int) float64 {
+		return float64(time.Date(2020, 1, day, hour, 0, 0, 0, time.UTC).Unix())
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		date  bool
+		in    []float64
+		out   []float64
+	}{
+		{"number", "10", false, []float64{10}, []float64{9, 11}},
+		{"greater", ">10", false, []float64{11, 500}, []float64{10}},
+		{"greater or equal", ">=10", false, []float64{10, 11}, []float64{9}},
+		{"less", "<10", false, []float64{0, 9}, []float64{10}},
+		{"less or equal", "<=10", false, []float64{9, 10}, []float64{11}},
+		{"between", "10..20", false, []float64{10, 15, 20}, []float64{9, 21}},
+		{"open lower bound", "*..20", false, []float64{-5, 20}, []float64{21}},
+		{"open upper bound", "10..*", false, []float64{10, 1e9}, []float64{9}},
+		{"date stands for the whole day", "2020-01-02", true, []float64{date(2, 0), date(2, 23)},
+			[]float64{date(1, 23), date(3, 0)}},
+		{"up to the end of a day", "<=2020-01-02", true, []float64{date(1, 0), date(2, 23)}, []float64{date(3, 0)}},
+		{"after the end of a day", ">2020-01-02", true, []float64{date(3, 0)}, []float64{date(2, 23)}},
+		{"before a day", "<2020-01-02", true, []float64{date(1, 23)}, []float64{date(2, 0)}},
+		{"date range", "2020-01-01..2020-01-02", true, []float64{date(1, 0), date(2, 23)}, []float64{date(3, 0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			in, err := parseRange("stars", tt.value, parseNumber, 1)
+			if tt.date {
+				in, err = parseRange("created", tt.value, parseDate, 24*60*60)
+			}
+			if err != nil {
+				t.Fatalf("parseRange(%q) error = %v", tt.value, err)
+			}
+
+			for _, x := range tt.in {
+				if !in(x) {
+					t.Errorf("parseRange(%q) left out %v", tt.value, x)
+				}
+			}
+			for _, x := range tt.out {
+				if in(x) {
+					t.Errorf("parseRange(%q) took in %v", tt.value, x)
+				}
+			}
+		})
+	}
+
+	for _, value := range []string{"abc", ">x", "1..x", "x..1", ""} {
+		if _, err := parseRange("stars", value, parseNumber, 1); err == nil {
+			t.Errorf("parseRange(%q) error = nil, want invalid range", value)
+		}
+	}
+}
+
+// newTestSearchIndex indexes docs the way BuildSearchIndex does, with the name of each as its data
+func newTestSearchIndex(repos []*repoDoc, members []*memberDoc) *SearchIndex {
+
+	rc := &repoCorpus{docs: repos, names: newTextIndex(), descriptions: newTextIndex()}
+	for i, doc := range repos {
+		doc.data = json.RawMessage(`"` + doc.fullName + `"`)
+		rc.names.add(i, doc.name)
+		rc.descriptions.add(i, doc.description)
+	}
+	rc.names.finish()
+	rc.descriptions.finish()
+
+	mc := &memberCorpus{docs: members, logins: newTextIndex()}
+	for i, doc := range members {
+		doc.data = json.RawMessage(`"` + doc.login + `"`)
+		mc.logins.add(i, doc.login)
+	}
+	mc.logins.finish()
+
+	return &SearchIndex{repos: rc, members: mc, builtAt: time.Now()}
+}
+
+func searchNames(items []json.RawMessage) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = strings.Trim(string(item), `"`)
+	}
+	return names
+}
+
+func TestSearchRepos(t *testing.T) {
+
+	si := newTestSearchIndex([]*repoDoc{
+		{name: "zuul", fullName: "netflix/zuul", description: "gateway service", stars: 100, forks: 10, pushed: 3},
+		{name: "zuul-plugins", fullName: "netflix/zuul-plugins", description: "filters for zuul", stars: 20, forks: 30,
+			pushed: 5},
+		{name: "eureka", fullName: "netflix/eureka", description: "service registry", stars: 300, forks: 5, pushed: 1},
+		{name: "hystrix", fullName: "netflix/hystrix", description: "latency and fault tolerance", stars: 500,
+			fork: true},
+	}, nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		sortBy string
+		order  string
+		want   []string
+	}{
+		{"exact name ranks first", "zuul", "", "", []string{"netflix/zuul", "netflix/zuul-plugins"}},
+		{"matches in names and descriptions add up", "zu", "", "", []string{"netflix/zuul-plugins", "netflix/zuul"}},
+		{"ties ranked by stars", "service", "", "", []string{"netflix/eureka", "netflix/zuul"}},
+		{"order is ignored when ranking", "service", "", "asc", []string{"netflix/eureka", "netflix/zuul"}},
+		{"stars descending", "zuul", "stars", "desc", []string{"netflix/zuul", "netflix/zuul-plugins"}},
+		{"stars ascending", "zuul", "stars", "asc", []string{"netflix/zuul-plugins", "netflix/zuul"}},
+		{"forks", "", "forks", "", []string{"netflix/zuul-plugins", "netflix/zuul", "netflix/eureka"}},
+		{"updated ascending", "", "updated", "asc", []string{"netflix/eureka", "netflix/zuul", "netflix/zuul-plugins"}},
+		{"excluded words", "-gateway", "stars", "", []string{"netflix/eureka", "netflix/zuul-plugins"}},
+		{"forks when asked for", "fork:only", "", "", []string{"netflix/hystrix"}},
+		{"negated qualifiers", "-stars:<50", "", "", []string{"netflix/eureka", "netflix/zuul"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			items, err := si.SearchRepos(tt.query, tt.sortBy, tt.order)
+			if err != nil {
+				t.Fatalf("SearchRepos(%q) error = %v", tt.query, err)
+			}
+			if got := searchNames(items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchRepos(%q, %q, %q) = %q, want %q", tt.query, tt.sortBy, tt.order, got, tt.want)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ query, sortBy, order string }{
+		{"zuul", "watchers", ""},
+		{"zuul", "", "up"},
+		{"in:readme zuul", "", ""},
+		{"stars:lots", "", ""},
+		{"license:mit", "", ""},
+	} {
+		if _, err := si.SearchRepos(tt.query, tt.sortBy, tt.order); err == nil {
+			t.Errorf("SearchRepos(%q, %q, %q) error = nil, want query error", tt.query, tt.sortBy, tt.order)
+		}
+	}
+}
+
+func TestSearchMembers(t *testing.T) {
+
+	si := newTestSearchIndex(nil, []*memberDoc{
+		{login: "brianne", orgs: []string{"netflix"}, kind: "user"},
+		{login: "brian", orgs: []string{"netflix", "spotify"}, kind: "user", admin: true},
+		{login: "carl", orgs: []string{"spotify"}, kind: "bot"},
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		order string
+		want  []string
+	}{
+		{"exact login ranks first", "brian", "", []string{"brian", "brianne"}},
+		{"ascending", "brian", "asc", []string{"brianne", "brian"}},
+		{"ties ordered by login", "", "", []string{"brian", "brianne", "carl"}},
+		{"ties ordered by login ascending", "", "asc", []string{"brian", "brianne", "carl"}},
+		{"org", "org:spotify", "", []string{"brian", "carl"}},
+		{"negated qualifiers", "-type:bot -is:admin", "", []string{"brianne"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			items, err := si.SearchMembers(tt.query, "", tt.order)
+			if err != nil {
+				t.Fatalf("SearchMembers(%q) error = %v", tt.query, err)
+			}
+			if got := searchNames(items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchMembers(%q, %q) = %q, want %q", tt.query, tt.order, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := si.SearchMembers("brian", "joined", ""); err == nil {
+		t.Error("SearchMembers() sorted by joined error = nil, want query error")
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+)
+
+// updateBuffer is how many updates a subscriber may fall behind before updates are dropped for it
+const updateBuffer = 64
+
+// Update announces that content cached under Key changed
+type Update struct {
+	Key        string
+	ETag       string
+	ModifiedAt time.Time
+}
+
+// UpdateFeed fans updates of cached keys out to subscribers of this replica
+type UpdateFeed struct {
+	mu          sync.RWMutex
+	subscribers map[chan Update]bool
+
+	// done is closed once the feed is closed, closeOnce guards closing it
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewUpdateFeed returns a feed without subscribers
+func NewUpdateFeed() *UpdateFeed {
+	return &UpdateFeed{subscribers: make(map[chan Update]bool), done: make(chan struct{})}
+}
+
+// Close tells subscribers that no more updates will follow, such as when shutting down
+func (uf *UpdateFeed) Close() {
+	uf.closeOnce.Do(func() {
+		close(uf.done)
+	})
+}
+
+// Done returns a channel which is closed once the feed is closed
+func (uf *UpdateFeed) Done() <-chan struct{} {
+	return uf.done
+}
+
+// Subscribe returns a channel receiving every update published from now on, until unsubscribed
+func (uf *UpdateFeed) Subscribe() chan Update {
+
+	ch := make(chan Update, updateBuffer)
+
+	uf.mu.Lock()
+	uf.subscribers[ch] = true
+	uf.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivering updates to ch
+func (uf *UpdateFeed) Unsubscribe(ch chan Update) {
+	uf.mu.Lock()
+	delete(uf.subscribers, ch)
+	uf.mu.Unlock()
+}
+
+// HasSubscribers reports whether anyone is listening, so that publishers may skip working out updates
+func (uf *UpdateFeed) HasSubscribers() bool {
+	if uf == nil {
+		return false
+	}
+	uf.mu.RLock()
+	defer uf.mu.RUnlock()
+	return len(uf.subscribers) > 0
+}
+
+// Publish delivers update to every subscriber. Refreshes never wait on subscribers, so those
+// falling behind miss updates
+func (uf *UpdateFeed) Publish(update Update) {
+
+	uf.mu.RLock()
+	defer uf.mu.RUnlock()
+
+	for ch := range uf.subscribers {
+		select {
+		case ch <- update:
+		default:
+			logging.Logger(context.Background()).Warn("Dropping update for slow subscriber",
+													  zap.String("key", update.Key))
+		}
+	}
+}
+
+// notify publishes an update of key if its content changed when it was last written
+func (cc *Cacher) notify(key string) {
+
+	if !cc.Updates.HasSubscribers() {
+		return
+	}
+
+	meta, err := cc.DBClient.GetMeta(key)
+	if err != nil || meta == nil || !meta.ModifiedAt.Equal(meta.UpdatedAt) {
+		return
+	}
+	cc.Updates.Publish(Update{Key: key, ETag: meta.ETag, ModifiedAt: meta.ModifiedAt})
+}
@@ -0,0 +1,753 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// kinds of argument values
+const (
+	KindString  = "String"
+	KindInt     = "Int"
+	KindFloat   = "Float"
+	KindBoolean = "Boolean"
+	KindEnum    = "Enum"
+)
+
+// limits applied to queries unless the schema sets its own
+const (
+	DefaultMaxDepth      = 10
+	DefaultMaxSelections = 500
+)
+
+// Schema describes the objects queries select fields of, starting at Query
+type Schema struct {
+	Query *Object
+
+	// MaxDepth is how deep fields may be nested, DefaultMaxDepth if zero
+	MaxDepth int
+
+	// MaxSelections is how many fields a query may select once its fragments are expanded, aliases
+	// of a field each counting. DefaultMaxSelections if zero
+	MaxSelections int
+}
+
+func (s *Schema) maxDepth() int {
+	if s.MaxDepth > 0 {
+		return s.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+func (s *Schema) maxSelections() int {
+	if s.MaxSelections > 0 {
+		return s.MaxSelections
+	}
+	return DefaultMaxSelections
+}
+
+// objects returns every object reachable from Query by name
+func (s *Schema) objects() map[string]*Object {
+
+	objects := make(map[string]*Object)
+	var walk func(obj *Object)
+	walk = func(obj *Object) {
+		if obj == nil || objects[obj.Name] != nil {
+			return
+		}
+		objects[obj.Name] = obj
+		for _, def := range obj.Fields {
+			walk(def.Type)
+		}
+	}
+	walk(s.Query)
+	return objects
+}
+
+// Object is an object type along with its fields
+type Object struct {
+	Name   string
+	Fields map[string]*FieldDef
+}
+
+// FieldDef defines a field of an object, the arguments it accepts and how it is resolved
+type FieldDef struct {
+	Args map[string]*Arg
+
+	// Type is the object values of the field are, nil for scalars. Fields resolving to slices are lists
+	Type *Object
+
+	// Resolve returns the value of the field of the object in p.Source
+	Resolve func(p Params) (interface{}, error)
+}
+
+// Arg defines an argument of a field
+type Arg struct {
+	Kind     string
+	Default  interface{}
+	Required bool
+
+	// Values lists the values accepted by enum arguments
+	Values []string
+}
+
+// Params are passed to resolvers of fields
+type Params struct {
+	Context context.Context
+	Source  interface{}
+
+	// Args holds arguments given to the field and the defaults of those which weren't, coerced to
+	// string for strings and enums, int, float64 and bool
+	Args map[string]interface{}
+}
+
+// String returns the string or enum argument name, empty if not given
+func (p Params) String(name string) string {
+	s, _ := p.Args[name].(string)
+	return s
+}
+
+// Int returns the int argument name, zero if not given
+func (p Params) Int(name string) int {
+	n, _ := p.Args[name].(int)
+	return n
+}
+
+// Bool returns the boolean argument name, and whether it was given
+func (p Params) Bool(name string) (bool, bool) {
+	b, ok := p.Args[name].(bool)
+	return b, ok
+}
+
+// Error is an error reported in the response, along with the path of the field it occurred at
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Response is the result of executing a query. Data is nil when the query could not be executed at all
+type Response struct {
+	Data   *OrderedMap `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// OrderedMap is an object of the response, whose keys are written in the order they were selected
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedMap returns an empty map
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]interface{})}
+}
+
+// Set sets key to value, keeping the position of key if it was set before
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of key
+func (m *OrderedMap) Get(key string) interface{} {
+	return m.values[key]
+}
+
+// MarshalJSON writes the map as a json object with keys in order
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Execute parses query, validates it against schema and executes the operation named operationName,
+// or the only operation of the document if not named. Errors resolving a field leave it null and are
+// reported along with the data
+func Execute(ctx context.Context, schema *Schema, query string, operationName string,
+	variables map[string]interface{}) *Response {
+
+	doc, err := Parse(query)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	op, err := findOperation(doc, operationName)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	if errs := validate(schema, doc, op); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	vars := make(map[string]interface{})
+	for _, def := range op.Variables {
+		if value, ok := variables[def.Name]; ok {
+			vars[def.Name] = value
+		} else if def.HasDefault {
+			vars[def.Name] = def.Default
+		} else if strings.HasSuffix(def.Type, "!") {
+			return &Response{Errors: []*Error{{Message: "variable $" + def.Name + " of type " + def.Type + " is required"}}}
+		}
+	}
+
+	e := &executor{ctx: ctx, doc: doc, vars: vars}
+	data := e.object(schema.Query, nil, e.collect(op.Selections), nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func findOperation(doc *Document, name string) (*Operation, error) {
+
+	var found *Operation
+	for _, op := range doc.Operations {
+		if name == "" && len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with several operations")
+		}
+		if name == "" || op.Name == name {
+			found = op
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown operation %s", name)
+	}
+	if found.Type != "query" {
+		return nil, fmt.Errorf("only queries are supported, not %s", found.Type)
+	}
+	return found, nil
+}
+
+// validator checks selections of an operation against the schema before anything is resolved.
+// Fragments are validated once on their own, so that spreading them many times costs nothing more
+type validator struct {
+	doc       *Document
+	variables map[string]bool
+	errors    []*Error
+
+	// measured memoizes depth and number of fields of fragments once expanded
+	measured map[string][2]int
+}
+
+func validate(schema *Schema, doc *Document, op *Operation) []*Error {
+
+	v := &validator{doc: doc, variables: make(map[string]bool), measured: make(map[string][2]int)}
+	for _, def := range op.Variables {
+		v.variables[def.Name] = true
+	}
+
+	// fragments in order of name so that errors are reported in the same order every time
+	names := make([]string, 0, len(doc.Fragments))
+	for name := range doc.Fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	objects := schema.objects()
+	for _, name := range names {
+		fragment := doc.Fragments[name]
+		obj, ok := objects[fragment.TypeCondition]
+		if !ok {
+			v.fail("fragment %q is on unknown type %s", name, fragment.TypeCondition)
+			continue
+		}
+		v.selections(obj, fragment.Selections)
+	}
+	v.cycles(names)
+
+	v.selections(schema.Query, op.Selections)
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	// fragments are known to be acyclic from here on, so expanding them terminates
+	depth, count := v.measure(op.Selections, schema.maxSelections())
+	if depth > schema.maxDepth() {
+		v.fail("query is nested %d levels deep, at most %d are allowed", depth, schema.maxDepth())
+	}
+	if count > schema.maxSelections() {
+		v.fail("query selects more than %d fields", schema.maxSelections())
+	}
+	return v.errors
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// selections validates sels as selected on obj. Spread fragments are only checked to exist and
+// apply to obj, they are validated on their own
+func (v *validator) selections(obj *Object, sels []Selection) {
+
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+
+		case *Field:
+			v.directives(sel.Directives)
+
+			if sel.Name == "__typename" {
+				if len(sel.Selections) > 0 {
+					v.fail("field __typename can't have a selection of subfields")
+				}
+				continue
+			}
+
+			def, ok := obj.Fields[sel.Name]
+			if !ok {
+				v.fail("cannot query field %q on type %q", sel.Name, obj.Name)
+				continue
+			}
+
+			given := make(map[string]bool)
+			for _, arg := range sel.Arguments {
+				given[arg.Name] = true
+				spec, ok := def.Args[arg.Name]
+				if !ok {
+					v.fail("unknown argument %q on field %s.%s", arg.Name, obj.Name, sel.Name)
+					continue
+				}
+				v.value(spec, arg.Value, fmt.Sprintf("argument %q on field %s.%s", arg.Name, obj.Name, sel.Name))
+			}
+			for name, spec := range def.Args {
+				if spec.Required && !given[name] {
+					v.fail("field %s.%s requires argument %q", obj.Name, sel.Name, name)
+				}
+			}
+
+			switch {
+			case def.Type == nil && len(sel.Selections) > 0:
+				v.fail("field %s.%s is a scalar and can't have a selection of subfields", obj.Name, sel.Name)
+			case def.Type != nil && len(sel.Selections) == 0:
+				v.fail("field %s.%s of type %s must have a selection of subfields", obj.Name, sel.Name, def.Type.Name)
+			case def.Type != nil:
+				v.selections(def.Type, sel.Selections)
+			}
+
+		case *FragmentSpread:
+			v.directives(sel.Directives)
+
+			fragment, ok := v.doc.Fragments[sel.Name]
+			if !ok {
+				v.fail("unknown fragment %q", sel.Name)
+				continue
+			}
+			if fragment.TypeCondition != obj.Name {
+				v.fail("fragment %q on %s can't be spread on type %s", sel.Name, fragment.TypeCondition, obj.Name)
+			}
+
+		case *InlineFragment:
+			v.directives(sel.Directives)
+
+			if sel.TypeCondition != "" && sel.TypeCondition != obj.Name {
+				v.fail("inline fragment on %s can't be spread on type %s", sel.TypeCondition, obj.Name)
+				continue
+			}
+			v.selections(obj, sel.Selections)
+		}
+	}
+}
+
+// cycles reports fragments which end up spreading themselves
+func (v *validator) cycles(names []string) {
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		for _, spread := range spreads(v.doc.Fragments[name].Selections, nil) {
+			if _, ok := v.doc.Fragments[spread]; !ok {
+				continue
+			}
+			switch state[spread] {
+			case visiting:
+				v.fail("fragment %q spreads itself", spread)
+			case 0:
+				visit(spread)
+			}
+		}
+		state[name] = visited
+	}
+
+	for _, name := range names {
+		if state[name] == 0 {
+			visit(name)
+		}
+	}
+}
+
+// spreads appends names of fragments spread anywhere in sels to names
+func spreads(sels []Selection, names []string) []string {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *Field:
+			names = spreads(sel.Selections, names)
+		case *FragmentSpread:
+			names = append(names, sel.Name)
+		case *InlineFragment:
+			names = spreads(sel.Selections, names)
+		}
+	}
+	return names
+}
+
+// measure returns how deep fields of sels nest and how many fields they select once fragments are
+// expanded. Counts stop growing past limit so that they can't overflow
+func (v *validator) measure(sels []Selection, limit int) (int, int) {
+
+	depth, count := 0, 0
+	add := func(d int, c int) {
+		if d > depth {
+			depth = d
+		}
+		if count += c; count > limit {
+			count = limit + 1
+		}
+	}
+
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *Field:
+			d, c := v.measure(sel.Selections, limit)
+			add(d+1, c+1)
+
+		case *FragmentSpread:
+			m, ok := v.measured[sel.Name]
+			if !ok {
+				m[0], m[1] = v.measure(v.doc.Fragments[sel.Name].Selections, limit)
+				v.measured[sel.Name] = m
+			}
+			add(m[0], m[1])
+
+		case *InlineFragment:
+			add(v.measure(sel.Selections, limit))
+		}
+	}
+	return depth, count
+}
+
+func (v *validator) directives(directives []*Directive) {
+
+	for _, directive := range directives {
+		if directive.Name != "include" && directive.Name != "skip" {
+			v.fail("unknown directive @%s", directive.Name)
+			continue
+		}
+		if len(directive.Arguments) != 1 || directive.Arguments[0].Name != "if" {
+			v.fail("directive @%s requires a single argument if", directive.Name)
+			continue
+		}
+		v.value(&Arg{Kind: KindBoolean, Required: true}, directive.Arguments[0].Value, "argument if of @"+directive.Name)
+	}
+}
+
+// value validates a literal value given for arg, variables are checked when they are resolved
+func (v *validator) value(arg *Arg, value Value, what string) {
+
+	if name, ok := value.(Variable); ok {
+		if !v.variables[string(name)] {
+			v.fail("variable $%s is not defined", name)
+		}
+		return
+	}
+	if _, err := coerce(arg, value); err != nil {
+		v.fail("%s: %s", what, err.Error())
+	}
+}
+
+// coerce converts value given for arg into the go value resolvers receive
+func coerce(arg *Arg, value interface{}) (interface{}, error) {
+
+	if value == nil {
+		if arg.Required {
+			return nil, fmt.Errorf("expected non null %s", arg.Kind)
+		}
+		return nil, nil
+	}
+
+	switch arg.Kind {
+	case KindString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+
+	case KindEnum:
+		// enums arrive as strings in variables
+		var s string
+		switch value := value.(type) {
+		case Enum:
+			s = string(value)
+		case string:
+			s = value
+		default:
+			return nil, fmt.Errorf("expected %s, found %v", arg.Kind, value)
+		}
+		for _, allowed := range arg.Values {
+			if s == allowed {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("expected one of %s, found %s", strings.Join(arg.Values, ", "), s)
+
+	case KindInt:
+		switch n := value.(type) {
+		case int:
+			return n, nil
+		case float64:
+			// numbers in variables are decoded from json as floats
+			if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+
+	case KindFloat:
+		switch n := value.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+
+	case KindBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, found %v", arg.Kind, value)
+}
+
+type executor struct {
+	ctx    context.Context
+	doc    *Document
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+// fieldSet holds the fields selected on an object grouped by response key, keys in the order first selected
+type fieldSet struct {
+	keys   []string
+	groups map[string][]*Field
+}
+
+// object resolves the fields of obj in fs on source
+func (e *executor) object(obj *Object, source interface{}, fs *fieldSet, path []interface{}) *OrderedMap {
+
+	result := NewOrderedMap()
+
+	for _, key := range fs.keys {
+
+		fields := fs.groups[key]
+		field := fields[0]
+		fieldPath := append(path[:len(path):len(path)], key)
+
+		if field.Name == "__typename" {
+			result.Set(key, obj.Name)
+			continue
+		}
+
+		def := obj.Fields[field.Name]
+		args, err := e.args(def, field.Arguments)
+		if err != nil {
+			e.fail(fieldPath, err)
+			result.Set(key, nil)
+			continue
+		}
+
+		value, err := def.Resolve(Params{Context: e.ctx, Source: source, Args: args})
+		if err != nil {
+			e.fail(fieldPath, err)
+			result.Set(key, nil)
+			continue
+		}
+
+		// fields selected more than once under the same key have their selections merged
+		var subs []Selection
+		for _, f := range fields {
+			subs = append(subs, f.Selections...)
+		}
+		result.Set(key, e.complete(def.Type, value, subs, fieldPath))
+	}
+	return result
+}
+
+// complete resolves selections on value of type obj, element by element for lists. Scalars are
+// returned as resolved. Selections are collected once for all elements of lists
+func (e *executor) complete(obj *Object, value interface{}, sels []Selection, path []interface{}) interface{} {
+	if obj == nil {
+		return e.completeFields(nil, value, nil, path)
+	}
+	return e.completeFields(obj, value, e.collect(sels), path)
+}
+
+func (e *executor) completeFields(obj *Object, value interface{}, fs *fieldSet, path []interface{}) interface{} {
+
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+
+	if obj == nil {
+		return value
+	}
+
+	if rv.Kind() == reflect.Slice {
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = e.completeFields(obj, rv.Index(i).Interface(), fs, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+	return e.object(obj, value, fs, path)
+}
+
+// collect gathers fields selected by sels, expanding fragments and leaving out those skipped by directives
+func (e *executor) collect(sels []Selection) *fieldSet {
+	fs := &fieldSet{groups: make(map[string][]*Field)}
+	e.collectInto(fs, sels)
+	return fs
+}
+
+func (e *executor) collectInto(fs *fieldSet, sels []Selection) {
+
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+
+		case *Field:
+			if !e.included(sel.Directives) {
+				continue
+			}
+			key := sel.ResponseKey()
+			if _, ok := fs.groups[key]; !ok {
+				fs.keys = append(fs.keys, key)
+			}
+			fs.groups[key] = append(fs.groups[key], sel)
+
+		case *FragmentSpread:
+			if !e.included(sel.Directives) {
+				continue
+			}
+			e.collectInto(fs, e.doc.Fragments[sel.Name].Selections)
+
+		case *InlineFragment:
+			if !e.included(sel.Directives) {
+				continue
+			}
+			e.collectInto(fs, sel.Selections)
+		}
+	}
+}
+
+// included evaluates @include and @skip directives
+func (e *executor) included(directives []*Directive) bool {
+
+	for _, directive := range directives {
+		value, _ := e.resolve(directive.Arguments[0].Value).(bool)
+		if (directive.Name == "include") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// args returns arguments given to field defined by def, along with the defaults of those not given
+func (e *executor) args(def *FieldDef, arguments []*Argument) (map[string]interface{}, error) {
+
+	args := make(map[string]interface{})
+	for name, spec := range def.Args {
+		if spec.Default != nil {
+			args[name] = spec.Default
+		}
+	}
+
+	for _, arg := range arguments {
+
+		// variables which are neither given nor defaulted leave the argument out
+		if name, ok := arg.Value.(Variable); ok {
+			if _, ok := e.vars[string(name)]; !ok {
+				continue
+			}
+		}
+
+		spec := def.Args[arg.Name]
+		value, err := coerce(spec, e.resolve(arg.Value))
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %s", arg.Name, err.Error())
+		}
+		if value == nil {
+			delete(args, arg.Name)
+			continue
+		}
+		args[arg.Name] = value
+	}
+
+	for name, spec := range def.Args {
+		if _, ok := args[name]; spec.Required && !ok {
+			return nil, fmt.Errorf("argument %q is required", name)
+		}
+	}
+	return args, nil
+}
+
+// resolve replaces variables in value with their values
+func (e *executor) resolve(value Value) interface{} {
+
+	switch value := value.(type) {
+	case Variable:
+		return e.vars[string(value)]
+	case []Value:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = e.resolve(item)
+		}
+		return list
+	case map[string]Value:
+		object := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			object[name] = e.resolve(item)
+		}
+		return object
+	}
+	return value
+}
@@ -0,0 +1,309 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testRepo struct {
+	Name  string
+	Stars int
+}
+
+type testOrg struct {
+	Login string
+	Repos []*testRepo
+}
+
+// testSchema serves a single org with a few repos, parent of every repo being the org again so that
+// queries may nest as deep as they like
+func testSchema() *Schema {
+
+	org := &testOrg{Login: "netflix", Repos: []*testRepo{{"zuul", 3}, {"hystrix", 5}, {"eureka", 1}}}
+
+	orgType := &Object{Name: "Org", Fields: map[string]*FieldDef{}}
+	repoType := &Object{Name: "Repo", Fields: map[string]*FieldDef{
+		"name": {Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*testRepo).Name, nil
+		}},
+		"stars": {Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*testRepo).Stars, nil
+		}},
+		"owner": {Type: orgType, Resolve: func(p Params) (interface{}, error) {
+			return org, nil
+		}},
+	}}
+
+	orgType.Fields["login"] = &FieldDef{Resolve: func(p Params) (interface{}, error) {
+		return p.Source.(*testOrg).Login, nil
+	}}
+	orgType.Fields["repos"] = &FieldDef{
+		Args: map[string]*Arg{
+			"first": {Kind: KindInt, Default: 100},
+			"order": {Kind: KindEnum, Values: []string{"ASC", "DESC"}, Default: "ASC"},
+		},
+		Type: repoType,
+		Resolve: func(p Params) (interface{}, error) {
+			repos := p.Source.(*testOrg).Repos
+			if p.String("order") == "DESC" {
+				repos = []*testRepo{repos[2], repos[1], repos[0]}
+			}
+			if first := p.Int("first"); first < len(repos) {
+				repos = repos[:first]
+			}
+			return repos, nil
+		},
+	}
+	orgType.Fields["broken"] = &FieldDef{Resolve: func(p Params) (interface{}, error) {
+		return nil, errors.New("broken")
+	}}
+
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*FieldDef{
+		"org": {
+			Args: map[string]*Arg{"login": {Kind: KindString, Required: true}},
+			Type: orgType,
+			Resolve: func(p Params) (interface{}, error) {
+				if p.String("login") != org.Login {
+					return nil, nil
+				}
+				return org, nil
+			},
+		},
+	}}}
+}
+
+func marshal(t *testing.T, resp *Response) string {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			name:  "fields in order selected",
+			query: `{ org(login: "netflix") { repos(first: 2) { stars name } login } }`,
+			want:  `{"data":{"org":{"repos":[{"stars":3,"name":"zuul"},{"stars":5,"name":"hystrix"}],"login":"netflix"}}}`,
+		},
+		{
+			name:  "aliases and typename",
+			query: `{ a: org(login: "netflix") { __typename l: login } b: org(login: "nobody") { login } }`,
+			want:  `{"data":{"a":{"__typename":"Org","l":"netflix"},"b":null}}`,
+		},
+		{
+			name:  "fragments and inline fragments merged",
+			query: `{ org(login: "netflix") { ...F ... on Org { repos(first: 1) { stars } } } } fragment F on Org { login repos(first: 1) { name } }`,
+			want:  `{"data":{"org":{"login":"netflix","repos":[{"name":"zuul","stars":3}]}}}`,
+		},
+		{
+			name:      "variables and defaults",
+			query:     `query Q($login: String!, $n: Int = 1, $order: String) { org(login: $login) { repos(first: $n, order: $order) { name } } }`,
+			operation: "Q",
+			variables: map[string]interface{}{"login": "netflix", "order": "DESC"},
+			want:      `{"data":{"org":{"repos":[{"name":"eureka"}]}}}`,
+		},
+		{
+			name:      "numbers of variables decoded from json",
+			query:     `query ($n: Int) { org(login: "netflix") { repos(first: $n) { name } } }`,
+			variables: map[string]interface{}{"n": float64(1)},
+			want:      `{"data":{"org":{"repos":[{"name":"zuul"}]}}}`,
+		},
+		{
+			name:      "skip and include",
+			query:     `query ($yes: Boolean!) { org(login: "netflix") { login @skip(if: $yes) repos(first: 1) @include(if: $yes) { name } } }`,
+			variables: map[string]interface{}{"yes": true},
+			want:      `{"data":{"org":{"repos":[{"name":"zuul"}]}}}`,
+		},
+		{
+			name:  "resolver errors leave fields null",
+			query: `{ org(login: "netflix") { login broken } }`,
+			want:  `{"data":{"org":{"login":"netflix","broken":null}},"errors":[{"message":"broken","path":["org","broken"]}]}`,
+		},
+		{
+			name:      "variables of the wrong type",
+			query:     `query ($n: Int) { org(login: "netflix") { repos(first: $n) { name } } }`,
+			variables: map[string]interface{}{"n": "two"},
+			want:      `{"data":{"org":{"repos":null}},"errors":[{"message":"argument \"first\": expected Int, found two","path":["org","repos"]}]}`,
+		},
+		{
+			name:  "missing required variables",
+			query: `query ($login: String!) { org(login: $login) { login } }`,
+			want:  `{"errors":[{"message":"variable $login of type String! is required"}]}`,
+		},
+		{
+			name:  "operation name required with several operations",
+			query: `query A { org(login: "netflix") { login } } query B { org(login: "netflix") { login } }`,
+			want:  `{"errors":[{"message":"operationName is required for documents with several operations"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Execute(context.Background(), testSchema(), tt.query, tt.operation, tt.variables)
+			if got := marshal(t, resp); got != tt.want {
+				t.Errorf("Execute() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "unknown field",
+			query: `{ org(login: "netflix") { name } }`,
+			want:  []string{`cannot query field "name" on type "Org"`},
+		},
+		{
+			name:  "missing required argument",
+			query: `{ org { login } }`,
+			want:  []string{`field Query.org requires argument "login"`},
+		},
+		{
+			name:  "argument of the wrong type",
+			query: `{ org(login: "netflix") { repos(first: "two") { name } } }`,
+			want:  []string{`argument "first" on field Org.repos: expected Int, found two`},
+		},
+		{
+			name:  "enum value not allowed",
+			query: `{ org(login: "netflix") { repos(order: UP) { name } } }`,
+			want:  []string{`argument "order" on field Org.repos: expected one of ASC, DESC, found UP`},
+		},
+		{
+			name:  "undefined variable",
+			query: `{ org(login: $login) { login } }`,
+			want:  []string{`variable $login is not defined`},
+		},
+		{
+			name:  "subfields of scalars",
+			query: `{ org(login: "netflix") { login { length } } }`,
+			want:  []string{`field Org.login is a scalar and can't have a selection of subfields`},
+		},
+		{
+			name:  "objects without subfields",
+			query: `{ org(login: "netflix") }`,
+			want:  []string{`field Query.org of type Org must have a selection of subfields`},
+		},
+		{
+			name:  "unknown fragment",
+			query: `{ org(login: "netflix") { ...F } }`,
+			want:  []string{`unknown fragment "F"`},
+		},
+		{
+			name:  "fragment on another type",
+			query: `{ org(login: "netflix") { ...F } } fragment F on Repo { name }`,
+			want:  []string{`fragment "F" on Repo can't be spread on type Org`},
+		},
+		{
+			name:  "fragment on unknown type",
+			query: `{ org(login: "netflix") { login } } fragment F on Team { name }`,
+			want:  []string{`fragment "F" is on unknown type Team`},
+		},
+		{
+			name:  "invalid fragments are reported once however often spread",
+			query: `{ org(login: "netflix") { ...F ...F repos { owner { ...F } } } } fragment F on Org { name }`,
+			want:  []string{`cannot query field "name" on type "Org"`},
+		},
+		{
+			name:  "fragment spreading itself",
+			query: `{ org(login: "netflix") { ...F } } fragment F on Org { login ...F }`,
+			want:  []string{`fragment "F" spreads itself`},
+		},
+		{
+			name:  "fragments spreading each other",
+			query: `{ org(login: "netflix") { ...A } } fragment A on Org { repos { owner { ...B } } } fragment B on Org { ...A }`,
+			want:  []string{`fragment "A" spreads itself`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Execute(context.Background(), testSchema(), tt.query, "", nil)
+			if resp.Data != nil {
+				t.Errorf("Execute() data = %s, want none", marshal(t, resp))
+			}
+			var got []string
+			for _, err := range resp.Errors {
+				got = append(got, err.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Execute() errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+
+	deep := func(n int) string {
+		return `{ org(login: "netflix") {` + strings.Repeat(` repos(first: 1) { owner {`, n) + ` login` +
+			strings.Repeat(` } }`, n) + ` } }`
+	}
+	wide := func(n int) string {
+		var fields []string
+		for i := 0; i < n; i++ {
+			fields = append(fields, "l"+string(rune('a'+i%26))+strings.Repeat("x", i/26)+": login")
+		}
+		return `{ org(login: "netflix") { ` + strings.Join(fields, " ") + ` } }`
+	}
+	doubling := func(n int) string {
+		var b strings.Builder
+		b.WriteString(`{ org(login: "netflix") { ...F0 } }`)
+		for i := 0; i < n; i++ {
+			b.WriteString(" fragment F" + strconv.Itoa(i) + " on Org { ...F" + strconv.Itoa(i+1) + " ...F" + strconv.Itoa(i+1) + " }")
+		}
+		b.WriteString(" fragment F" + strconv.Itoa(n) + " on Org { login }")
+		return b.String()
+	}
+
+	tests := []struct {
+		name   string
+		schema *Schema
+		query  string
+		want   string
+	}{
+		{"depth within default", &Schema{}, deep(4), ""},
+		{"depth over default", &Schema{}, deep(5), "query is nested 12 levels deep, at most 10 are allowed"},
+		{"depth over schema limit", &Schema{MaxDepth: 3}, deep(1), "query is nested 4 levels deep, at most 3 are allowed"},
+		{"aliases within default", &Schema{}, wide(DefaultMaxSelections - 1), ""},
+		{"aliases over default", &Schema{}, wide(DefaultMaxSelections), "query selects more than 500 fields"},
+		{"aliases over schema limit", &Schema{MaxSelections: 10}, wide(10), "query selects more than 10 fields"},
+		{"fragments expanded within limit", &Schema{}, doubling(8), ""},
+		{"fragments expanded over limit", &Schema{}, doubling(9), "query selects more than 500 fields"},
+		{"fragments expanded exponentially", &Schema{}, doubling(200), "query selects more than 500 fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := testSchema()
+			schema.MaxDepth, schema.MaxSelections = tt.schema.MaxDepth, tt.schema.MaxSelections
+
+			resp := Execute(context.Background(), schema, tt.query, "", nil)
+			var got string
+			if len(resp.Errors) > 0 {
+				got = resp.Errors[0].Message
+			}
+			if got != tt.want {
+				t.Errorf("Execute() error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
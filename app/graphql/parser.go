@@ -0,0 +1,530 @@
+// Package graphql implements the subset of GraphQL needed to serve read only queries over cached
+// data: documents with queries and fragments are parsed, validated against a schema of object types
+// and executed by calling the resolvers of the fields selected
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document is a parsed GraphQL document
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query of a document. Only queries are supported
+type Operation struct {
+	Type       string
+	Name       string
+	Variables  []*VariableDefinition
+	Selections []Selection
+}
+
+// VariableDefinition declares a variable of an operation along with its default value
+type VariableDefinition struct {
+	Name       string
+	Type       string
+	Default    Value
+	HasDefault bool
+}
+
+// Selection is one of *Field, *FragmentSpread or *InlineFragment
+type Selection interface{}
+
+// Field selects a field of an object, under its alias if given
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  []*Argument
+	Directives []*Directive
+	Selections []Selection
+}
+
+// ResponseKey returns the key field is written under in the response
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Argument is a named value passed to a field or directive
+type Argument struct {
+	Name  string
+	Value Value
+}
+
+// Directive annotates a selection, @include and @skip are supported
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+}
+
+// FragmentSpread includes the selections of the named fragment
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment includes its selections when the object is of its type condition, if any
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	Selections    []Selection
+}
+
+// Fragment is a named set of selections on objects of its type condition
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []Selection
+}
+
+// Value is an argument value, one of string, int, float64, bool, nil, Enum, Variable, []Value or
+// map[string]Value
+type Value interface{}
+
+// Enum is an enum value written without quotes
+type Enum string
+
+// Variable refers to a variable of the operation by name
+type Variable string
+
+// SyntaxError reports a document which can't be parsed
+type SyntaxError struct {
+	Msg string
+	Pos int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lex splits src into tokens, dropping white space, commas and comments
+func lex(src string) ([]token, error) {
+
+	var tokens []token
+	for i := 0; i < len(src); {
+
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{kind: tokenPunct, value: "...", pos: i})
+			i += 3
+
+		case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+			tokens = append(tokens, token{kind: tokenPunct, value: string(c), pos: i})
+			i++
+
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenName, value: src[start:i], pos: start})
+
+		case c == '-' || isDigit(c):
+			start := i
+			kind := tokenInt
+			if c == '-' {
+				i++
+			}
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				kind = tokenFloat
+				for i++; i < len(src) && isDigit(src[i]); i++ {
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				kind = tokenFloat
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: kind, value: src[start:i], pos: start})
+
+		case strings.HasPrefix(src[i:], `"""`):
+			start := i
+			var value strings.Builder
+			for i += 3; ; i++ {
+				if i >= len(src) {
+					return nil, &SyntaxError{Msg: "unterminated block string", Pos: start}
+				}
+				if strings.HasPrefix(src[i:], `\"""`) {
+					value.WriteString(`"""`)
+					i += 3
+					continue
+				}
+				if strings.HasPrefix(src[i:], `"""`) {
+					i += 3
+					break
+				}
+				value.WriteByte(src[i])
+			}
+			tokens = append(tokens, token{kind: tokenString, value: strings.TrimSpace(value.String()), pos: start})
+
+		case c == '"':
+			start := i
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && (src[i] == '\n' || src[i] == '\r') {
+					return nil, &SyntaxError{Msg: "unterminated string", Pos: start}
+				}
+			}
+			if i >= len(src) {
+				return nil, &SyntaxError{Msg: "unterminated string", Pos: start}
+			}
+			i++
+
+			// escapes of GraphQL strings are those of json
+			var value string
+			if err := json.Unmarshal([]byte(src[start:i]), &value); err != nil {
+				return nil, &SyntaxError{Msg: "invalid string", Pos: start}
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, pos: start})
+
+		default:
+			return nil, &SyntaxError{Msg: fmt.Sprintf("unexpected character %q", c), Pos: i}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a GraphQL document
+func Parse(src string) (doc *Document, err error) {
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// parsing functions bail out by panicking with a syntax error, which is recovered here
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, syntaxErr
+		}
+	}()
+
+	p := &parser{tokens: tokens}
+	return p.parseDocument(), nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) fail(msg string) {
+	panic(&SyntaxError{Msg: msg, Pos: p.peek().pos})
+}
+
+// found describes the next token for syntax errors
+func (p *parser) found() string {
+	if p.peek().kind == tokenEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.peek().value)
+}
+
+// is reports whether the next token is the punctuator or name value
+func (p *parser) is(value string) bool {
+	t := p.peek()
+	return (t.kind == tokenPunct || t.kind == tokenName) && t.value == value
+}
+
+// skip consumes the next token if it is value, reporting whether it was
+func (p *parser) skip(value string) bool {
+	if p.is(value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) {
+	if !p.skip(value) {
+		p.fail(fmt.Sprintf("expected %q, found %s", value, p.found()))
+	}
+}
+
+func (p *parser) name() string {
+	if p.peek().kind != tokenName {
+		p.fail("expected name, found " + p.found())
+	}
+	return p.next().value
+}
+
+func (p *parser) parseDocument() *Document {
+
+	doc := &Document{Fragments: make(map[string]*Fragment)}
+
+	for p.peek().kind != tokenEOF {
+		switch {
+		case p.is("{"):
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: p.parseSelections()})
+
+		case p.is("query") || p.is("mutation") || p.is("subscription"):
+			doc.Operations = append(doc.Operations, p.parseOperation())
+
+		case p.is("fragment"):
+			fragment := p.parseFragment()
+			if _, ok := doc.Fragments[fragment.Name]; ok {
+				p.fail("fragment " + fragment.Name + " is defined more than once")
+			}
+			doc.Fragments[fragment.Name] = fragment
+
+		default:
+			p.fail("unexpected " + p.found())
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		p.fail("document has no operation")
+	}
+	return doc
+}
+
+func (p *parser) parseOperation() *Operation {
+
+	op := &Operation{Type: p.next().value}
+	if p.peek().kind == tokenName {
+		op.Name = p.name()
+	}
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			def := &VariableDefinition{Name: p.name()}
+			p.expect(":")
+			def.Type = p.parseType()
+			if p.skip("=") {
+				def.Default, def.HasDefault = p.parseValue(true), true
+			}
+			op.Variables = append(op.Variables, def)
+		}
+	}
+
+	p.parseDirectives()
+	op.Selections = p.parseSelections()
+	return op
+}
+
+// parseType returns the type of a variable as written
+func (p *parser) parseType() string {
+
+	var typ string
+	if p.skip("[") {
+		typ = "[" + p.parseType() + "]"
+		p.expect("]")
+	} else {
+		typ = p.name()
+	}
+	if p.skip("!") {
+		typ += "!"
+	}
+	return typ
+}
+
+func (p *parser) parseFragment() *Fragment {
+
+	p.expect("fragment")
+	fragment := &Fragment{Name: p.name()}
+	if fragment.Name == "on" {
+		p.fail("fragment can't be named on")
+	}
+	p.expect("on")
+	fragment.TypeCondition = p.name()
+	p.parseDirectives()
+	fragment.Selections = p.parseSelections()
+	return fragment
+}
+
+func (p *parser) parseSelections() []Selection {
+
+	p.expect("{")
+
+	var selections []Selection
+	for !p.skip("}") {
+
+		if p.skip("...") {
+			if p.peek().kind == tokenName && !p.is("on") {
+				selections = append(selections, &FragmentSpread{Name: p.name(), Directives: p.parseDirectives()})
+				continue
+			}
+
+			inline := &InlineFragment{}
+			if p.skip("on") {
+				inline.TypeCondition = p.name()
+			}
+			inline.Directives = p.parseDirectives()
+			inline.Selections = p.parseSelections()
+			selections = append(selections, inline)
+			continue
+		}
+
+		field := &Field{Name: p.name()}
+		if p.skip(":") {
+			field.Alias, field.Name = field.Name, p.name()
+		}
+		field.Arguments = p.parseArguments(false)
+		field.Directives = p.parseDirectives()
+		if p.is("{") {
+			field.Selections = p.parseSelections()
+		}
+		selections = append(selections, field)
+	}
+
+	if len(selections) == 0 {
+		p.fail("selection set is empty")
+	}
+	return selections
+}
+
+func (p *parser) parseArguments(constant bool) []*Argument {
+
+	var args []*Argument
+	if p.skip("(") {
+		for !p.skip(")") {
+			arg := &Argument{Name: p.name()}
+			p.expect(":")
+			arg.Value = p.parseValue(constant)
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+func (p *parser) parseDirectives() []*Directive {
+
+	var directives []*Directive
+	for p.skip("@") {
+		directives = append(directives, &Directive{Name: p.name(), Arguments: p.parseArguments(false)})
+	}
+	return directives
+}
+
+// parseValue parses a value, which may not refer to variables when constant
+func (p *parser) parseValue(constant bool) Value {
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenPunct && t.value == "$" && !constant:
+		p.next()
+		return Variable(p.name())
+
+	case t.kind == tokenPunct && t.value == "[":
+		p.next()
+		list := []Value{}
+		for !p.skip("]") {
+			list = append(list, p.parseValue(constant))
+		}
+		return list
+
+	case t.kind == tokenPunct && t.value == "{":
+		p.next()
+		object := make(map[string]Value)
+		for !p.skip("}") {
+			name := p.name()
+			p.expect(":")
+			object[name] = p.parseValue(constant)
+		}
+		return object
+
+	case t.kind == tokenInt:
+		n, err := strconv.Atoi(t.value)
+		if err != nil {
+			p.fail("invalid int " + t.value)
+		}
+		p.next()
+		return n
+
+	case t.kind == tokenFloat:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			p.fail("invalid float " + t.value)
+		}
+		p.next()
+		return f
+
+	case t.kind == tokenString:
+		p.next()
+		return t.value
+
+	case t.kind == tokenName:
+		p.next()
+		switch t.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return Enum(t.value)
+	}
+
+	p.fail("unexpected " + p.found())
+	return nil
+}
@@ -0,0 +1,124 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		query string
+		want  *Document
+	}{
+		{
+			name:  "shorthand query",
+			query: `{ org { login } }`,
+			want: &Document{
+				Operations: []*Operation{{
+					Type: "query",
+					Selections: []Selection{
+						&Field{Name: "org", Selections: []Selection{&Field{Name: "login"}}},
+					},
+				}},
+				Fragments: map[string]*Fragment{},
+			},
+		},
+		{
+			name:  "named query with variables, aliases, arguments and directives",
+			query: `query Q($login: String! = "netflix", $n: Int) { o: org(login: $login) @include(if: true) { repos(first: 5, type: PUBLIC) }}`,
+			want: &Document{
+				Operations: []*Operation{{
+					Type: "query",
+					Name: "Q",
+					Variables: []*VariableDefinition{
+						{Name: "login", Type: "String!", Default: "netflix", HasDefault: true},
+						{Name: "n", Type: "Int"},
+					},
+					Selections: []Selection{
+						&Field{
+							Alias:      "o",
+							Name:       "org",
+							Arguments:  []*Argument{{Name: "login", Value: Variable("login")}},
+							Directives: []*Directive{{Name: "include", Arguments: []*Argument{{Name: "if", Value: true}}}},
+							Selections: []Selection{
+								&Field{Name: "repos", Arguments: []*Argument{
+									{Name: "first", Value: 5},
+									{Name: "type", Value: Enum("PUBLIC")},
+								}},
+							},
+						},
+					},
+				}},
+				Fragments: map[string]*Fragment{},
+			},
+		},
+		{
+			name:  "fragments",
+			query: `{ org { ...F ... on Org { login } } } fragment F on Org { name }`,
+			want: &Document{
+				Operations: []*Operation{{
+					Type: "query",
+					Selections: []Selection{
+						&Field{Name: "org", Selections: []Selection{
+							&FragmentSpread{Name: "F"},
+							&InlineFragment{TypeCondition: "Org", Selections: []Selection{&Field{Name: "login"}}},
+						}},
+					},
+				}},
+				Fragments: map[string]*Fragment{
+					"F": {Name: "F", TypeCondition: "Org", Selections: []Selection{&Field{Name: "name"}}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(doc, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", doc, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"empty document", ``},
+		{"unclosed selection", `{ org { login }`},
+		{"empty selection", `{ }`},
+		{"missing argument value", `{ org(login:) { login } }`},
+		{"unterminated string", `{ org(login: "netflix) { login } }`},
+		{"unexpected character", `{ org % }`},
+		{"variable in default value", `query ($a: Int = $b) { org }`},
+		{"mutation without selection", `mutation`},
+		{"fragment without type condition", `{ org } fragment F { login }`},
+		{"duplicate fragment", `{ org } fragment F on Org { a } fragment F on Org { b }`},
+		{"spread without name", `{ org { ... } }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want syntax error", tt.query)
+			}
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Errorf("Parse(%q) error = %T %v, want *SyntaxError", tt.query, err, err)
+			}
+			if !strings.HasPrefix(err.Error(), "syntax error at ") {
+				t.Errorf("Parse(%q) error = %q, want position of error", tt.query, err.Error())
+			}
+		})
+	}
+}
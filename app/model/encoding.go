@@ -0,0 +1,102 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// EncodingIdentity marks data which is not compressed
+const EncodingIdentity = "identity"
+
+// valueMarker starts the header of compressed values, it never starts a json document. The header
+// is the marker, name of the encoding and the marker again, followed by the compressed bytes
+const valueMarker = 0
+
+// Compress compresses data with encoding, gzip or br
+func Compress(data []byte, encoding string) ([]byte, error) {
+
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+
+	switch encoding {
+	case config.EncodingGzip:
+		zw = gzip.NewWriter(&buf)
+	case config.EncodingBrotli:
+		zw = brotli.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress reverses Compress, identity data is returned as is
+func Decompress(data []byte, encoding string) ([]byte, error) {
+
+	var zr io.Reader
+
+	switch encoding {
+	case EncodingIdentity:
+		return data, nil
+	case config.EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		zr = gr
+	case config.EncodingBrotli:
+		zr = brotli.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	return ioutil.ReadAll(zr)
+}
+
+// encodeValue compresses data to be stored in redis as configured, prefixing it with a header naming
+// its encoding. Small values and values which don't shrink are stored as they are
+func encodeValue(data []byte) []byte {
+
+	conf := config.GetConfig().GetCacheConfig().Compression
+	if conf.GetStore() == config.EncodingNone || len(data) < conf.GetMinSize() {
+		return data
+	}
+
+	compressed, err := Compress(data, conf.GetStore())
+	if err != nil || len(compressed) >= len(data) {
+		return data
+	}
+
+	header := []byte{valueMarker}
+	header = append(header, conf.GetStore()...)
+	header = append(header, valueMarker)
+	return append(header, compressed...)
+}
+
+// splitValue returns the bytes of value read from redis without the header and their encoding
+func splitValue(value []byte) ([]byte, string) {
+
+	if len(value) == 0 || value[0] != valueMarker {
+		return value, EncodingIdentity
+	}
+
+	end := bytes.IndexByte(value[1:], valueMarker)
+	if end < 0 {
+		return value, EncodingIdentity
+	}
+	return value[end+2:], string(value[1 : end+1])
+}
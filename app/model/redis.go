@@ -1,18 +1,99 @@
 package model
 
 import (
+	"time"
+	"strconv"
+	"strings"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
 	"github.com/go-redis/redis"
 	"github.com/aniketalshi/go_rest_cache/config"
 )
 
+// metaKey is the redis hash holding book-keeping information for every cached key
+const metaKey = "cache-meta"
+
+// InternalPrefix is prepended to keys used by the server for its own purposes, they are never
+// reported as cached keys
+const InternalPrefix = "_internal:"
+
+// takeTokenScript refills the token bucket stored at KEYS[1] and takes a token out of it if available.
+// Returns whether a token was taken and the tokens left
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, tostring(tokens)}
+`)
+
 // DBClient maintains a redis connection and a shim layer on top of redis library
 type DBClient struct {
 	client *redis.Client
 }
 
+// KeyMeta holds the book-keeping information stored alongside each cached key
+type KeyMeta struct {
+	// UpdatedAt is when the key was last fetched
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// ModifiedAt is when the content of the key last changed
+	ModifiedAt time.Time `json:"modified_at,omitempty"`
+
+	// ETag is the content hash of the value
+	ETag string `json:"etag,omitempty"`
+}
+
+// KeyInfo describes a cached key for inspection by operators
+type KeyInfo struct {
+	Key       string    `json:"key"`
+	Type      string    `json:"type"`
+
+	// Size is the length in bytes of strings, the number of entries of hashes, sets, sorted sets and lists
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Age       string    `json:"age,omitempty"`
+	ETag      string    `json:"etag,omitempty"`
+
+	// TTL is negative for keys that never expire
+	TTL       string    `json:"ttl"`
+}
+
+// Age returns how long ago the key was last written
+func (km *KeyMeta) Age() time.Duration {
+	return time.Since(km.UpdatedAt)
+}
+
+// LastModified returns when the content of the key last changed, falling back to when it was
+// written for keys written before modification times were recorded
+func (km *KeyMeta) LastModified() time.Time {
+	if km.ModifiedAt.IsZero() {
+		return km.UpdatedAt
+	}
+	return km.ModifiedAt
+}
+
 // SetupDBCLient initializes client to talk to redis
 func SetupDBClient() *DBClient {
-	
+
 	db := &DBClient{
 		client: redis.NewClient(&redis.Options{
 		    Addr: config.GetConfig().GetRedisURL(),
@@ -24,13 +105,409 @@ func SetupDBClient() *DBClient {
 	return db
 }
 
-// Set sets the key in redis with provided data
+// Ping checks if redis instance is reachable
+func (db *DBClient) Ping() error {
+	return db.client.Ping().Err()
+}
+
+// ContentHash returns a stable hash of data suitable for use as an etag
+func ContentHash(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Set sets the key in redis with provided data and records when it was written along with its
+// content hash. Content unchanged since the previous write keeps its modification time
 func (db *DBClient) Set (key string, data []byte) {
-	db.client.Set(key, data, 0)
+	db.SetWithMeta(key, data, db.nextMeta(key, ContentHash(data)))
 }
 
-// Get retrieves the value corresponding to key in redis
+// Touch records that key, whose value is held in structures other than a plain string, was written
+// with content hashing to etag
+func (db *DBClient) Touch (key string, etag string) error {
+
+	js, err := json.Marshal(db.nextMeta(key, etag))
+	if err != nil {
+		return err
+	}
+	return db.client.HSet(metaKey, key, js).Err()
+}
+
+// TouchMany records that every key of etags was written with content hashing to its etag, like Touch
+func (db *DBClient) TouchMany (etags map[string]string) error {
+
+	if len(etags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(etags))
+	for key := range etags {
+		keys = append(keys, key)
+	}
+
+	prevs, err := db.client.HMGet(metaKey, keys...).Result()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	fields := make(map[string]interface{}, len(keys))
+	for i, key := range keys {
+
+		meta := KeyMeta{UpdatedAt: now, ModifiedAt: now, ETag: etags[key]}
+
+		prev := &KeyMeta{}
+		if content, ok := prevs[i].(string); ok && json.Unmarshal([]byte(content), prev) == nil && prev.ETag == meta.ETag {
+			meta.ModifiedAt = prev.LastModified()
+		}
+
+		js, err := json.Marshal(&meta)
+		if err != nil {
+			return err
+		}
+		fields[key] = js
+	}
+	return db.client.HMSet(metaKey, fields).Err()
+}
+
+// nextMeta returns book-keeping information for key written now with content hashing to etag.
+// Content unchanged since the previous write keeps its modification time
+func (db *DBClient) nextMeta (key string, etag string) KeyMeta {
+
+	now := time.Now().UTC()
+	meta := KeyMeta{UpdatedAt: now, ModifiedAt: now, ETag: etag}
+
+	if prev, err := db.GetMeta(key); err == nil && prev != nil && prev.ETag == meta.ETag {
+		meta.ModifiedAt = prev.LastModified()
+	}
+	return meta
+}
+
+// SetWithMeta sets the key in redis along with the provided book-keeping information.
+// Used when restoring data whose original write time must be preserved
+func (db *DBClient) SetWithMeta (key string, data []byte, meta KeyMeta) error {
+
+	if meta.ETag == "" {
+		meta.ETag = ContentHash(data)
+	}
+	if meta.ModifiedAt.IsZero() {
+		meta.ModifiedAt = meta.UpdatedAt
+	}
+
+	js, err := json.Marshal(&meta)
+	if err != nil {
+		return err
+	}
+
+	pipe := db.client.TxPipeline()
+	pipe.Set(key, encodeValue(data), 0)
+	pipe.HSet(metaKey, key, js)
+
+	_, err = pipe.Exec()
+	return err
+}
+
+// SetWithTTL sets the key in redis with provided data expiring after ttl, without any book-keeping.
+// Used for data private to a client which is not part of the shared cache
+func (db *DBClient) SetWithTTL (key string, data []byte, ttl time.Duration) error {
+	return db.client.Set(key, encodeValue(data), ttl).Err()
+}
+
+// Get retrieves the value corresponding to key in redis, decompressing it if it was stored compressed
 func (db *DBClient) Get (key string) []byte {
+
+	content, encoding := db.GetEncoded(key)
+
+	data, err := Decompress(content, encoding)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// GetEncoded retrieves the value corresponding to key in redis as it is stored, along with the
+// encoding it is compressed with. Lets compressed values be sent to clients without decompressing them
+func (db *DBClient) GetEncoded (key string) ([]byte, string) {
 	content, _ := db.client.Get(key).Bytes()
-	return content
+	return splitValue(content)
+}
+
+// GetMeta retrieves the book-keeping information for key. Returns nil if key was never written
+func (db *DBClient) GetMeta (key string) (*KeyMeta, error) {
+
+	content, err := db.client.HGet(metaKey, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &KeyMeta{}
+	if err := json.Unmarshal(content, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// Keys returns all cached keys matching the glob style pattern
+func (db *DBClient) Keys (pattern string) ([]string, error) {
+
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := db.client.Scan(cursor, pattern, 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range batch {
+			// book-keeping hash and internal keys are not cached keys
+			if key != metaKey && !strings.HasPrefix(key, InternalPrefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	return keys, nil
+}
+
+// Describe returns the type, size, age and ttl of key
+func (db *DBClient) Describe (key string) (*KeyInfo, error) {
+
+	kind, err := db.client.Type(key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	switch kind {
+	case "string":
+		size, err = db.client.StrLen(key).Result()
+	case "hash":
+		size, err = db.client.HLen(key).Result()
+	case "zset":
+		size, err = db.client.ZCard(key).Result()
+	case "set":
+		size, err = db.client.SCard(key).Result()
+	case "list":
+		size, err = db.client.LLen(key).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := db.client.TTL(key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &KeyInfo{Key: key, Type: kind, Size: size, TTL: ttl.String()}
+
+	meta, err := db.GetMeta(key)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		info.UpdatedAt = meta.UpdatedAt
+		info.Age = meta.Age().String()
+		info.ETag = meta.ETag
+	}
+	return info, nil
+}
+
+// Delete removes keys along with their book-keeping information. Returns number of keys removed
+func (db *DBClient) Delete (keys ...string) (int64, error) {
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	pipe := db.client.TxPipeline()
+	deleted := pipe.Del(keys...)
+	pipe.HDel(metaKey, keys...)
+
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	return deleted.Val(), nil
+}
+
+// DeleteValues removes keys but keeps their book-keeping information, for keys whose data has moved
+// to other structures
+func (db *DBClient) DeleteValues (keys ...string) error {
+	return db.client.Del(keys...).Err()
+}
+
+// TakeToken takes a token out of the bucket stored under key, refilling it at rate tokens per second
+// up to burst tokens. Returns whether a token was available and the tokens left
+func (db *DBClient) TakeToken (key string, rate float64, burst int) (bool, float64, error) {
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	result, err := takeTokenScript.Run(db.client, []string{InternalPrefix + key}, rate, burst, now).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("unexpected reply from token bucket script: %v", result)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokens, _ := values[1].(string)
+
+	remaining, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed == 1, remaining, nil
+}
+
+// HGet retrieves field of the hash stored at key, nil if it doesn't exist
+func (db *DBClient) HGet (key, field string) ([]byte, error) {
+
+	content, err := db.client.HGet(key, field).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return content, err
+}
+
+// HGetAll retrieves all fields of the hash stored at key
+func (db *DBClient) HGetAll (key string) (map[string]string, error) {
+	return db.client.HGetAll(key).Result()
+}
+
+// HSet sets field of the hash stored at key
+func (db *DBClient) HSet (key, field string, data []byte) error {
+	return db.client.HSet(key, field, data).Err()
+}
+
+// HDel removes fields of the hash stored at key
+func (db *DBClient) HDel (key string, fields ...string) error {
+	return db.client.HDel(key, fields...).Err()
+}
+
+// ScoredMember is a member of a sorted set along with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ReplaceHashes writes every hash in hashes, dropping fields they held before, and removes the
+// hashes at stale keys
+func (db *DBClient) ReplaceHashes (hashes map[string]map[string]interface{}, stale ...string) error {
+
+	pipe := db.client.TxPipeline()
+	for key, fields := range hashes {
+		pipe.Del(key)
+		pipe.HMSet(key, fields)
+	}
+	if len(stale) > 0 {
+		pipe.Del(stale...)
+	}
+
+	_, err := pipe.Exec()
+	return err
+}
+
+// SetMembers returns the members of the set stored at key
+func (db *DBClient) SetMembers (key string) ([]string, error) {
+	return db.client.SMembers(key).Result()
+}
+
+// ReplaceSet replaces the set stored at key with members
+func (db *DBClient) ReplaceSet (key string, members []string) error {
+
+	values := make([]interface{}, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+
+	pipe := db.client.TxPipeline()
+	pipe.Del(key)
+	if len(values) > 0 {
+		pipe.SAdd(key, values...)
+	}
+
+	_, err := pipe.Exec()
+	return err
+}
+
+// ReplaceSortedSet replaces the sorted set stored at key with members
+func (db *DBClient) ReplaceSortedSet (key string, members []ScoredMember) error {
+
+	zs := make([]redis.Z, len(members))
+	for i, member := range members {
+		zs[i] = redis.Z{Score: member.Score, Member: member.Member}
+	}
+
+	pipe := db.client.TxPipeline()
+	pipe.Del(key)
+	if len(zs) > 0 {
+		pipe.ZAdd(key, zs...)
+	}
+
+	_, err := pipe.Exec()
+	return err
+}
+
+// UnionSortedSets replaces the sorted set stored at dest with the union of the sorted sets at keys
+func (db *DBClient) UnionSortedSets (dest string, keys ...string) error {
+
+	if len(keys) == 0 {
+		return db.client.Del(dest).Err()
+	}
+	return db.client.ZUnionStore(dest, redis.ZStore{}, keys...).Err()
+}
+
+// UnionSortedSetsMax replaces the sorted set stored at dest with the union of the sorted sets at keys,
+// scoring members found in several of them by their highest score
+func (db *DBClient) UnionSortedSetsMax (dest string, keys ...string) error {
+
+	if len(keys) == 0 {
+		return db.client.Del(dest).Err()
+	}
+	return db.client.ZUnionStore(dest, redis.ZStore{Aggregate: "MAX"}, keys...).Err()
+}
+
+// AddScored adds members to the sorted set stored at key, updating scores of those already in it,
+// and trims it down to the keep members scored highest
+func (db *DBClient) AddScored (key string, members []ScoredMember, keep int) error {
+
+	zs := make([]redis.Z, len(members))
+	for i, member := range members {
+		zs[i] = redis.Z{Score: member.Score, Member: member.Member}
+	}
+
+	pipe := db.client.TxPipeline()
+	if len(zs) > 0 {
+		pipe.ZAdd(key, zs...)
+	}
+	pipe.ZRemRangeByRank(key, 0, -int64(keep) - 1)
+
+	_, err := pipe.Exec()
+	return err
+}
+
+// TopScored returns the first limit members of the sorted set stored at key, highest scores first
+func (db *DBClient) TopScored (key string, limit int) ([]ScoredMember, error) {
+
+	zs, err := db.client.ZRevRangeWithScores(key, 0, int64(limit) - 1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]ScoredMember, len(zs))
+	for i, z := range zs {
+		member, _ := z.Member.(string)
+		members[i] = ScoredMember{Member: member, Score: z.Score}
+	}
+	return members, nil
 }
@@ -0,0 +1,1382 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: cache.proto
+
+package rpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Inclusion tells whether repositories with a property are included, left out or the only ones returned
+type Inclusion int32
+
+const (
+	Inclusion_INCLUDE Inclusion = 0
+	Inclusion_ONLY    Inclusion = 1
+	Inclusion_EXCLUDE Inclusion = 2
+)
+
+var Inclusion_name = map[int32]string{
+	0: "INCLUDE",
+	1: "ONLY",
+	2: "EXCLUDE",
+}
+var Inclusion_value = map[string]int32{
+	"INCLUDE": 0,
+	"ONLY":    1,
+	"EXCLUDE": 2,
+}
+
+func (x Inclusion) String() string {
+	return proto.EnumName(Inclusion_name, int32(x))
+}
+func (Inclusion) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{0}
+}
+
+type Metric int32
+
+const (
+	Metric_STARS        Metric = 0
+	Metric_FORKS        Metric = 1
+	Metric_OPEN_ISSUES  Metric = 2
+	Metric_LAST_UPDATED Metric = 3
+)
+
+var Metric_name = map[int32]string{
+	0: "STARS",
+	1: "FORKS",
+	2: "OPEN_ISSUES",
+	3: "LAST_UPDATED",
+}
+var Metric_value = map[string]int32{
+	"STARS":        0,
+	"FORKS":        1,
+	"OPEN_ISSUES":  2,
+	"LAST_UPDATED": 3,
+}
+
+func (x Metric) String() string {
+	return proto.EnumName(Metric_name, int32(x))
+}
+func (Metric) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{1}
+}
+
+type GetOrgRequest struct {
+	Org                  string   `protobuf:"bytes,1,opt,name=org,proto3" json:"org,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetOrgRequest) Reset()         { *m = GetOrgRequest{} }
+func (m *GetOrgRequest) String() string { return proto.CompactTextString(m) }
+func (*GetOrgRequest) ProtoMessage()    {}
+func (*GetOrgRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{0}
+}
+func (m *GetOrgRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetOrgRequest.Unmarshal(m, b)
+}
+func (m *GetOrgRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetOrgRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetOrgRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetOrgRequest.Merge(dst, src)
+}
+func (m *GetOrgRequest) XXX_Size() int {
+	return xxx_messageInfo_GetOrgRequest.Size(m)
+}
+func (m *GetOrgRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetOrgRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetOrgRequest proto.InternalMessageInfo
+
+func (m *GetOrgRequest) GetOrg() string {
+	if m != nil {
+		return m.Org
+	}
+	return ""
+}
+
+type Org struct {
+	Login       string `protobuf:"bytes,1,opt,name=login,proto3" json:"login,omitempty"`
+	Id          int64  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Description string `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	HtmlUrl     string `protobuf:"bytes,5,opt,name=html_url,json=htmlUrl,proto3" json:"html_url,omitempty"`
+	AvatarUrl   string `protobuf:"bytes,6,opt,name=avatar_url,json=avatarUrl,proto3" json:"avatar_url,omitempty"`
+	PublicRepos int32  `protobuf:"varint,7,opt,name=public_repos,json=publicRepos,proto3" json:"public_repos,omitempty"`
+	Followers   int32  `protobuf:"varint,8,opt,name=followers,proto3" json:"followers,omitempty"`
+	CreatedAt   string `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt   string `protobuf:"bytes,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// json is the org as served by the http api
+	Json                 []byte   `protobuf:"bytes,11,opt,name=json,proto3" json:"json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Org) Reset()         { *m = Org{} }
+func (m *Org) String() string { return proto.CompactTextString(m) }
+func (*Org) ProtoMessage()    {}
+func (*Org) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{1}
+}
+func (m *Org) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Org.Unmarshal(m, b)
+}
+func (m *Org) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Org.Marshal(b, m, deterministic)
+}
+func (dst *Org) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Org.Merge(dst, src)
+}
+func (m *Org) XXX_Size() int {
+	return xxx_messageInfo_Org.Size(m)
+}
+func (m *Org) XXX_DiscardUnknown() {
+	xxx_messageInfo_Org.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Org proto.InternalMessageInfo
+
+func (m *Org) GetLogin() string {
+	if m != nil {
+		return m.Login
+	}
+	return ""
+}
+
+func (m *Org) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Org) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Org) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Org) GetHtmlUrl() string {
+	if m != nil {
+		return m.HtmlUrl
+	}
+	return ""
+}
+
+func (m *Org) GetAvatarUrl() string {
+	if m != nil {
+		return m.AvatarUrl
+	}
+	return ""
+}
+
+func (m *Org) GetPublicRepos() int32 {
+	if m != nil {
+		return m.PublicRepos
+	}
+	return 0
+}
+
+func (m *Org) GetFollowers() int32 {
+	if m != nil {
+		return m.Followers
+	}
+	return 0
+}
+
+func (m *Org) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+func (m *Org) GetUpdatedAt() string {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return ""
+}
+
+func (m *Org) GetJson() []byte {
+	if m != nil {
+		return m.Json
+	}
+	return nil
+}
+
+type Repo struct {
+	Id              int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name            string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	FullName        string   `protobuf:"bytes,3,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
+	Description     string   `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Language        string   `protobuf:"bytes,5,opt,name=language,proto3" json:"language,omitempty"`
+	HtmlUrl         string   `protobuf:"bytes,6,opt,name=html_url,json=htmlUrl,proto3" json:"html_url,omitempty"`
+	StargazersCount int64    `protobuf:"varint,7,opt,name=stargazers_count,json=stargazersCount,proto3" json:"stargazers_count,omitempty"`
+	ForksCount      int64    `protobuf:"varint,8,opt,name=forks_count,json=forksCount,proto3" json:"forks_count,omitempty"`
+	OpenIssuesCount int64    `protobuf:"varint,9,opt,name=open_issues_count,json=openIssuesCount,proto3" json:"open_issues_count,omitempty"`
+	Archived        bool     `protobuf:"varint,10,opt,name=archived,proto3" json:"archived,omitempty"`
+	Fork            bool     `protobuf:"varint,11,opt,name=fork,proto3" json:"fork,omitempty"`
+	Private         bool     `protobuf:"varint,12,opt,name=private,proto3" json:"private,omitempty"`
+	Topics          []string `protobuf:"bytes,13,rep,name=topics,proto3" json:"topics,omitempty"`
+	CreatedAt       string   `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt       string   `protobuf:"bytes,15,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	PushedAt        string   `protobuf:"bytes,16,opt,name=pushed_at,json=pushedAt,proto3" json:"pushed_at,omitempty"`
+	// json is the repository as served by the http api
+	Json                 []byte   `protobuf:"bytes,17,opt,name=json,proto3" json:"json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Repo) Reset()         { *m = Repo{} }
+func (m *Repo) String() string { return proto.CompactTextString(m) }
+func (*Repo) ProtoMessage()    {}
+func (*Repo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{2}
+}
+func (m *Repo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Repo.Unmarshal(m, b)
+}
+func (m *Repo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Repo.Marshal(b, m, deterministic)
+}
+func (dst *Repo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Repo.Merge(dst, src)
+}
+func (m *Repo) XXX_Size() int {
+	return xxx_messageInfo_Repo.Size(m)
+}
+func (m *Repo) XXX_DiscardUnknown() {
+	xxx_messageInfo_Repo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Repo proto.InternalMessageInfo
+
+func (m *Repo) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Repo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Repo) GetFullName() string {
+	if m != nil {
+		return m.FullName
+	}
+	return ""
+}
+
+func (m *Repo) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Repo) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *Repo) GetHtmlUrl() string {
+	if m != nil {
+		return m.HtmlUrl
+	}
+	return ""
+}
+
+func (m *Repo) GetStargazersCount() int64 {
+	if m != nil {
+		return m.StargazersCount
+	}
+	return 0
+}
+
+func (m *Repo) GetForksCount() int64 {
+	if m != nil {
+		return m.ForksCount
+	}
+	return 0
+}
+
+func (m *Repo) GetOpenIssuesCount() int64 {
+	if m != nil {
+		return m.OpenIssuesCount
+	}
+	return 0
+}
+
+func (m *Repo) GetArchived() bool {
+	if m != nil {
+		return m.Archived
+	}
+	return false
+}
+
+func (m *Repo) GetFork() bool {
+	if m != nil {
+		return m.Fork
+	}
+	return false
+}
+
+func (m *Repo) GetPrivate() bool {
+	if m != nil {
+		return m.Private
+	}
+	return false
+}
+
+func (m *Repo) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *Repo) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+func (m *Repo) GetUpdatedAt() string {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return ""
+}
+
+func (m *Repo) GetPushedAt() string {
+	if m != nil {
+		return m.PushedAt
+	}
+	return ""
+}
+
+func (m *Repo) GetJson() []byte {
+	if m != nil {
+		return m.Json
+	}
+	return nil
+}
+
+type Member struct {
+	Login     string `protobuf:"bytes,1,opt,name=login,proto3" json:"login,omitempty"`
+	Id        int64  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	AvatarUrl string `protobuf:"bytes,3,opt,name=avatar_url,json=avatarUrl,proto3" json:"avatar_url,omitempty"`
+	HtmlUrl   string `protobuf:"bytes,4,opt,name=html_url,json=htmlUrl,proto3" json:"html_url,omitempty"`
+	Type      string `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	SiteAdmin bool   `protobuf:"varint,6,opt,name=site_admin,json=siteAdmin,proto3" json:"site_admin,omitempty"`
+	// json is the member as served by the http api
+	Json                 []byte   `protobuf:"bytes,7,opt,name=json,proto3" json:"json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Member) Reset()         { *m = Member{} }
+func (m *Member) String() string { return proto.CompactTextString(m) }
+func (*Member) ProtoMessage()    {}
+func (*Member) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{3}
+}
+func (m *Member) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Member.Unmarshal(m, b)
+}
+func (m *Member) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Member.Marshal(b, m, deterministic)
+}
+func (dst *Member) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Member.Merge(dst, src)
+}
+func (m *Member) XXX_Size() int {
+	return xxx_messageInfo_Member.Size(m)
+}
+func (m *Member) XXX_DiscardUnknown() {
+	xxx_messageInfo_Member.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Member proto.InternalMessageInfo
+
+func (m *Member) GetLogin() string {
+	if m != nil {
+		return m.Login
+	}
+	return ""
+}
+
+func (m *Member) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Member) GetAvatarUrl() string {
+	if m != nil {
+		return m.AvatarUrl
+	}
+	return ""
+}
+
+func (m *Member) GetHtmlUrl() string {
+	if m != nil {
+		return m.HtmlUrl
+	}
+	return ""
+}
+
+func (m *Member) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Member) GetSiteAdmin() bool {
+	if m != nil {
+		return m.SiteAdmin
+	}
+	return false
+}
+
+func (m *Member) GetJson() []byte {
+	if m != nil {
+		return m.Json
+	}
+	return nil
+}
+
+// RepoFilter narrows repositories down, empty fields don't filter
+type RepoFilter struct {
+	Language             string    `protobuf:"bytes,1,opt,name=language,proto3" json:"language,omitempty"`
+	Topic                string    `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	Archived             Inclusion `protobuf:"varint,3,opt,name=archived,proto3,enum=gorestcache.v1.Inclusion" json:"archived,omitempty"`
+	Forks                Inclusion `protobuf:"varint,4,opt,name=forks,proto3,enum=gorestcache.v1.Inclusion" json:"forks,omitempty"`
+	MinStars             int64     `protobuf:"varint,5,opt,name=min_stars,json=minStars,proto3" json:"min_stars,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *RepoFilter) Reset()         { *m = RepoFilter{} }
+func (m *RepoFilter) String() string { return proto.CompactTextString(m) }
+func (*RepoFilter) ProtoMessage()    {}
+func (*RepoFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{4}
+}
+func (m *RepoFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RepoFilter.Unmarshal(m, b)
+}
+func (m *RepoFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RepoFilter.Marshal(b, m, deterministic)
+}
+func (dst *RepoFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RepoFilter.Merge(dst, src)
+}
+func (m *RepoFilter) XXX_Size() int {
+	return xxx_messageInfo_RepoFilter.Size(m)
+}
+func (m *RepoFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_RepoFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RepoFilter proto.InternalMessageInfo
+
+func (m *RepoFilter) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *RepoFilter) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *RepoFilter) GetArchived() Inclusion {
+	if m != nil {
+		return m.Archived
+	}
+	return Inclusion_INCLUDE
+}
+
+func (m *RepoFilter) GetForks() Inclusion {
+	if m != nil {
+		return m.Forks
+	}
+	return Inclusion_INCLUDE
+}
+
+func (m *RepoFilter) GetMinStars() int64 {
+	if m != nil {
+		return m.MinStars
+	}
+	return 0
+}
+
+type ListReposRequest struct {
+	Org string `protobuf:"bytes,1,opt,name=org,proto3" json:"org,omitempty"`
+	// type, sort and direction are those of the org repos endpoint
+	Type      string      `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Sort      string      `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	Direction string      `protobuf:"bytes,4,opt,name=direction,proto3" json:"direction,omitempty"`
+	Filter    *RepoFilter `protobuf:"bytes,5,opt,name=filter,proto3" json:"filter,omitempty"`
+	// limit of zero returns every repository from offset on
+	Limit                int32    `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset               int32    `protobuf:"varint,7,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListReposRequest) Reset()         { *m = ListReposRequest{} }
+func (m *ListReposRequest) String() string { return proto.CompactTextString(m) }
+func (*ListReposRequest) ProtoMessage()    {}
+func (*ListReposRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{5}
+}
+func (m *ListReposRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListReposRequest.Unmarshal(m, b)
+}
+func (m *ListReposRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListReposRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListReposRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListReposRequest.Merge(dst, src)
+}
+func (m *ListReposRequest) XXX_Size() int {
+	return xxx_messageInfo_ListReposRequest.Size(m)
+}
+func (m *ListReposRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListReposRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListReposRequest proto.InternalMessageInfo
+
+func (m *ListReposRequest) GetOrg() string {
+	if m != nil {
+		return m.Org
+	}
+	return ""
+}
+
+func (m *ListReposRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *ListReposRequest) GetSort() string {
+	if m != nil {
+		return m.Sort
+	}
+	return ""
+}
+
+func (m *ListReposRequest) GetDirection() string {
+	if m != nil {
+		return m.Direction
+	}
+	return ""
+}
+
+func (m *ListReposRequest) GetFilter() *RepoFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+func (m *ListReposRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *ListReposRequest) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type ListReposResponse struct {
+	Repos []*Repo `protobuf:"bytes,1,rep,name=repos,proto3" json:"repos,omitempty"`
+	// total is the number of repositories matching before limit and offset are applied
+	Total                int32    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListReposResponse) Reset()         { *m = ListReposResponse{} }
+func (m *ListReposResponse) String() string { return proto.CompactTextString(m) }
+func (*ListReposResponse) ProtoMessage()    {}
+func (*ListReposResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{6}
+}
+func (m *ListReposResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListReposResponse.Unmarshal(m, b)
+}
+func (m *ListReposResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListReposResponse.Marshal(b, m, deterministic)
+}
+func (dst *ListReposResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListReposResponse.Merge(dst, src)
+}
+func (m *ListReposResponse) XXX_Size() int {
+	return xxx_messageInfo_ListReposResponse.Size(m)
+}
+func (m *ListReposResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListReposResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListReposResponse proto.InternalMessageInfo
+
+func (m *ListReposResponse) GetRepos() []*Repo {
+	if m != nil {
+		return m.Repos
+	}
+	return nil
+}
+
+func (m *ListReposResponse) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+type ListMembersRequest struct {
+	Org                  string   `protobuf:"bytes,1,opt,name=org,proto3" json:"org,omitempty"`
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset               int32    `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListMembersRequest) Reset()         { *m = ListMembersRequest{} }
+func (m *ListMembersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListMembersRequest) ProtoMessage()    {}
+func (*ListMembersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{7}
+}
+func (m *ListMembersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListMembersRequest.Unmarshal(m, b)
+}
+func (m *ListMembersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListMembersRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListMembersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListMembersRequest.Merge(dst, src)
+}
+func (m *ListMembersRequest) XXX_Size() int {
+	return xxx_messageInfo_ListMembersRequest.Size(m)
+}
+func (m *ListMembersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListMembersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListMembersRequest proto.InternalMessageInfo
+
+func (m *ListMembersRequest) GetOrg() string {
+	if m != nil {
+		return m.Org
+	}
+	return ""
+}
+
+func (m *ListMembersRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *ListMembersRequest) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type ListMembersResponse struct {
+	Members              []*Member `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
+	Total                int32     `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ListMembersResponse) Reset()         { *m = ListMembersResponse{} }
+func (m *ListMembersResponse) String() string { return proto.CompactTextString(m) }
+func (*ListMembersResponse) ProtoMessage()    {}
+func (*ListMembersResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{8}
+}
+func (m *ListMembersResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListMembersResponse.Unmarshal(m, b)
+}
+func (m *ListMembersResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListMembersResponse.Marshal(b, m, deterministic)
+}
+func (dst *ListMembersResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListMembersResponse.Merge(dst, src)
+}
+func (m *ListMembersResponse) XXX_Size() int {
+	return xxx_messageInfo_ListMembersResponse.Size(m)
+}
+func (m *ListMembersResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListMembersResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListMembersResponse proto.InternalMessageInfo
+
+func (m *ListMembersResponse) GetMembers() []*Member {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *ListMembersResponse) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+type GetTopReposRequest struct {
+	Metric Metric `protobuf:"varint,1,opt,name=metric,proto3,enum=gorestcache.v1.Metric" json:"metric,omitempty"`
+	N      int32  `protobuf:"varint,2,opt,name=n,proto3" json:"n,omitempty"`
+	// org whose view is returned, views aggregated across orgs if empty
+	Org                  string      `protobuf:"bytes,3,opt,name=org,proto3" json:"org,omitempty"`
+	Filter               *RepoFilter `protobuf:"bytes,4,opt,name=filter,proto3" json:"filter,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *GetTopReposRequest) Reset()         { *m = GetTopReposRequest{} }
+func (m *GetTopReposRequest) String() string { return proto.CompactTextString(m) }
+func (*GetTopReposRequest) ProtoMessage()    {}
+func (*GetTopReposRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{9}
+}
+func (m *GetTopReposRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetTopReposRequest.Unmarshal(m, b)
+}
+func (m *GetTopReposRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetTopReposRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetTopReposRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetTopReposRequest.Merge(dst, src)
+}
+func (m *GetTopReposRequest) XXX_Size() int {
+	return xxx_messageInfo_GetTopReposRequest.Size(m)
+}
+func (m *GetTopReposRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetTopReposRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetTopReposRequest proto.InternalMessageInfo
+
+func (m *GetTopReposRequest) GetMetric() Metric {
+	if m != nil {
+		return m.Metric
+	}
+	return Metric_STARS
+}
+
+func (m *GetTopReposRequest) GetN() int32 {
+	if m != nil {
+		return m.N
+	}
+	return 0
+}
+
+func (m *GetTopReposRequest) GetOrg() string {
+	if m != nil {
+		return m.Org
+	}
+	return ""
+}
+
+func (m *GetTopReposRequest) GetFilter() *RepoFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+type RankedRepo struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// value of the metric as served by views of the http api
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Repo                 *Repo    `protobuf:"bytes,3,opt,name=repo,proto3" json:"repo,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RankedRepo) Reset()         { *m = RankedRepo{} }
+func (m *RankedRepo) String() string { return proto.CompactTextString(m) }
+func (*RankedRepo) ProtoMessage()    {}
+func (*RankedRepo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{10}
+}
+func (m *RankedRepo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RankedRepo.Unmarshal(m, b)
+}
+func (m *RankedRepo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RankedRepo.Marshal(b, m, deterministic)
+}
+func (dst *RankedRepo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RankedRepo.Merge(dst, src)
+}
+func (m *RankedRepo) XXX_Size() int {
+	return xxx_messageInfo_RankedRepo.Size(m)
+}
+func (m *RankedRepo) XXX_DiscardUnknown() {
+	xxx_messageInfo_RankedRepo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RankedRepo proto.InternalMessageInfo
+
+func (m *RankedRepo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *RankedRepo) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *RankedRepo) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+type GetTopReposResponse struct {
+	Repos                []*RankedRepo `protobuf:"bytes,1,rep,name=repos,proto3" json:"repos,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *GetTopReposResponse) Reset()         { *m = GetTopReposResponse{} }
+func (m *GetTopReposResponse) String() string { return proto.CompactTextString(m) }
+func (*GetTopReposResponse) ProtoMessage()    {}
+func (*GetTopReposResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{11}
+}
+func (m *GetTopReposResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetTopReposResponse.Unmarshal(m, b)
+}
+func (m *GetTopReposResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetTopReposResponse.Marshal(b, m, deterministic)
+}
+func (dst *GetTopReposResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetTopReposResponse.Merge(dst, src)
+}
+func (m *GetTopReposResponse) XXX_Size() int {
+	return xxx_messageInfo_GetTopReposResponse.Size(m)
+}
+func (m *GetTopReposResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetTopReposResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetTopReposResponse proto.InternalMessageInfo
+
+func (m *GetTopReposResponse) GetRepos() []*RankedRepo {
+	if m != nil {
+		return m.Repos
+	}
+	return nil
+}
+
+type WatchUpdatesRequest struct {
+	// prefixes of keys to watch, every key if empty
+	Prefixes             []string `protobuf:"bytes,1,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchUpdatesRequest) Reset()         { *m = WatchUpdatesRequest{} }
+func (m *WatchUpdatesRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchUpdatesRequest) ProtoMessage()    {}
+func (*WatchUpdatesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{12}
+}
+func (m *WatchUpdatesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchUpdatesRequest.Unmarshal(m, b)
+}
+func (m *WatchUpdatesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchUpdatesRequest.Marshal(b, m, deterministic)
+}
+func (dst *WatchUpdatesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchUpdatesRequest.Merge(dst, src)
+}
+func (m *WatchUpdatesRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchUpdatesRequest.Size(m)
+}
+func (m *WatchUpdatesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchUpdatesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchUpdatesRequest proto.InternalMessageInfo
+
+func (m *WatchUpdatesRequest) GetPrefixes() []string {
+	if m != nil {
+		return m.Prefixes
+	}
+	return nil
+}
+
+type Update struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Etag                 string   `protobuf:"bytes,2,opt,name=etag,proto3" json:"etag,omitempty"`
+	ModifiedAt           string   `protobuf:"bytes,3,opt,name=modified_at,json=modifiedAt,proto3" json:"modified_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Update) Reset()         { *m = Update{} }
+func (m *Update) String() string { return proto.CompactTextString(m) }
+func (*Update) ProtoMessage()    {}
+func (*Update) Descriptor() ([]byte, []int) {
+	return fileDescriptor_cache_a0569d8f0a5f157b, []int{13}
+}
+func (m *Update) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Update.Unmarshal(m, b)
+}
+func (m *Update) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Update.Marshal(b, m, deterministic)
+}
+func (dst *Update) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Update.Merge(dst, src)
+}
+func (m *Update) XXX_Size() int {
+	return xxx_messageInfo_Update.Size(m)
+}
+func (m *Update) XXX_DiscardUnknown() {
+	xxx_messageInfo_Update.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Update proto.InternalMessageInfo
+
+func (m *Update) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Update) GetEtag() string {
+	if m != nil {
+		return m.Etag
+	}
+	return ""
+}
+
+func (m *Update) GetModifiedAt() string {
+	if m != nil {
+		return m.ModifiedAt
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*GetOrgRequest)(nil), "gorestcache.v1.GetOrgRequest")
+	proto.RegisterType((*Org)(nil), "gorestcache.v1.Org")
+	proto.RegisterType((*Repo)(nil), "gorestcache.v1.Repo")
+	proto.RegisterType((*Member)(nil), "gorestcache.v1.Member")
+	proto.RegisterType((*RepoFilter)(nil), "gorestcache.v1.RepoFilter")
+	proto.RegisterType((*ListReposRequest)(nil), "gorestcache.v1.ListReposRequest")
+	proto.RegisterType((*ListReposResponse)(nil), "gorestcache.v1.ListReposResponse")
+	proto.RegisterType((*ListMembersRequest)(nil), "gorestcache.v1.ListMembersRequest")
+	proto.RegisterType((*ListMembersResponse)(nil), "gorestcache.v1.ListMembersResponse")
+	proto.RegisterType((*GetTopReposRequest)(nil), "gorestcache.v1.GetTopReposRequest")
+	proto.RegisterType((*RankedRepo)(nil), "gorestcache.v1.RankedRepo")
+	proto.RegisterType((*GetTopReposResponse)(nil), "gorestcache.v1.GetTopReposResponse")
+	proto.RegisterType((*WatchUpdatesRequest)(nil), "gorestcache.v1.WatchUpdatesRequest")
+	proto.RegisterType((*Update)(nil), "gorestcache.v1.Update")
+	proto.RegisterEnum("gorestcache.v1.Inclusion", Inclusion_name, Inclusion_value)
+	proto.RegisterEnum("gorestcache.v1.Metric", Metric_name, Metric_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// CacheClient is the client API for Cache service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CacheClient interface {
+	// GetOrg returns the cached org endpoint
+	GetOrg(ctx context.Context, in *GetOrgRequest, opts ...grpc.CallOption) (*Org, error)
+	// ListRepos returns cached repositories of an org, filtered and sorted like the org repos endpoint
+	ListRepos(ctx context.Context, in *ListReposRequest, opts ...grpc.CallOption) (*ListReposResponse, error)
+	// ListMembers returns cached members of an org
+	ListMembers(ctx context.Context, in *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error)
+	// GetTopRepos returns the first n repositories of a view, of an org or aggregated across orgs
+	GetTopRepos(ctx context.Context, in *GetTopReposRequest, opts ...grpc.CallOption) (*GetTopReposResponse, error)
+	// WatchUpdates streams an update every time content of a cached key or a view changes
+	WatchUpdates(ctx context.Context, in *WatchUpdatesRequest, opts ...grpc.CallOption) (Cache_WatchUpdatesClient, error)
+}
+
+type cacheClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCacheClient(cc *grpc.ClientConn) CacheClient {
+	return &cacheClient{cc}
+}
+
+func (c *cacheClient) GetOrg(ctx context.Context, in *GetOrgRequest, opts ...grpc.CallOption) (*Org, error) {
+	out := new(Org)
+	err := c.cc.Invoke(ctx, "/gorestcache.v1.Cache/GetOrg", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheClient) ListRepos(ctx context.Context, in *ListReposRequest, opts ...grpc.CallOption) (*ListReposResponse, error) {
+	out := new(ListReposResponse)
+	err := c.cc.Invoke(ctx, "/gorestcache.v1.Cache/ListRepos", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheClient) ListMembers(ctx context.Context, in *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error) {
+	out := new(ListMembersResponse)
+	err := c.cc.Invoke(ctx, "/gorestcache.v1.Cache/ListMembers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheClient) GetTopRepos(ctx context.Context, in *GetTopReposRequest, opts ...grpc.CallOption) (*GetTopReposResponse, error) {
+	out := new(GetTopReposResponse)
+	err := c.cc.Invoke(ctx, "/gorestcache.v1.Cache/GetTopRepos", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheClient) WatchUpdates(ctx context.Context, in *WatchUpdatesRequest, opts ...grpc.CallOption) (Cache_WatchUpdatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Cache_serviceDesc.Streams[0], "/gorestcache.v1.Cache/WatchUpdates", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cacheWatchUpdatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Cache_WatchUpdatesClient interface {
+	Recv() (*Update, error)
+	grpc.ClientStream
+}
+
+type cacheWatchUpdatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *cacheWatchUpdatesClient) Recv() (*Update, error) {
+	m := new(Update)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CacheServer is the server API for Cache service.
+type CacheServer interface {
+	// GetOrg returns the cached org endpoint
+	GetOrg(context.Context, *GetOrgRequest) (*Org, error)
+	// ListRepos returns cached repositories of an org, filtered and sorted like the org repos endpoint
+	ListRepos(context.Context, *ListReposRequest) (*ListReposResponse, error)
+	// ListMembers returns cached members of an org
+	ListMembers(context.Context, *ListMembersRequest) (*ListMembersResponse, error)
+	// GetTopRepos returns the first n repositories of a view, of an org or aggregated across orgs
+	GetTopRepos(context.Context, *GetTopReposRequest) (*GetTopReposResponse, error)
+	// WatchUpdates streams an update every time content of a cached key or a view changes
+	WatchUpdates(*WatchUpdatesRequest, Cache_WatchUpdatesServer) error
+}
+
+func RegisterCacheServer(s *grpc.Server, srv CacheServer) {
+	s.RegisterService(&_Cache_serviceDesc, srv)
+}
+
+func _Cache_GetOrg_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrgRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServer).GetOrg(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gorestcache.v1.Cache/GetOrg",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServer).GetOrg(ctx, req.(*GetOrgRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cache_ListRepos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReposRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServer).ListRepos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gorestcache.v1.Cache/ListRepos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServer).ListRepos(ctx, req.(*ListReposRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cache_ListMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMembersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServer).ListMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gorestcache.v1.Cache/ListMembers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServer).ListMembers(ctx, req.(*ListMembersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cache_GetTopRepos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopReposRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServer).GetTopRepos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gorestcache.v1.Cache/GetTopRepos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServer).GetTopRepos(ctx, req.(*GetTopReposRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cache_WatchUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchUpdatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CacheServer).WatchUpdates(m, &cacheWatchUpdatesServer{stream})
+}
+
+type Cache_WatchUpdatesServer interface {
+	Send(*Update) error
+	grpc.ServerStream
+}
+
+type cacheWatchUpdatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *cacheWatchUpdatesServer) Send(m *Update) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Cache_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gorestcache.v1.Cache",
+	HandlerType: (*CacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetOrg",
+			Handler:    _Cache_GetOrg_Handler,
+		},
+		{
+			MethodName: "ListRepos",
+			Handler:    _Cache_ListRepos_Handler,
+		},
+		{
+			MethodName: "ListMembers",
+			Handler:    _Cache_ListMembers_Handler,
+		},
+		{
+			MethodName: "GetTopRepos",
+			Handler:    _Cache_GetTopRepos_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchUpdates",
+			Handler:       _Cache_WatchUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cache.proto",
+}
+
+func init() { proto.RegisterFile("cache.proto", fileDescriptor_cache_a0569d8f0a5f157b) }
+
+var fileDescriptor_cache_a0569d8f0a5f157b = []byte{
+	// 1094 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xcf, 0x52, 0xe3, 0xc6,
+	0x13, 0x5e, 0x59, 0x96, 0x2d, 0xb5, 0xbd, 0x20, 0x06, 0x6a, 0x4b, 0xeb, 0xfd, 0x6d, 0xfd, 0x8c,
+	0xf6, 0xe2, 0x70, 0x60, 0x77, 0x49, 0xe5, 0x96, 0x8b, 0x03, 0x2c, 0x45, 0x85, 0xc5, 0xd4, 0x18,
+	0xe7, 0x5f, 0x55, 0xca, 0x11, 0xf2, 0xd8, 0x4c, 0x90, 0x25, 0x65, 0x34, 0x22, 0x21, 0x0f, 0x92,
+	0xca, 0x53, 0x24, 0x0f, 0x91, 0x6b, 0x5e, 0x24, 0x6f, 0x91, 0x9a, 0x19, 0x49, 0x96, 0x64, 0x20,
+	0xdc, 0xa6, 0xbf, 0xee, 0x69, 0xf7, 0x7c, 0x5f, 0x77, 0x5b, 0xd0, 0xf1, 0x3d, 0xff, 0x9a, 0xec,
+	0xc7, 0x2c, 0xe2, 0x11, 0xda, 0x58, 0x44, 0x8c, 0x24, 0x5c, 0x41, 0xb7, 0xef, 0xdd, 0x5d, 0x78,
+	0x7e, 0x42, 0xf8, 0x88, 0x2d, 0x30, 0xf9, 0x29, 0x25, 0x09, 0x47, 0x36, 0xe8, 0x11, 0x5b, 0x38,
+	0x5a, 0x5f, 0x1b, 0x58, 0x58, 0x1c, 0xdd, 0x3f, 0x1a, 0xa0, 0x8f, 0xd8, 0x02, 0xed, 0x80, 0x11,
+	0x44, 0x0b, 0x1a, 0x66, 0x3e, 0x65, 0xa0, 0x0d, 0x68, 0xd0, 0x99, 0xd3, 0xe8, 0x6b, 0x03, 0x1d,
+	0x37, 0xe8, 0x0c, 0x21, 0x68, 0x86, 0xde, 0x92, 0x38, 0xba, 0x0c, 0x92, 0x67, 0xd4, 0x87, 0xce,
+	0x8c, 0x24, 0x3e, 0xa3, 0x31, 0xa7, 0x51, 0xe8, 0x34, 0xa5, 0xab, 0x0c, 0xa1, 0x97, 0x60, 0x5e,
+	0xf3, 0x65, 0x30, 0x4d, 0x59, 0xe0, 0x18, 0xd2, 0xdd, 0x16, 0xf6, 0x84, 0x05, 0xe8, 0x35, 0x80,
+	0x77, 0xeb, 0x71, 0x8f, 0x49, 0x67, 0x4b, 0x3a, 0x2d, 0x85, 0x08, 0xf7, 0x2e, 0x74, 0xe3, 0xf4,
+	0x2a, 0xa0, 0xfe, 0x94, 0x91, 0x38, 0x4a, 0x9c, 0x76, 0x5f, 0x1b, 0x18, 0xb8, 0xa3, 0x30, 0x2c,
+	0x20, 0xf4, 0x3f, 0xb0, 0xe6, 0x51, 0x10, 0x44, 0x3f, 0x13, 0x96, 0x38, 0xa6, 0xf4, 0xaf, 0x00,
+	0x91, 0xdf, 0x67, 0xc4, 0xe3, 0x64, 0x36, 0xf5, 0xb8, 0x63, 0xa9, 0xfc, 0x19, 0x32, 0xe4, 0xc2,
+	0x9d, 0xc6, 0xb3, 0xdc, 0x0d, 0xca, 0x9d, 0x21, 0x43, 0x2e, 0x9e, 0xfb, 0x63, 0x12, 0x85, 0x4e,
+	0xa7, 0xaf, 0x0d, 0xba, 0x58, 0x9e, 0xdd, 0x7f, 0x74, 0x68, 0x8a, 0x5f, 0xce, 0xb8, 0xd1, 0xd6,
+	0xb8, 0x69, 0x94, 0xb8, 0x79, 0x05, 0xd6, 0x3c, 0x0d, 0x82, 0x69, 0x89, 0x34, 0x53, 0x00, 0xe7,
+	0x4f, 0x23, 0xae, 0x07, 0x66, 0xe0, 0x85, 0x8b, 0xd4, 0x5b, 0x90, 0x8c, 0xb8, 0xc2, 0xae, 0x90,
+	0xda, 0xaa, 0x92, 0xfa, 0x09, 0xd8, 0x09, 0xf7, 0xd8, 0xc2, 0xfb, 0x95, 0xb0, 0x64, 0xea, 0x47,
+	0x69, 0xc8, 0x25, 0x73, 0x3a, 0xde, 0x5c, 0xe1, 0x87, 0x02, 0x46, 0xff, 0x87, 0xce, 0x3c, 0x62,
+	0x37, 0x79, 0x94, 0x29, 0xa3, 0x40, 0x42, 0x2a, 0x60, 0x0f, 0xb6, 0xa2, 0x98, 0x84, 0x53, 0x9a,
+	0x24, 0x29, 0xc9, 0xc3, 0x2c, 0x95, 0x4c, 0x38, 0x4e, 0x25, 0xae, 0x62, 0x7b, 0x60, 0x7a, 0xcc,
+	0xbf, 0xa6, 0xb7, 0x64, 0x26, 0xb9, 0x34, 0x71, 0x61, 0x0b, 0x76, 0x44, 0x56, 0x49, 0xa5, 0x89,
+	0xe5, 0x19, 0x39, 0xd0, 0x8e, 0x19, 0xbd, 0xf5, 0x38, 0x71, 0xba, 0x12, 0xce, 0x4d, 0xf4, 0x02,
+	0x5a, 0x3c, 0x8a, 0xa9, 0x9f, 0x38, 0xcf, 0xfb, 0xfa, 0xc0, 0xc2, 0x99, 0x55, 0x93, 0x73, 0xe3,
+	0x71, 0x39, 0x37, 0xeb, 0x72, 0xbe, 0x02, 0x2b, 0x4e, 0x93, 0x6b, 0xe5, 0xb5, 0x15, 0x9f, 0x0a,
+	0x28, 0x69, 0xbd, 0x55, 0xd2, 0xfa, 0x4f, 0x0d, 0x5a, 0x1f, 0xc9, 0xf2, 0x8a, 0xb0, 0x27, 0xce,
+	0x47, 0xb5, 0x9d, 0xf5, 0x7a, 0x3b, 0x97, 0x35, 0x6b, 0x56, 0x35, 0x43, 0xd0, 0xe4, 0x77, 0x71,
+	0x2e, 0xb3, 0x3c, 0x8b, 0x6c, 0x09, 0xe5, 0x64, 0xea, 0xcd, 0x96, 0x34, 0x94, 0x22, 0x9b, 0xd8,
+	0x12, 0xc8, 0x50, 0x00, 0x45, 0xc5, 0xed, 0x52, 0xc5, 0x7f, 0x69, 0x00, 0xa2, 0x3b, 0x3f, 0xd0,
+	0x80, 0x13, 0x56, 0x69, 0x20, 0xad, 0xd6, 0x40, 0x3b, 0x60, 0x48, 0x56, 0xb3, 0x86, 0x55, 0x06,
+	0xfa, 0xac, 0xa4, 0xa1, 0xa8, 0x7f, 0xe3, 0xe0, 0xe5, 0x7e, 0x75, 0xab, 0xec, 0x9f, 0x86, 0x7e,
+	0x90, 0x26, 0x34, 0x0a, 0x4b, 0xf2, 0xbe, 0x05, 0x43, 0x36, 0x8d, 0xd3, 0xfc, 0xaf, 0x3b, 0x2a,
+	0x4e, 0x68, 0xb1, 0xa4, 0xe1, 0x54, 0xf4, 0x63, 0x22, 0x1f, 0xad, 0x63, 0x73, 0x49, 0xc3, 0xb1,
+	0xb0, 0xdd, 0xbf, 0x35, 0xb0, 0xcf, 0x68, 0xc2, 0xe5, 0x84, 0x3f, 0xb8, 0xbb, 0x0a, 0xce, 0x1a,
+	0x25, 0xce, 0x10, 0x34, 0x93, 0x88, 0xf1, 0x7c, 0x43, 0x89, 0xb3, 0x58, 0x11, 0x33, 0xca, 0x88,
+	0x5f, 0x1a, 0xb3, 0x15, 0x80, 0x0e, 0xa0, 0x35, 0x97, 0x6c, 0xc9, 0x32, 0x3a, 0x07, 0xbd, 0x7a,
+	0xed, 0x2b, 0x3e, 0x71, 0x16, 0x29, 0xbb, 0x81, 0x2e, 0x29, 0x97, 0xa2, 0x18, 0x58, 0x19, 0xa2,
+	0x6b, 0xa3, 0xf9, 0x3c, 0x21, 0x3c, 0xdb, 0x53, 0x99, 0xe5, 0x4e, 0x60, 0xab, 0xf4, 0x9a, 0x24,
+	0x8e, 0xc2, 0x84, 0xa0, 0x3d, 0x30, 0xd4, 0x4e, 0xd3, 0xfa, 0xfa, 0xa0, 0x73, 0xb0, 0x73, 0xdf,
+	0xaf, 0x62, 0x15, 0xa2, 0xa4, 0xe2, 0x5e, 0x20, 0x5f, 0x6a, 0x60, 0x65, 0xb8, 0x97, 0x80, 0x44,
+	0x5a, 0xd5, 0xa0, 0x8f, 0xd0, 0x54, 0x14, 0xdb, 0xb8, 0xbf, 0x58, 0xbd, 0x52, 0xec, 0xf7, 0xb0,
+	0x5d, 0xc9, 0x9a, 0x95, 0xfb, 0x0e, 0xda, 0x4b, 0x05, 0x65, 0x05, 0xbf, 0xa8, 0x17, 0xac, 0x6e,
+	0xe0, 0x3c, 0xec, 0x81, 0xa2, 0x7f, 0xd7, 0x00, 0x9d, 0x10, 0x7e, 0x19, 0xc5, 0x15, 0x71, 0xf7,
+	0xa1, 0xb5, 0x24, 0x9c, 0x51, 0x5f, 0x16, 0xbe, 0x71, 0x5f, 0x76, 0xe1, 0xc5, 0x59, 0x14, 0xea,
+	0x82, 0x16, 0x66, 0x89, 0xb5, 0x30, 0x7f, 0xb3, 0xbe, 0x7a, 0xf3, 0x4a, 0xd4, 0xe6, 0x53, 0x45,
+	0x75, 0x7f, 0x00, 0xc0, 0x5e, 0x78, 0x43, 0x66, 0xc2, 0x57, 0xac, 0x73, 0xad, 0xb4, 0xce, 0x77,
+	0xc0, 0xb8, 0xf5, 0x82, 0x34, 0xef, 0x38, 0x65, 0xa0, 0x01, 0x34, 0x85, 0x4c, 0xf2, 0xe7, 0x1f,
+	0x12, 0x52, 0x46, 0xb8, 0x27, 0xb0, 0x5d, 0x79, 0x7b, 0xc1, 0x6d, 0xa5, 0x15, 0xd6, 0x6b, 0x2d,
+	0xaa, 0xca, 0x1a, 0xc2, 0x7d, 0x0f, 0xdb, 0x5f, 0x7b, 0xdc, 0xbf, 0x9e, 0xc8, 0xdd, 0x56, 0xb0,
+	0xd8, 0x03, 0x33, 0x66, 0x64, 0x4e, 0x7f, 0x21, 0x2a, 0x97, 0x85, 0x0b, 0xdb, 0x1d, 0x41, 0x4b,
+	0x45, 0x0b, 0xb6, 0x6e, 0xc8, 0x5d, 0xde, 0x21, 0x37, 0xe4, 0x4e, 0xbc, 0x95, 0x70, 0x6f, 0x91,
+	0x0f, 0x92, 0x38, 0x8b, 0x7f, 0x86, 0x65, 0x34, 0xa3, 0x73, 0xaa, 0xd6, 0xa5, 0xe2, 0x16, 0x72,
+	0x68, 0xc8, 0xf7, 0xde, 0x82, 0x55, 0x4c, 0x35, 0xea, 0x40, 0xfb, 0xf4, 0xfc, 0xf0, 0x6c, 0x72,
+	0x74, 0x6c, 0x3f, 0x43, 0x26, 0x34, 0x47, 0xe7, 0x67, 0xdf, 0xda, 0x9a, 0x80, 0x8f, 0xbf, 0x51,
+	0x70, 0x63, 0x6f, 0x28, 0x96, 0xa9, 0x54, 0xcf, 0x02, 0x63, 0x7c, 0x39, 0xc4, 0x63, 0xfb, 0x99,
+	0x38, 0x7e, 0x18, 0xe1, 0x2f, 0xc7, 0xb6, 0x86, 0x36, 0xa1, 0x33, 0xba, 0x38, 0x3e, 0x9f, 0x9e,
+	0x8e, 0xc7, 0x93, 0xe3, 0xb1, 0xdd, 0x40, 0x36, 0x74, 0xcf, 0x86, 0xe3, 0xcb, 0xe9, 0xe4, 0xe2,
+	0x68, 0x78, 0x79, 0x7c, 0x64, 0xeb, 0x07, 0xbf, 0xe9, 0x60, 0x1c, 0x0a, 0x5a, 0xd0, 0xe7, 0xd0,
+	0x52, 0x9f, 0x36, 0xe8, 0x75, 0x9d, 0xae, 0xca, 0x27, 0x4f, 0x6f, 0xbb, 0xee, 0x16, 0x77, 0x2e,
+	0xc0, 0x2a, 0x26, 0x12, 0xf5, 0xeb, 0x11, 0xf5, 0xd5, 0xd3, 0xdb, 0x7d, 0x24, 0x22, 0xd3, 0xf0,
+	0x2b, 0xe8, 0x94, 0xc6, 0x06, 0xb9, 0xf7, 0xdd, 0xa8, 0x4e, 0x6a, 0xef, 0xcd, 0xa3, 0x31, 0xab,
+	0xbc, 0xa5, 0x96, 0x59, 0xcf, 0xbb, 0x3e, 0x4b, 0xbd, 0x37, 0x8f, 0xc6, 0x64, 0x79, 0x3f, 0x42,
+	0xb7, 0xdc, 0x41, 0x68, 0xed, 0xd2, 0x3d, 0xfd, 0xd5, 0x5b, 0x9b, 0x4a, 0xe5, 0x7f, 0xa7, 0x7d,
+	0x61, 0x7c, 0xa7, 0xb3, 0xd8, 0xbf, 0x6a, 0xc9, 0xef, 0xd0, 0x4f, 0xff, 0x1d, 0x00, 0xd2, 0x38,
+	0xbf, 0x94, 0x96, 0x0a, 0x00, 0x00,
+}
@@ -0,0 +1,470 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/aniketalshi/go_rest_cache/app/cache"
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+//go:generate protoc --go_out=plugins=grpc:. cache.proto
+
+// APIKeyMetadata is the metadata key clients pass their api key in when auth is enabled
+const APIKeyMetadata = "x-api-key"
+
+// views served for each metric
+var metricViews = map[Metric]string{
+	Metric_STARS:        cache.ViewByStars,
+	Metric_FORKS:        cache.ViewByForks,
+	Metric_OPEN_ISSUES:  cache.ViewByOpenIssues,
+	Metric_LAST_UPDATED: cache.ViewByLastUpdated,
+}
+
+// methodScopes maps each method to the scope required for it, the same as of the matching http routes
+var methodScopes = map[string]string{
+	"/gorestcache.v1.Cache/GetOrg":       cache.ScopeCachedRead,
+	"/gorestcache.v1.Cache/ListRepos":    cache.ScopeCachedRead,
+	"/gorestcache.v1.Cache/ListMembers":  cache.ScopeCachedRead,
+	"/gorestcache.v1.Cache/GetTopRepos":  cache.ScopeViews,
+	"/gorestcache.v1.Cache/WatchUpdates": cache.ScopeCachedRead,
+}
+
+// methodClasses maps each method to the class of route it is rate limited as, that of the matching http routes
+var methodClasses = map[string]string{
+	"/gorestcache.v1.Cache/GetOrg":       cache.RouteCached,
+	"/gorestcache.v1.Cache/ListRepos":    cache.RouteCached,
+	"/gorestcache.v1.Cache/ListMembers":  cache.RouteCached,
+	"/gorestcache.v1.Cache/GetTopRepos":  cache.RouteViews,
+	"/gorestcache.v1.Cache/WatchUpdates": cache.RouteCached,
+}
+
+// Server serves service Cache from data cached by cacher, the same the http handlers serve
+type Server struct {
+	cacher  *cache.Cacher
+	auth    *cache.Authenticator
+	limiter *cache.RateLimiter
+}
+
+// NewServer returns grpc server with service Cache registered, backed by cacher.
+// Calls are authenticated with api keys when auth is enabled and draw from the same rate limit buckets
+// as http requests
+func NewServer(cacher *cache.Cacher) *grpc.Server {
+
+	ss := &Server{
+		cacher:  cacher,
+		auth:    cache.NewAuthenticator(cacher.DBClient),
+		limiter: cacher.Limiter,
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(ss.unaryInterceptor),
+		grpc.StreamInterceptor(ss.streamInterceptor),
+	)
+	RegisterCacheServer(server, ss)
+	return server
+}
+
+// admit authenticates the call of method and takes a token for it from the bucket of the client,
+// returning ctx carrying the key id for logging. Like http requests, calls are limited per client ip
+// ahead of authentication too
+func (ss *Server) admit(ctx context.Context, method string) (context.Context, error) {
+
+	var addr net.Addr
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr
+	}
+
+	if config.GetConfig().GetAuthConfig().Enabled {
+		if allowed, retryAfter := ss.limiter.Allow(ctx, cache.RouteAuth, nil, addr); !allowed {
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %v", retryAfter)
+		}
+	}
+
+	ctx, key, err := ss.authenticate(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowed, retryAfter := ss.limiter.Allow(ctx, methodClasses[method], key, addr); !allowed {
+		return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %v", retryAfter)
+	}
+	return ctx, nil
+}
+
+// authenticate checks the api key in metadata of ctx has the scope for method, and returns ctx
+// carrying the key id for logging along with the key, nil when auth is disabled
+func (ss *Server) authenticate(ctx context.Context, method string) (context.Context, *cache.APIKey, error) {
+
+	// everything served here is cached with our token, which clients with their own must not see
+	// in partition mode, the same as over http
+	passthrough := config.GetConfig().GetPassthroughConfig()
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 &&
+		passthrough.Enabled && passthrough.GetCached() == config.PassthroughPartition {
+		return nil, nil, status.Error(codes.PermissionDenied, "not available to clients passing their own token")
+	}
+
+	if !config.GetConfig().GetAuthConfig().Enabled {
+		return ctx, nil, nil
+	}
+
+	var raw string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(APIKeyMetadata)) > 0 {
+		raw = md.Get(APIKeyMetadata)[0]
+	}
+
+	key, err := ss.auth.Authenticate(raw)
+	if err != nil {
+		logging.Logger(ctx).Error("Error looking up api key",
+								  zap.String("msg", err.Error()))
+		return nil, nil, status.Error(codes.Internal, "error looking up api key")
+	}
+	if key == nil {
+		logging.Logger(ctx).Warn("Call without valid api key rejected",
+								 zap.String("method", method))
+		return nil, nil, status.Error(codes.Unauthenticated, "valid api key required in "+APIKeyMetadata+" metadata")
+	}
+
+	ctx = logging.NewContext(ctx, zap.String("apiKeyID", key.ID))
+	scope := methodScopes[method]
+	allowed := cache.HasScope(key, scope)
+
+	logging.Logger(ctx).Info("Audit",
+							 zap.String("method", method),
+							 zap.String("class", "grpc"),
+							 zap.Bool("allowed", allowed),
+							 zap.String("reason", "scope "+scope))
+
+	if !allowed {
+		return nil, nil, status.Error(codes.PermissionDenied, "api key lacks scope "+scope)
+	}
+	return ctx, key, nil
+}
+
+func (ss *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+
+	ctx, err := ss.admit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticatedStream carries the context of an authenticated call to stream handlers
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (as *authenticatedStream) Context() context.Context {
+	return as.ctx
+}
+
+func (ss *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	ctx, err := ss.admit(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// findOrg returns the configured org named name
+func findOrg(name string) (config.OrgConfig, error) {
+	org, ok := config.GetConfig().FindOrg(name)
+	if !ok {
+		return org, status.Errorf(codes.NotFound, "org %q is not configured", name)
+	}
+	return org, nil
+}
+
+// cachedList returns the json of elements of the list cached under url
+func (ss *Server) cachedList(url string) ([]json.RawMessage, error) {
+
+	data := ss.cacher.GetCachedEndpoint(url)
+	if len(data) == 0 {
+		return nil, status.Error(codes.Unavailable, url+" is not cached yet")
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return items, nil
+}
+
+// window returns the bounds of the elements from offset on, at most limit of them if limit is positive
+func window(total int, offset int32, limit int32) (int, int, error) {
+
+	if offset < 0 || limit < 0 {
+		return 0, 0, status.Error(codes.InvalidArgument, "offset and limit may not be negative")
+	}
+
+	start := int(offset)
+	if start > total {
+		start = total
+	}
+	end := total
+	if limit > 0 && start+int(limit) < end {
+		end = start + int(limit)
+	}
+	return start, end, nil
+}
+
+// decodeRepo decodes repository json, keeping the json along
+func decodeRepo(data []byte) (*Repo, error) {
+	repo := &Repo{}
+	if err := json.Unmarshal(data, repo); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	repo.Json = data
+	return repo, nil
+}
+
+// matches reports whether repo passes filter
+func matches(repo *Repo, filter *RepoFilter) bool {
+
+	if filter == nil {
+		return true
+	}
+
+	included := func(inclusion Inclusion, has bool) bool {
+		switch inclusion {
+		case Inclusion_ONLY:
+			return has
+		case Inclusion_EXCLUDE:
+			return !has
+		}
+		return true
+	}
+
+	if filter.Language != "" && !strings.EqualFold(repo.Language, filter.Language) {
+		return false
+	}
+	if filter.Topic != "" {
+		found := false
+		for _, topic := range repo.Topics {
+			if strings.EqualFold(topic, filter.Topic) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return included(filter.Archived, repo.Archived) &&
+		included(filter.Forks, repo.Fork) &&
+		repo.StargazersCount >= filter.MinStars
+}
+
+// GetOrg returns the cached org endpoint
+func (ss *Server) GetOrg(ctx context.Context, req *GetOrgRequest) (*Org, error) {
+
+	conf, err := findOrg(req.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	data := ss.cacher.GetCachedEndpoint(conf.GetURL())
+	if len(data) == 0 {
+		return nil, status.Errorf(codes.Unavailable, "org %q is not cached yet", req.Org)
+	}
+
+	org := &Org{}
+	if err := json.Unmarshal(data, org); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	org.Json = data
+	return org, nil
+}
+
+// ListRepos returns cached repositories of an org, filtered and sorted like the org repos endpoint
+func (ss *Server) ListRepos(ctx context.Context, req *ListReposRequest) (*ListReposResponse, error) {
+
+	conf, err := findOrg(req.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for param, value := range map[string]string{"type": req.Type, "sort": req.Sort, "direction": req.Direction} {
+		if value != "" {
+			query.Set(param, strings.ToLower(value))
+		}
+	}
+
+	items, err := ss.cacher.ListRepos(conf, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if items == nil {
+		return nil, status.Errorf(codes.Unavailable, "repositories of %q are not cached yet", req.Org)
+	}
+
+	var repos []*Repo
+	for _, item := range items {
+		repo, err := decodeRepo(item)
+		if err != nil {
+			return nil, err
+		}
+		if matches(repo, req.Filter) {
+			repos = append(repos, repo)
+		}
+	}
+
+	start, end, err := window(len(repos), req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &ListReposResponse{Repos: repos[start:end], Total: int32(len(repos))}, nil
+}
+
+// ListMembers returns cached members of an org
+func (ss *Server) ListMembers(ctx context.Context, req *ListMembersRequest) (*ListMembersResponse, error) {
+
+	conf, err := findOrg(req.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := ss.cachedList(conf.GetMembersURL())
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := window(len(items), req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*Member, 0, end-start)
+	for _, item := range items[start:end] {
+		member := &Member{}
+		if err := json.Unmarshal(item, member); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		member.Json = item
+		members = append(members, member)
+	}
+	return &ListMembersResponse{Members: members, Total: int32(len(items))}, nil
+}
+
+// GetTopRepos returns the first n repositories of a view passing the filter
+func (ss *Server) GetTopRepos(ctx context.Context, req *GetTopReposRequest) (*GetTopReposResponse, error) {
+
+	view, ok := metricViews[req.Metric]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown metric %v", req.Metric)
+	}
+	if req.N < 1 {
+		return nil, status.Error(codes.InvalidArgument, "n must be positive")
+	}
+	if req.Org != "" {
+		if _, err := findOrg(req.Org); err != nil {
+			return nil, err
+		}
+	}
+	if !ss.cacher.IsWarm(cache.ViewKey(view, req.Org)) {
+		return nil, status.Error(codes.Unavailable, "view is not built yet")
+	}
+
+	// filtered views are walked until n repositories pass
+	limit := int(req.N)
+	if req.Filter != nil {
+		limit = 0
+	}
+
+	results, err := ss.cacher.GetView(ctx, view, req.Org, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &GetTopReposResponse{}
+	for _, result := range results {
+
+		data, err := ss.cacher.GetRepo(result.Repo)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if data == nil {
+			continue
+		}
+
+		repo, err := decodeRepo(data)
+		if err != nil {
+			return nil, err
+		}
+		if !matches(repo, req.Filter) {
+			continue
+		}
+
+		resp.Repos = append(resp.Repos, &RankedRepo{Name: result.Repo, Value: result.Count, Repo: repo})
+		if len(resp.Repos) == int(req.N) {
+			break
+		}
+	}
+	return resp, nil
+}
+
+// WatchUpdates streams an update every time content of a cached key with one of the prefixes changes,
+// until the client goes away or the feed is closed on shutdown
+func (ss *Server) WatchUpdates(req *WatchUpdatesRequest, stream Cache_WatchUpdatesServer) error {
+
+	updates := ss.cacher.Updates.Subscribe()
+	defer ss.cacher.Updates.Unsubscribe(updates)
+
+	logging.Logger(stream.Context()).Info("Watching updates",
+										  zap.Strings("prefixes", req.Prefixes))
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case <-ss.cacher.Updates.Done():
+			// let clients reconnect to another replica
+			return status.Error(codes.Unavailable, "server is shutting down")
+
+		case update := <-updates:
+			if !hasPrefix(update.Key, req.Prefixes) {
+				continue
+			}
+			err := stream.Send(&Update{
+				Key:        update.Key,
+				Etag:       update.ETag,
+				ModifiedAt: update.ModifiedAt.UTC().Format(time.RFC3339),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// hasPrefix reports whether key starts with any of prefixes, true if there are none
+func hasPrefix(key string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
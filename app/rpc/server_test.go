@@ -0,0 +1,265 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/aniketalshi/go_rest_cache/app/cache"
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+func TestAdmit(t *testing.T) {
+
+	logging.InitLogger()
+
+	conf := &config.Config{}
+	conf.Auth.Enabled = true
+	conf.Auth.Keys = []config.APIKeyConfig{
+		{ID: "reader", Key: "reader-key", Scopes: []string{cache.ScopeCachedRead}},
+		{ID: "viewer", Key: "viewer-key", Scopes: []string{cache.ScopeViews}},
+		{ID: "both", Key: "both-key", Scopes: []string{cache.ScopeCachedRead, cache.ScopeViews}},
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	ss := &Server{auth: cache.NewAuthenticator(nil), limiter: cache.NewRateLimiter(nil)}
+
+	tests := []struct {
+		name     string
+		method   string
+		metadata []string
+		want     codes.Code
+	}{
+		{"no key", "GetOrg", nil, codes.Unauthenticated},
+		{"cached read of org", "GetOrg", []string{APIKeyMetadata, "reader-key"}, codes.OK},
+		{"cached read of repos", "ListRepos", []string{APIKeyMetadata, "reader-key"}, codes.OK},
+		{"cached read of members", "ListMembers", []string{APIKeyMetadata, "reader-key"}, codes.OK},
+		{"cached read of updates", "WatchUpdates", []string{APIKeyMetadata, "reader-key"}, codes.OK},
+		{"cached read of views", "GetTopRepos", []string{APIKeyMetadata, "reader-key"}, codes.PermissionDenied},
+		{"views of views", "GetTopRepos", []string{APIKeyMetadata, "viewer-key"}, codes.OK},
+		{"views of org", "GetOrg", []string{APIKeyMetadata, "viewer-key"}, codes.PermissionDenied},
+		{"views of updates", "WatchUpdates", []string{APIKeyMetadata, "viewer-key"}, codes.PermissionDenied},
+		{"both of views", "GetTopRepos", []string{APIKeyMetadata, "both-key"}, codes.OK},
+		{"both of repos", "ListRepos", []string{APIKeyMetadata, "both-key"}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.metadata...))
+			_, err := ss.admit(ctx, "/gorestcache.v1.Cache/"+tt.method)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("admit(%s) = %v, want %v", tt.method, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmitRateLimit(t *testing.T) {
+
+	logging.InitLogger()
+
+	conf := &config.Config{}
+	conf.Auth.Enabled = true
+	conf.Auth.Keys = []config.APIKeyConfig{
+		{ID: "first", Key: "first-key", Scopes: []string{cache.ScopeCachedRead, cache.ScopeViews}},
+		{ID: "second", Key: "second-key", Scopes: []string{cache.ScopeCachedRead, cache.ScopeViews}},
+	}
+	conf.RateLimit.Enabled = true
+	conf.RateLimit.Limits = map[string]config.Limit{
+		cache.RouteCached: {Rate: 0.001, Burst: 2},
+		cache.RouteViews:  {Rate: 0.001, Burst: 1},
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	ss := &Server{auth: cache.NewAuthenticator(nil), limiter: cache.NewRateLimiter(nil)}
+
+	// calls run in order, drawing from the same buckets
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		want   codes.Code
+	}{
+		{"first cached call", "GetOrg", "first-key", codes.OK},
+		{"second cached call of another method", "ListRepos", "first-key", codes.OK},
+		{"third cached call over burst", "ListMembers", "first-key", codes.ResourceExhausted},
+		{"streams draw from cached bucket", "WatchUpdates", "first-key", codes.ResourceExhausted},
+		{"views limited apart", "GetTopRepos", "first-key", codes.OK},
+		{"views over burst", "GetTopRepos", "first-key", codes.ResourceExhausted},
+		{"other keys limited apart", "GetOrg", "second-key", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadata, tt.key))
+			_, err := ss.admit(ctx, "/gorestcache.v1.Cache/"+tt.method)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("admit(%s) = %v, want %v", tt.method, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmitAuthLimit(t *testing.T) {
+
+	logging.InitLogger()
+
+	conf := &config.Config{}
+	conf.Auth.Enabled = true
+	conf.Auth.Keys = []config.APIKeyConfig{
+		{ID: "reader", Key: "reader-key", Scopes: []string{cache.ScopeCachedRead}},
+	}
+	conf.RateLimit.Enabled = true
+	conf.RateLimit.Limits = map[string]config.Limit{
+		cache.RouteAuth: {Rate: 0.001, Burst: 2},
+	}
+	config.SetConfig(conf)
+	defer config.SetConfig(nil)
+
+	ss := &Server{auth: cache.NewAuthenticator(nil), limiter: cache.NewRateLimiter(nil)}
+
+	// calls run in order, drawing from the same buckets
+	tests := []struct {
+		name string
+		ip   string
+		key  string
+		want codes.Code
+	}{
+		{"first call without key", "10.0.0.1", "", codes.Unauthenticated},
+		{"second call without key", "10.0.0.1", "", codes.Unauthenticated},
+		{"ip out of tokens ahead of authentication", "10.0.0.1", "", codes.ResourceExhausted},
+		{"valid keys from the same ip", "10.0.0.1", "reader-key", codes.ResourceExhausted},
+		{"another ip", "10.0.0.2", "reader-key", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadata, tt.key))
+			ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.ip), Port: 5000}})
+
+			_, err := ss.admit(ctx, "/gorestcache.v1.Cache/GetOrg")
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("admit() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmitPassthrough(t *testing.T) {
+
+	logging.InitLogger()
+
+	tests := []struct {
+		name     string
+		cached   string
+		metadata []string
+		want     codes.Code
+	}{
+		{"partition without token", config.PassthroughPartition, nil, codes.OK},
+		{"partition with token", config.PassthroughPartition, []string{"authorization", "token abc"}, codes.PermissionDenied},
+		{"public with token", config.PassthroughPublic, []string{"authorization", "token abc"}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{}
+			conf.UpstreamTarget.Passthrough.Enabled = true
+			conf.UpstreamTarget.Passthrough.Cached = tt.cached
+			config.SetConfig(conf)
+			defer config.SetConfig(nil)
+
+			ss := &Server{auth: cache.NewAuthenticator(nil), limiter: cache.NewRateLimiter(nil)}
+
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.metadata...))
+			_, err := ss.admit(ctx, "/gorestcache.v1.Cache/GetOrg")
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("admit() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// updateStream collects updates sent to a watcher
+type updateStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *Update
+}
+
+func (us *updateStream) Context() context.Context {
+	return us.ctx
+}
+
+func (us *updateStream) Send(update *Update) error {
+	us.sent <- update
+	return nil
+}
+
+func TestWatchUpdates(t *testing.T) {
+
+	logging.InitLogger()
+
+	tests := []struct {
+		name   string
+		cancel bool
+		want   codes.Code
+	}{
+		{"client goes away", true, codes.OK},
+		{"feed closed on shutdown", false, codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			feed := cache.NewUpdateFeed()
+			ss := &Server{cacher: &cache.Cacher{Updates: feed}}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stream := &updateStream{ctx: ctx, sent: make(chan *Update, 1)}
+
+			done := make(chan error)
+			go func() {
+				done <- ss.WatchUpdates(&WatchUpdatesRequest{Prefixes: []string{"/orgs/"}}, stream)
+			}()
+
+			// wait for the watcher to subscribe, updates outside the prefixes are not sent
+			for !feed.HasSubscribers() {
+				time.Sleep(time.Millisecond)
+			}
+			feed.Publish(cache.Update{Key: "/", ModifiedAt: time.Unix(0, 0)})
+			feed.Publish(cache.Update{Key: "/orgs/Netflix", ETag: `"abc"`, ModifiedAt: time.Unix(0, 0)})
+			if update := <-stream.sent; update.Key != "/orgs/Netflix" || update.Etag != `"abc"` {
+				t.Errorf("sent %+v, want update of /orgs/Netflix", update)
+			}
+
+			if tt.cancel {
+				cancel()
+			} else {
+				feed.Close()
+			}
+
+			select {
+			case err := <-done:
+				if got := status.Code(err); got != tt.want {
+					t.Errorf("WatchUpdates() = %v, want %v", err, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("WatchUpdates() kept streaming")
+			}
+			if feed.HasSubscribers() {
+				t.Error("watcher is still subscribed")
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aniketalshi/go_rest_cache/app/logging"
+	"github.com/aniketalshi/go_rest_cache/app/model"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+// Version of the snapshot format written by Export. Import accepts this version and older ones
+const Version = 3
+
+// Entry is a single cached key, its value and book-keeping information
+type Entry struct {
+	Key       string    `json:"key"`
+	Value     []byte    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// ETag is the content hash of value, verified on import
+	ETag string `json:"etag"`
+}
+
+// Snapshot holds the contents of the cache at a point in time
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Orgs the cached data was fetched for
+	Orgs []string `json:"orgs"`
+
+	// Org is the single org recorded by version 2 and older
+	Org     string  `json:"org,omitempty"`
+	Entries []Entry `json:"entries"`
+}
+
+// ImportResult summarizes what an import did
+type ImportResult struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Orgs      []string  `json:"orgs"`
+	Imported  int       `json:"imported"`
+
+	// Skipped counts entries for which the cache already held fresher data
+	Skipped int `json:"skipped"`
+}
+
+// Export writes every cached key matching pattern to w as a gzip compressed archive
+func Export(db *model.DBClient, pattern string, w io.Writer) (*Snapshot, error) {
+
+	keys, err := db.Keys(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Orgs:      config.GetConfig().GetOrgNames(),
+	}
+
+	for _, key := range keys {
+
+		value := db.Get(key)
+		entry := Entry{Key: key, Value: value, ETag: model.ContentHash(value)}
+
+		meta, err := db.GetMeta(key)
+		if err != nil {
+			return nil, err
+		}
+		if meta != nil {
+			entry.UpdatedAt = meta.UpdatedAt
+		}
+
+		snap.Entries = append(snap.Entries, entry)
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Import reads a snapshot archive from r and writes its entries into the cache.
+// Entries are skipped when the cache already holds data written after the entry was
+func Import(db *model.DBClient, r io.Reader) (*ImportResult, error) {
+
+	// archives are gzipped json, plain json is accepted too, such as of an archive unpacked to be edited
+	br := bufio.NewReader(r)
+	var body io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	snap := &Snapshot{}
+	if err := json.NewDecoder(body).Decode(snap); err != nil {
+		return nil, err
+	}
+
+	if snap.Version < 1 || snap.Version > Version {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	if snap.Org != "" {
+		snap.Orgs = append(snap.Orgs, snap.Org)
+	}
+
+	// data of orgs no longer configured would never be refreshed
+	for _, org := range snap.Orgs {
+		if _, ok := config.GetConfig().FindOrg(org); !ok {
+			return nil, fmt.Errorf("snapshot was taken for org %s, which is not configured", org)
+		}
+	}
+
+	// verify integrity of the whole archive before touching the cache
+	for _, entry := range snap.Entries {
+		if entry.ETag != "" && entry.ETag != model.ContentHash(entry.Value) {
+			return nil, fmt.Errorf("snapshot entry %s is corrupt: content hash mismatch", entry.Key)
+		}
+	}
+
+	result := &ImportResult{Version: snap.Version, CreatedAt: snap.CreatedAt, Orgs: snap.Orgs}
+
+	for _, entry := range snap.Entries {
+
+		meta, err := db.GetMeta(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		if meta != nil && !meta.UpdatedAt.Before(entry.UpdatedAt) {
+			result.Skipped += 1
+			continue
+		}
+
+		updatedAt := entry.UpdatedAt
+		if updatedAt.IsZero() {
+			updatedAt = snap.CreatedAt
+		}
+
+		if err := db.SetWithMeta(entry.Key, entry.Value, model.KeyMeta{UpdatedAt: updatedAt}); err != nil {
+			return nil, err
+		}
+		result.Imported += 1
+	}
+
+	logging.Logger(context.Background()).Info("Snapshot imported",
+											  zap.Int("version", result.Version),
+											  zap.Time("created_at", result.CreatedAt),
+											  zap.Int("imported", result.Imported),
+											  zap.Int("skipped", result.Skipped))
+
+	return result, nil
+}
@@ -0,0 +1,163 @@
+// cachectl is a command line tool for operating the cache without starting the http server
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/aniketalshi/go_rest_cache/app"
+	"github.com/aniketalshi/go_rest_cache/app/snapshot"
+	"github.com/aniketalshi/go_rest_cache/config"
+)
+
+const usage = `usage: cachectl <command> [args]
+
+commands:
+  warm                  run all refresh jobs once and exit
+  inspect <key>         print size, age, ttl and value of a cached key
+  invalidate <pattern>  remove all cached keys matching glob pattern
+  export [file]         write snapshot of the cache to file or stdout
+  import [file]         load snapshot from file or stdin into the cache
+  views rebuild         rebuild views from cached repositories
+  check-config          validate config.yaml and environment overrides
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+
+	// initialize the config
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if command == "check-config" {
+		if err := cfg.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("config ok")
+		return
+	}
+
+	// setup redis client and cacher, no http handlers are needed
+	aa := &app.App{}
+	aa.InitializeCache()
+
+	switch {
+	case command == "warm":
+		err = aa.Warm()
+
+	case command == "inspect" && len(args) == 1:
+		err = inspect(aa, args[0])
+
+	case command == "invalidate" && len(args) == 1:
+		err = invalidate(aa, args[0])
+
+	case command == "export" && len(args) <= 1:
+		err = export(aa, args)
+
+	case command == "import" && len(args) <= 1:
+		err = load(aa, args)
+
+	case command == "views" && len(args) == 1 && args[0] == "rebuild":
+		err = aa.RebuildViews()
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// inspect prints the description of key followed by its value
+func inspect(aa *app.App, key string) error {
+
+	info, err := aa.DBClient.Describe(key)
+	if err != nil {
+		return err
+	}
+
+	js, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(js))
+	fmt.Println(string(aa.DBClient.Get(key)))
+	return nil
+}
+
+// invalidate removes all keys matching pattern
+func invalidate(aa *app.App, pattern string) error {
+
+	keys, err := aa.DBClient.Keys(pattern)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := aa.DBClient.Delete(keys...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("deleted %d keys\n", deleted)
+	return nil
+}
+
+// export writes the snapshot to the file named in args or stdout
+func export(aa *app.App, args []string) error {
+
+	var w io.Writer = os.Stdout
+	if len(args) == 1 {
+		fp, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		w = fp
+	}
+
+	snap, err := snapshot.Export(aa.DBClient, "*", w)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d keys\n", len(snap.Entries))
+	return nil
+}
+
+// load reads the snapshot from the file named in args or stdin
+func load(aa *app.App, args []string) error {
+
+	var r io.Reader = os.Stdin
+	if len(args) == 1 {
+		fp, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		r = fp
+	}
+
+	// views are rebuilt from the repositories imported, like on import through the admin api
+	result, err := aa.ImportSnapshot(r)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d keys, skipped %d keys holding fresher data\n",
+		result.Imported, result.Skipped)
+	return nil
+}
@@ -2,6 +2,13 @@ package config
 
 import (
 	"os"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
 	"gopkg.in/yaml.v2"
 )
 
@@ -11,15 +18,582 @@ const (
 
 type CacheConfig struct {
 	RefreshInterval int `yaml:"refresh"`
+	MaxStaleness    int `yaml:"max_staleness"`
+
+	// Snapshot is the path of snapshot archive imported at startup, if any
+	Snapshot string `yaml:"snapshot"`
+
+	// ColdStart decides how cached routes are served before their key is first populated
+	ColdStart string `yaml:"cold_start"`
+
+	// Budget caps requests refresh jobs of all orgs make upstream together
+	Budget BudgetConfig `yaml:"budget"`
+
+	Compression CompressionConfig `yaml:"compression"`
+
+	// Routes lists patterns of further paths served from cache, read through from upstream. Patterns are
+	// templates with {name} segments, globs with ** matching trailing segments, or regular expressions
+	// when prefixed with "regex:"
+	Routes []string `yaml:"routes"`
+
+	Query QueryConfig `yaml:"query"`
+}
+
+// QueryConfig controls how query strings of requests to cached routes are normalized
+type QueryConfig struct {
+	// Ignore lists parameters dropped from requests, paging parameters by default since cached
+	// lists hold every page
+	Ignore []string `yaml:"ignore"`
+}
+
+// CompressionConfig controls compression of values stored in redis and of responses served from cache
+type CompressionConfig struct {
+	// Store is the encoding values are compressed with in redis, gzip, br or none
+	Store string `yaml:"store"`
+
+	// MinSize is the size in bytes below which values and responses are left uncompressed
+	MinSize int `yaml:"min_size"`
+
+	// Responses enables compressing responses served from cache as negotiated by Accept-Encoding
+	Responses bool `yaml:"responses"`
+}
+
+const (
+	EncodingNone   = "none"
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// GetStore returns the encoding values are compressed with in redis, none by default
+func (cc CompressionConfig) GetStore() string {
+	if cc.Store == "" {
+		return EncodingNone
+	}
+	return cc.Store
+}
+
+// GetMinSize returns the size below which nothing is compressed, 1KB by default
+func (cc CompressionConfig) GetMinSize() int {
+	if cc.MinSize > 0 {
+		return cc.MinSize
+	}
+	return 1024
+}
+
+// BudgetConfig is the upstream request budget shared by refresh jobs
+type BudgetConfig struct {
+	RequestsPerHour int `yaml:"requests_per_hour"`
+	Burst           int `yaml:"burst"`
+}
+
+// GetLimit returns the budget as a token bucket, false if refresh jobs are not budgeted.
+// Burst defaults to a minute worth of requests
+func (b BudgetConfig) GetLimit() (Limit, bool) {
+
+	if b.RequestsPerHour <= 0 {
+		return Limit{}, false
+	}
+
+	burst := b.Burst
+	if burst <= 0 {
+		burst = b.RequestsPerHour / 60
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return Limit{Rate: float64(b.RequestsPerHour) / 3600, Burst: burst}, true
+}
+
+// OrgConfig describes an organization whose endpoints are cached and views built
+type OrgConfig struct {
+	Name string `yaml:"name"`
+
+	// Refresh is the refresh interval of the org's jobs in seconds, cache.refresh by default
+	Refresh int `yaml:"refresh"`
+
+	// Cached lists endpoints of the org served from cache, org details, members and repos by default
+	Cached []string `yaml:"cached"`
+
+	// Repos configures endpoints of every repository of the org served from cache
+	Repos RepoConfig `yaml:"repos"`
+
+	// Members configures what is fetched about members of the org beyond the members endpoint
+	Members MemberConfig `yaml:"members"`
+}
+
+// MemberConfig describes data fetched about every member of an org for member views. Views ranking
+// members by public repos, followers and account age need the profile of every member
+type MemberConfig struct {
+	// Profiles fetches /users/{login} of every member, off by default as it costs a request per member
+	Profiles bool `yaml:"profiles"`
+
+	// Refresh is the refresh interval of profiles in seconds, ten times the org's by default
+	Refresh int `yaml:"refresh"`
+}
+
+// GetRefresh returns how often member profiles are refreshed given the refresh interval of the org
+func (mc MemberConfig) GetRefresh(orgRefresh time.Duration) time.Duration {
+	if mc.Refresh > 0 {
+		return time.Duration(mc.Refresh) * time.Second
+	}
+	return 10 * orgRefresh
+}
+
+// sub-resources of repositories which may be cached
+const (
+	RepoLanguages    = "languages"
+	RepoTopics       = "topics"
+	RepoContributors = "contributors"
+	RepoReleases     = "releases"
+	RepoLatestCommit = "latest_commit"
+)
+
+// repoResourcePaths maps sub-resources to their path under the repository endpoint
+var repoResourcePaths = map[string]string{
+	RepoLanguages:    "/languages",
+	RepoTopics:       "/topics",
+	RepoContributors: "/contributors",
+	RepoReleases:     "/releases",
+	RepoLatestCommit: "/commits/HEAD",
+}
+
+// RepoConfig describes endpoints of repositories served from cache. Repositories themselves are
+// always cached along with the repos of their org, sub-resources only when listed
+type RepoConfig struct {
+	// Resources lists sub-resources of every repository cached, none by default
+	Resources []string `yaml:"resources"`
+
+	// Refresh is the refresh interval of sub-resources in seconds, ten times the org's by default
+	Refresh int `yaml:"refresh"`
+}
+
+// GetResourcePaths returns paths of cached sub-resources under the repository endpoint
+func (rc RepoConfig) GetResourcePaths() []string {
+	var paths []string
+	for _, resource := range rc.Resources {
+		if path, ok := repoResourcePaths[resource]; ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// GetRefresh returns how often sub-resources are refreshed given the refresh interval of the org
+func (rc RepoConfig) GetRefresh(orgRefresh time.Duration) time.Duration {
+	if rc.Refresh > 0 {
+		return time.Duration(rc.Refresh) * time.Second
+	}
+	return 10 * orgRefresh
+}
+
+// GetURL returns the org endpoint, e.g /orgs/Netflix
+func (o OrgConfig) GetURL() string {
+	return "/orgs/" + o.Name
+}
+
+// GetMembersURL returns the endpoint listing members of the org
+func (o OrgConfig) GetMembersURL() string {
+	return o.GetURL() + "/members"
+}
+
+// GetReposURL returns the endpoint listing repositories of the org
+func (o OrgConfig) GetReposURL() string {
+	return o.GetURL() + "/repos"
+}
+
+// GetCachedURLs returns endpoints of the org served from cache
+func (o OrgConfig) GetCachedURLs() []string {
+	if len(o.Cached) == 0 {
+		return []string{o.GetURL(), o.GetMembersURL(), o.GetReposURL()}
+	}
+	return o.Cached
+}
+
+// GetRepoURL returns the endpoint of repository repo of the org, e.g /repos/Netflix/zuul
+func (o OrgConfig) GetRepoURL(repo string) string {
+	return "/repos/" + o.Name + "/" + repo
+}
+
+// GetRepoResourcesJob returns the name of the job refreshing sub-resources of repositories of the org,
+// shaped like the urls it caches so that it shares their refresh interval
+func (o OrgConfig) GetRepoResourcesJob() string {
+	return o.GetRepoURL("*") + "/*"
+}
+
+// GetMemberProfilesJob returns the name of the job refreshing profiles of members of the org
+func (o OrgConfig) GetMemberProfilesJob() string {
+	return o.GetMembersURL() + "/*"
+}
+
+// CachesMembers reports whether the members endpoint of the org is served from cache
+func (o OrgConfig) CachesMembers() bool {
+	for _, url := range o.GetCachedURLs() {
+		if url == o.GetMembersURL() {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns reports whether url is an endpoint of the org or of one of its repositories
+func (o OrgConfig) Owns(url string) bool {
+	return url == o.GetURL() || strings.HasPrefix(url, o.GetURL()+"/") || strings.HasPrefix(url, "/repos/"+o.Name+"/")
+}
+
+// ParseRepoURL splits url of a repository endpoint of the org into the repository and the path of
+// the sub-resource under it, empty for the repository itself. Reports false if url is not one
+func (o OrgConfig) ParseRepoURL(url string) (string, string, bool) {
+
+	rest := strings.TrimPrefix(url, "/repos/"+o.Name+"/")
+	if rest == url || rest == "" {
+		return "", "", false
+	}
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return rest[:i], rest[i:], true
+	}
+	return rest, "", true
+}
+
+const (
+	// ColdStartProxy serves cached routes from upstream until they are warm
+	ColdStartProxy = "proxy"
+
+	// ColdStartUnavailable responds with 503 to cached routes until they are warm
+	ColdStartUnavailable = "unavailable"
+)
+
+// GetColdStart returns how cold cached routes are served, proxying them by default
+func (c CacheConfig) GetColdStart() string {
+	if c.ColdStart == "" {
+		return ColdStartProxy
+	}
+	return c.ColdStart
+}
+
+// GetMaxStaleness returns how old a cached key may get before it is considered stale.
+// Defaults to three refresh intervals when not configured
+func (c CacheConfig) GetMaxStaleness() time.Duration {
+	if c.MaxStaleness > 0 {
+		return time.Duration(c.MaxStaleness) * time.Second
+	}
+	return 3 * time.Duration(c.RefreshInterval) * time.Second
+}
+
+
+// ProxyConfig holds tuning parameters of the reverse proxy forwarding non cached requests upstream
+type ProxyConfig struct {
+	ResponseHeaderTimeout int `yaml:"response_header_timeout"`
+	IdleConnTimeout       int `yaml:"idle_conn_timeout"`
+	MaxIdleConns          int `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int `yaml:"max_conns_per_host"`
+
+	Policy ProxyPolicyConfig `yaml:"policy"`
+}
+
+// ProxyPolicyConfig restricts what may be proxied upstream with the server's token
+type ProxyPolicyConfig struct {
+	Methods []string `yaml:"methods"`
+
+	// Allow and Deny are lists of glob patterns, or regular expressions when prefixed with "regex:"
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+}
+
+// GetMethods returns methods allowed to be proxied, GET and HEAD by default
+func (pp ProxyPolicyConfig) GetMethods() []string {
+	if len(pp.Methods) == 0 {
+		return []string{"GET", "HEAD"}
+	}
+	return pp.Methods
+}
+
+// GetMaxBodyBytes returns the largest request body allowed to be proxied, 1MB by default
+func (pp ProxyPolicyConfig) GetMaxBodyBytes() int64 {
+	if pp.MaxBodyBytes > 0 {
+		return pp.MaxBodyBytes
+	}
+	return 1 << 20
+}
+
+// GetResponseHeaderTimeout returns how long to wait for upstream response headers, defaults to 30 seconds
+func (p ProxyConfig) GetResponseHeaderTimeout() time.Duration {
+	return secondsOrDefault(p.ResponseHeaderTimeout, 30)
+}
+
+// GetIdleConnTimeout returns how long idle upstream connections are kept in the pool, defaults to 90 seconds
+func (p ProxyConfig) GetIdleConnTimeout() time.Duration {
+	return secondsOrDefault(p.IdleConnTimeout, 90)
+}
+
+// GetMaxIdleConns returns size of the idle connection pool, defaults to 100
+func (p ProxyConfig) GetMaxIdleConns() int {
+	if p.MaxIdleConns > 0 {
+		return p.MaxIdleConns
+	}
+	return 100
+}
+
+// GetMaxIdleConnsPerHost returns size of the idle connection pool per upstream host, defaults to 20
+func (p ProxyConfig) GetMaxIdleConnsPerHost() int {
+	if p.MaxIdleConnsPerHost > 0 {
+		return p.MaxIdleConnsPerHost
+	}
+	return 20
+}
+
+// GetMaxConnsPerHost returns the limit on connections per upstream host, 0 means no limit
+func (p ProxyConfig) GetMaxConnsPerHost() int {
+	return p.MaxConnsPerHost
+}
+
+// BreakerConfig holds parameters of the circuit breaker guarding upstream calls
+type BreakerConfig struct {
+	FailureThreshold int `yaml:"failure_threshold"`
+	OpenDuration     int `yaml:"open_duration"`
+	HalfOpenProbes   int `yaml:"half_open_probes"`
+}
+
+// GetFailureThreshold returns consecutive failures after which breaker opens, defaults to 5
+func (b BreakerConfig) GetFailureThreshold() int {
+	if b.FailureThreshold > 0 {
+		return b.FailureThreshold
+	}
+	return 5
+}
+
+// GetOpenDuration returns how long breaker stays open before letting probes through, defaults to 30 seconds
+func (b BreakerConfig) GetOpenDuration() time.Duration {
+	return secondsOrDefault(b.OpenDuration, 30)
+}
+
+// GetHalfOpenProbes returns successful probes needed to close the breaker again, defaults to 1
+func (b BreakerConfig) GetHalfOpenProbes() int {
+	if b.HalfOpenProbes > 0 {
+		return b.HalfOpenProbes
+	}
+	return 1
+}
+
+// PassthroughConfig controls forwarding of client supplied tokens upstream
+type PassthroughConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Cached decides how cached routes are kept from leaking private data between callers
+	Cached string `yaml:"cached"`
+}
+
+const (
+	// PassthroughPartition caches responses separately for every client token
+	PassthroughPartition = "partition"
+
+	// PassthroughPublic only ever caches public data, which is shared by all callers
+	PassthroughPublic = "public"
+)
+
+// GetCached returns how cached routes are served in passthrough mode, partitioned by token by default
+func (pt PassthroughConfig) GetCached() string {
+	if pt.Cached == "" {
+		return PassthroughPartition
+	}
+	return pt.Cached
+}
+
+// PublicOnly reports whether the shared cache must be restricted to public data
+func (pt PassthroughConfig) PublicOnly() bool {
+	return pt.Enabled && pt.GetCached() == PassthroughPublic
+}
+
+const (
+	// ProviderGitHub caches github orgs, builds views over their repositories and serves passthrough partitions
+	ProviderGitHub = "github"
+
+	// ProviderHTTP caches the configured endpoints of any json api
+	ProviderHTTP = "http"
+)
+
+// UpstreamAuthConfig decides how target.token is presented to upstream
+type UpstreamAuthConfig struct {
+	// Scheme is one of token (github's "Authorization: token"), bearer, basic or header
+	Scheme string `yaml:"scheme"`
+
+	// Header carries the token as is when scheme is header
+	Header string `yaml:"header"`
+
+	// Username is sent along with token as password when scheme is basic
+	Username string `yaml:"username"`
+}
+
+const (
+	AuthSchemeToken  = "token"
+	AuthSchemeBearer = "bearer"
+	AuthSchemeBasic  = "basic"
+	AuthSchemeHeader = "header"
+)
+
+// GetHeader returns the header credentials are sent upstream in
+func (ua UpstreamAuthConfig) GetHeader() string {
+	if ua.Scheme == AuthSchemeHeader {
+		return ua.Header
+	}
+	return "Authorization"
+}
+
+// GraphQLConfig controls fetching org repositories and members through github's graphql api,
+// which takes far fewer requests than paging through rest
+type GraphQLConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Path of graphql endpoint on upstream host, /graphql by default. Github enterprise serves it under /api/graphql
+	Path string `yaml:"path"`
+}
+
+// PaginationConfig describes how list endpoints of upstream are paged through
+type PaginationConfig struct {
+	// Strategy is one of link (rel="next" in Link header), page, offset, cursor or none
+	Strategy string `yaml:"strategy"`
+
+	// PageParam is the query parameter carrying page number, offset or cursor
+	PageParam string `yaml:"page_param"`
+
+	// SizeParam is the query parameter carrying page size
+	SizeParam string `yaml:"size_param"`
+	Size      int    `yaml:"size"`
+
+	// CursorField is the dot separated path of the next cursor in response body, e.g meta.next
+	CursorField string `yaml:"cursor_field"`
+
+	// ItemsField is the dot separated path of the list in response body, empty if body is the list itself
+	ItemsField string `yaml:"items_field"`
+}
+
+const (
+	PaginateLink   = "link"
+	PaginatePage   = "page"
+	PaginateOffset = "offset"
+	PaginateCursor = "cursor"
+	PaginateNone   = "none"
+)
+
+// GetStrategy returns how lists are paged through, following Link headers by default
+func (pg PaginationConfig) GetStrategy() string {
+	if pg.Strategy == "" {
+		return PaginateLink
+	}
+	return pg.Strategy
+}
+
+// GetPageParam returns the query parameter carrying page number, offset or cursor, named after the strategy by default
+func (pg PaginationConfig) GetPageParam() string {
+	if pg.PageParam == "" {
+		return pg.GetStrategy()
+	}
+	return pg.PageParam
+}
+
+// GetSizeParam returns the query parameter carrying page size, per_page for link and page strategies
+// and limit for the rest by default
+func (pg PaginationConfig) GetSizeParam() string {
+	if pg.SizeParam != "" {
+		return pg.SizeParam
+	}
+	if pg.GetStrategy() == PaginateLink || pg.GetStrategy() == PaginatePage {
+		return "per_page"
+	}
+	return "limit"
+}
+
+// GetSize returns the number of items requested per page, 100 by default
+func (pg PaginationConfig) GetSize() int {
+	if pg.Size > 0 {
+		return pg.Size
+	}
+	return 100
+}
+
+func secondsOrDefault(seconds int, fallback int) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(fallback) * time.Second
+}
+
+// Limit is a token bucket refilled at Rate tokens per second holding at most Burst tokens
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// RateLimitConfig holds limits applied to inbound requests of each route class per client
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Store is where buckets live, "memory" or "redis" for deployments with multiple replicas
+	Store string `yaml:"store"`
+
+	// TrustForwardedFor identifies clients by X-Forwarded-For when running behind a load balancer
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+
+	Limits map[string]Limit `yaml:"limits"`
 }
 
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// GetStore returns where rate limit buckets live, in memory by default
+func (rl RateLimitConfig) GetStore() string {
+	if rl.Store == "" {
+		return RateLimitStoreMemory
+	}
+	return rl.Store
+}
+
+// GetLimit returns the limit for route class, false if the class is not limited
+func (rl RateLimitConfig) GetLimit(class string) (Limit, bool) {
+	limit, ok := rl.Limits[class]
+	return limit, ok && limit.Rate > 0 && limit.Burst > 0
+}
+
+// APIKeyConfig describes a client api key and what it is allowed to do
+type APIKeyConfig struct {
+	ID string `yaml:"id" json:"id"`
+
+	// KeySHA256 is the hex encoded sha256 of the key, Key may be used instead to configure it in plain text
+	KeySHA256 string `yaml:"key_sha256" json:"-"`
+	Key       string `yaml:"key" json:"-"`
+
+	Scopes []string `yaml:"scopes" json:"scopes"`
+
+	// Proxy restricts which upstream paths and methods the key may proxy to
+	Proxy struct {
+		Paths   []string `yaml:"paths" json:"paths,omitempty"`
+		Methods []string `yaml:"methods" json:"methods,omitempty"`
+	} `yaml:"proxy" json:"proxy"`
+}
+
+// AuthConfig holds client authentication settings
+type AuthConfig struct {
+	Enabled bool           `yaml:"enabled"`
+	Keys    []APIKeyConfig `yaml:"keys"`
+}
 
 // Config struct holds all important configuration paramters which 
 // are read from config.yaml file and can be overriden by env variables
 type Config struct
 {
 	Server struct {
-		Port string `yaml:"port"`
+		Port        string `yaml:"port"`
+		ExternalURL string `yaml:"external_url"`
+		RewriteURLs string `yaml:"rewrite_urls"`
+		GRPCPort    string `yaml:"grpc_port"`
+
+		// TrustForwarded honours X-Forwarded-Host and X-Forwarded-Proto set by a proxy in front of us
+		TrustForwarded bool `yaml:"trust_forwarded"`
 	} `yaml:"server"`
 
 	Redis struct {
@@ -31,14 +605,37 @@ type Config struct
 	    Url     string `yaml:"url"`
 	    Token   string `yaml:"token"`
 	    Timeout int    `yaml:"timeout"`
+	    HealthPath string `yaml:"health_path"`
+	    BasePath   string `yaml:"base_path"`
+	    Proxy      ProxyConfig `yaml:"proxy"`
+	    Breaker    BreakerConfig `yaml:"breaker"`
+	    Passthrough PassthroughConfig `yaml:"passthrough"`
+	    Provider   string `yaml:"provider"`
+	    Auth       UpstreamAuthConfig `yaml:"auth"`
+	    Pagination PaginationConfig `yaml:"pagination"`
+	    GraphQL    GraphQLConfig `yaml:"graphql"`
+
+	    // Endpoints are cached in addition to those of orgs, fetched through the provider
+	    Endpoints  []string `yaml:"endpoints"`
 	} `yaml:"target"`
 
 	Cache CacheConfig `yaml:"cache"`
 
+	Admin struct {
+		Token string `yaml:"token"`
+	} `yaml:"admin"`
+
+	RateLimit RateLimitConfig `yaml:"ratelimit"`
+
+	Auth AuthConfig `yaml:"auth"`
+
+	// Org is the single org cached by older deployments, superseded by Orgs
 	Org struct {
-		Name string `yaml: "name"`
+		Name string `yaml:"name"`
 		CachedURL []string `yaml:"cached"`
 	}
+
+	Orgs []OrgConfig `yaml:"orgs"`
 }
 
 var conf *Config
@@ -48,6 +645,41 @@ func (c *Config) GetServerPort() string {
 	return c.Server.Port
 }
 
+// GetGRPCPort returns the port the grpc server listens on, empty if it is disabled
+func (c *Config) GetGRPCPort() string {
+	return c.Server.GRPCPort
+}
+
+const (
+	// RewriteOff never rewrites upstream urls
+	RewriteOff = "off"
+
+	// RewriteProxy rewrites upstream urls in proxied responses only
+	RewriteProxy = "proxy"
+
+	// RewriteAll rewrites upstream urls in proxied responses and cached payloads
+	RewriteAll = "all"
+)
+
+// TrustsForwarded reports whether X-Forwarded-Host and X-Forwarded-Proto of requests are set by a
+// trusted proxy, rather than by clients which could point links anywhere with them
+func (c *Config) TrustsForwarded() bool {
+	return c.Server.TrustForwarded
+}
+
+// GetExternalURL returns the base url clients use to reach the cache, if configured
+func (c *Config) GetExternalURL() string {
+	return strings.TrimSuffix(c.Server.ExternalURL, "/")
+}
+
+// GetRewriteURLs returns where upstream urls get rewritten to point at the cache, proxied responses by default
+func (c *Config) GetRewriteURLs() string {
+	if c.Server.RewriteURLs == "" {
+		return RewriteProxy
+	}
+	return c.Server.RewriteURLs
+}
+
 func (c *Config) GetRedisURL() string {
 	return c.Redis.Url
 }
@@ -64,20 +696,249 @@ func (c* Config) GetTargetUrl() string {
 	return c.UpstreamTarget.Url
 }
 
+// GetTargetBaseURL returns the base url of upstream target, e.g https://api.github.com
+func (c* Config) GetTargetBaseURL() string {
+	return c.GetTargetScheme() + "://" + c.GetTargetUrl() + strings.TrimSuffix(c.UpstreamTarget.BasePath, "/")
+}
+
+// GetTargetBasePath returns the path prefix all upstream apis live under, if any
+func (c* Config) GetTargetBasePath() string {
+	return strings.TrimSuffix(c.UpstreamTarget.BasePath, "/")
+}
+
+// GetBreakerConfig returns parameters of the circuit breaker guarding upstream calls
+func (c* Config) GetBreakerConfig() BreakerConfig {
+	return c.UpstreamTarget.Breaker
+}
+
+// GetProvider returns the kind of upstream api, github by default
+func (c* Config) GetProvider() string {
+	if c.UpstreamTarget.Provider == "" {
+		return ProviderGitHub
+	}
+	return c.UpstreamTarget.Provider
+}
+
+// GetUpstreamAuthConfig returns how our token is presented to upstream, github's token scheme
+// for github and bearer for other providers by default
+func (c* Config) GetUpstreamAuthConfig() UpstreamAuthConfig {
+	auth := c.UpstreamTarget.Auth
+	if auth.Scheme == "" && c.GetProvider() == ProviderGitHub {
+		auth.Scheme = AuthSchemeToken
+	} else if auth.Scheme == "" {
+		auth.Scheme = AuthSchemeBearer
+	}
+	return auth
+}
+
+// GetUpstreamAuthorization returns the header and value presenting our token to upstream,
+// empty if no token is set
+func (c* Config) GetUpstreamAuthorization() (string, string) {
+
+	token := c.GetTargetToken()
+	if token == "" {
+		return "", ""
+	}
+
+	auth := c.GetUpstreamAuthConfig()
+	switch auth.Scheme {
+	case AuthSchemeBearer:
+		return auth.GetHeader(), "Bearer " + token
+	case AuthSchemeBasic:
+		return auth.GetHeader(), "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+token))
+	case AuthSchemeHeader:
+		return auth.GetHeader(), token
+	default:
+		return auth.GetHeader(), "token " + token
+	}
+}
+
+// GetGraphQLConfig returns settings for fetching from github's graphql api
+func (c* Config) GetGraphQLConfig() GraphQLConfig {
+	return c.UpstreamTarget.GraphQL
+}
+
+// GetGraphQLURL returns the url of github's graphql api on upstream host
+func (c* Config) GetGraphQLURL() string {
+	path := c.UpstreamTarget.GraphQL.Path
+	if path == "" {
+		path = "/graphql"
+	}
+	return c.GetTargetScheme() + "://" + c.GetTargetUrl() + path
+}
+
+// GetPaginationConfig returns how list endpoints of upstream are paged through
+func (c* Config) GetPaginationConfig() PaginationConfig {
+	return c.UpstreamTarget.Pagination
+}
+
+// GetEndpoints returns endpoints cached in addition to those of orgs
+func (c* Config) GetEndpoints() []string {
+	return c.UpstreamTarget.Endpoints
+}
+
+// GetPassthroughConfig returns settings for forwarding client supplied tokens upstream
+func (c* Config) GetPassthroughConfig() PassthroughConfig {
+	return c.UpstreamTarget.Passthrough
+}
+
+// GetProxyConfig returns tuning parameters of the reverse proxy
+func (c* Config) GetProxyConfig() ProxyConfig {
+	return c.UpstreamTarget.Proxy
+}
+
 func (c* Config) GetTargetTimeout() int {
 	return c.UpstreamTarget.Timeout
 }
 
+// GetTargetHealthPath returns the upstream path probed by readiness checks
+func (c* Config) GetTargetHealthPath() string {
+	if c.UpstreamTarget.HealthPath == "" {
+		return "/rate_limit"
+	}
+	return c.UpstreamTarget.HealthPath
+}
+
 func (c* Config) GetCacheConfig() CacheConfig {
 	return c.Cache
 }
 
+// GetCachedURLs returns every endpoint served from cache, those configured under target.endpoints
+// and for github the root endpoint and those of each org
 func (c *Config) GetCachedURLs() []string {
-	return c.Org.CachedURL
+
+	var urls []string
+	seen := make(map[string]bool)
+
+	add := func(url string) {
+		if !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+
+	if c.GetProvider() == ProviderGitHub {
+		add("/")
+		for _, org := range c.GetOrgs() {
+			for _, url := range org.GetCachedURLs() {
+				add(url)
+			}
+		}
+	}
+
+	for _, url := range c.GetEndpoints() {
+		add(url)
+	}
+	return urls
+}
+
+// GetRefreshInterval returns how often url is refreshed, the interval of the owning org if any.
+// Sub-resources of repositories and profiles of members refresh at their own interval
+func (c *Config) GetRefreshInterval(url string) time.Duration {
+	for _, org := range c.GetOrgs() {
+		if !org.Owns(url) {
+			continue
+		}
+
+		refresh := secondsOrDefault(org.Refresh, c.Cache.RefreshInterval)
+		if url == org.GetMemberProfilesJob() {
+			return org.Members.GetRefresh(refresh)
+		}
+		if _, resource, ok := org.ParseRepoURL(url); ok && resource != "" {
+			return org.Repos.GetRefresh(refresh)
+		}
+		return refresh
+	}
+	return time.Duration(c.Cache.RefreshInterval) * time.Second
+}
+
+// GetRepoRoutes returns route templates of repository endpoints of every org served from cache
+func (c *Config) GetRepoRoutes() []string {
+
+	if c.GetProvider() != ProviderGitHub {
+		return nil
+	}
+
+	var routes []string
+	for _, org := range c.GetOrgs() {
+		route := org.GetRepoURL("{repo}")
+		routes = append(routes, route)
+		for _, path := range org.Repos.GetResourcePaths() {
+			routes = append(routes, route+path)
+		}
+	}
+	return routes
+}
+
+// GetIgnoredQueryParams returns query parameters dropped from requests to cached routes,
+// page and per_page along with the paging parameters of upstream by default
+func (c *Config) GetIgnoredQueryParams() []string {
+
+	if len(c.Cache.Query.Ignore) > 0 {
+		return c.Cache.Query.Ignore
+	}
+
+	pagination := c.GetPaginationConfig()
+	return []string{"page", "per_page", pagination.GetPageParam(), pagination.GetSizeParam()}
+}
+
+// GetKeyMaxStaleness returns how old the key cached for url may get before it is considered stale,
+// never less than three of its refresh intervals
+func (c *Config) GetKeyMaxStaleness(url string) time.Duration {
+	return c.GetMaxStalenessFor(c.GetRefreshInterval(url))
+}
+
+// GetMaxStalenessFor returns how old data refreshed every refresh interval may get before it is
+// considered stale, never less than three of those intervals
+func (c *Config) GetMaxStalenessFor(refresh time.Duration) time.Duration {
+	staleness := c.GetCacheConfig().GetMaxStaleness()
+	if 3*refresh > staleness {
+		return 3 * refresh
+	}
+	return staleness
+}
+
+// GetRateLimitConfig returns limits applied to inbound requests
+func (c *Config) GetRateLimitConfig() RateLimitConfig {
+	return c.RateLimit
+}
+
+// GetAuthConfig returns client authentication settings
+func (c *Config) GetAuthConfig() AuthConfig {
+	return c.Auth
+}
+
+// GetAdminToken returns the token required to access admin api. Admin api is disabled when empty
+func (c *Config) GetAdminToken() string {
+	return c.Admin.Token
+}
+
+// GetOrgs returns the orgs whose data is cached, falling back to the single org configured
+// under org by older deployments
+func (c *Config) GetOrgs() []OrgConfig {
+	if len(c.Orgs) == 0 && c.Org.Name != "" {
+		return []OrgConfig{{Name: c.Org.Name, Cached: c.Org.CachedURL}}
+	}
+	return c.Orgs
+}
+
+// GetOrgNames returns names of the orgs whose data is cached
+func (c *Config) GetOrgNames() []string {
+	var names []string
+	for _, org := range c.GetOrgs() {
+		names = append(names, org.Name)
+	}
+	return names
 }
 
-func (c *Config) GetOrg() string {
-	return c.Org.Name
+// FindOrg returns the configured org with name, false if there is none
+func (c *Config) FindOrg(name string) (OrgConfig, bool) {
+	for _, org := range c.GetOrgs() {
+		if org.Name == name {
+			return org, true
+		}
+	}
+	return OrgConfig{}, false
 }
 
 // InitConfig initializes the config object for our program. Typically to be called before starting server instance
@@ -98,11 +959,168 @@ func InitConfig() (*Config, error) {
 	return conf, nil
 }
 
+// Validate checks the configuration for missing or inconsistent values and reports all problems found
+func (c *Config) Validate() error {
+
+	var problems []string
+
+	switch c.GetRewriteURLs() {
+	case RewriteOff, RewriteProxy:
+	case RewriteAll:
+		if c.GetExternalURL() == "" {
+			problems = append(problems, "server.external_url is required when server.rewrite_urls is all")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("server.rewrite_urls must be %s, %s or %s, got %q",
+			RewriteOff, RewriteProxy, RewriteAll, c.GetRewriteURLs()))
+	}
+	if port := c.GetGRPCPort(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			problems = append(problems, fmt.Sprintf("server.grpc_port must be a port number, got %q", port))
+		}
+	}
+	if c.GetRedisURL() == "" {
+		problems = append(problems, "redis.url is not set")
+	}
+	if c.GetTargetScheme() != "http" && c.GetTargetScheme() != "https" {
+		problems = append(problems, fmt.Sprintf("target.scheme must be http or https, got %q", c.GetTargetScheme()))
+	}
+	if c.GetTargetUrl() == "" {
+		problems = append(problems, "target.url is not set")
+	}
+	if bp := c.UpstreamTarget.BasePath; bp != "" && !strings.HasPrefix(bp, "/") {
+		problems = append(problems, fmt.Sprintf("target.base_path %q must start with /", bp))
+	}
+	if c.GetTargetTimeout() <= 0 {
+		problems = append(problems, "target.timeout must be positive")
+	}
+	if c.GetCacheConfig().RefreshInterval <= 0 {
+		problems = append(problems, "cache.refresh must be positive")
+	}
+	if cs := c.GetCacheConfig().GetColdStart(); cs != ColdStartProxy && cs != ColdStartUnavailable {
+		problems = append(problems, fmt.Sprintf("cache.cold_start must be %s or %s, got %q",
+			ColdStartProxy, ColdStartUnavailable, cs))
+	}
+	if st := c.GetRateLimitConfig().GetStore(); st != RateLimitStoreMemory && st != RateLimitStoreRedis {
+		problems = append(problems, fmt.Sprintf("ratelimit.store must be %s or %s, got %q",
+			RateLimitStoreMemory, RateLimitStoreRedis, st))
+	}
+	for _, pattern := range append(c.GetProxyConfig().Policy.Allow, c.GetProxyConfig().Policy.Deny...) {
+		if strings.HasPrefix(pattern, "regex:") {
+			if _, err := regexp.Compile(strings.TrimPrefix(pattern, "regex:")); err != nil {
+				problems = append(problems, fmt.Sprintf("proxy policy pattern %q: %s", pattern, err))
+			}
+		}
+	}
+	switch c.GetCacheConfig().Compression.GetStore() {
+	case EncodingNone, EncodingGzip, EncodingBrotli:
+	default:
+		problems = append(problems, fmt.Sprintf("cache.compression.store must be %s, %s or %s, got %q",
+			EncodingNone, EncodingGzip, EncodingBrotli, c.GetCacheConfig().Compression.GetStore()))
+	}
+	if pt := c.GetPassthroughConfig().GetCached(); pt != PassthroughPartition && pt != PassthroughPublic {
+		problems = append(problems, fmt.Sprintf("target.passthrough.cached must be %s or %s, got %q",
+			PassthroughPartition, PassthroughPublic, pt))
+	}
+	for _, key := range c.GetAuthConfig().Keys {
+		if key.ID == "" || (key.Key == "" && key.KeySHA256 == "") {
+			problems = append(problems, "auth.keys entries need an id and a key or key_sha256")
+		}
+	}
+	switch c.GetProvider() {
+	case ProviderGitHub:
+		if len(c.GetOrgs()) == 0 {
+			problems = append(problems, "orgs is not set")
+		}
+	case ProviderHTTP:
+		if len(c.GetOrgs()) > 0 {
+			problems = append(problems, "orgs are only supported by the github provider")
+		}
+		if c.GetGraphQLConfig().Enabled {
+			problems = append(problems, "target.graphql is only supported by the github provider")
+		}
+		if len(c.GetEndpoints()) == 0 {
+			problems = append(problems, "target.endpoints is not set")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("target.provider must be %s or %s, got %q",
+			ProviderGitHub, ProviderHTTP, c.GetProvider()))
+	}
+	switch auth := c.GetUpstreamAuthConfig(); auth.Scheme {
+	case AuthSchemeToken, AuthSchemeBearer, AuthSchemeBasic:
+	case AuthSchemeHeader:
+		if auth.Header == "" {
+			problems = append(problems, "target.auth.header is required when target.auth.scheme is header")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("target.auth.scheme must be %s, %s, %s or %s, got %q",
+			AuthSchemeToken, AuthSchemeBearer, AuthSchemeBasic, AuthSchemeHeader, auth.Scheme))
+	}
+	switch pg := c.GetPaginationConfig(); pg.GetStrategy() {
+	case PaginateLink, PaginatePage, PaginateOffset, PaginateNone:
+	case PaginateCursor:
+		if pg.CursorField == "" {
+			problems = append(problems, "target.pagination.cursor_field is required when strategy is cursor")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("target.pagination.strategy must be %s, %s, %s, %s or %s, got %q",
+			PaginateLink, PaginatePage, PaginateOffset, PaginateCursor, PaginateNone, pg.GetStrategy()))
+	}
+	orgs := make(map[string]bool)
+	for _, org := range c.GetOrgs() {
+		if org.Name == "" || strings.Contains(org.Name, "/") {
+			problems = append(problems, fmt.Sprintf("org name %q is invalid", org.Name))
+		}
+		if orgs[org.Name] {
+			problems = append(problems, fmt.Sprintf("org %s is configured more than once", org.Name))
+		}
+		orgs[org.Name] = true
+
+		// views of members are served under /view/members
+		if org.Name == "members" {
+			problems = append(problems, "org name members clashes with member views")
+		}
+		if org.Members.Profiles && !org.CachesMembers() {
+			problems = append(problems, fmt.Sprintf("org %s fetches member profiles without caching its members", org.Name))
+		}
+
+		for _, resource := range org.Repos.Resources {
+			if _, ok := repoResourcePaths[resource]; !ok {
+				problems = append(problems, fmt.Sprintf("org %s caches unknown repository resource %q", org.Name, resource))
+			}
+		}
+	}
+	for _, url := range c.GetCachedURLs() {
+		if !strings.HasPrefix(url, "/") {
+			problems = append(problems, fmt.Sprintf("cached url %q must start with /", url))
+		}
+	}
+	for _, pattern := range c.Cache.Routes {
+		if strings.HasPrefix(pattern, "regex:") {
+			if _, err := regexp.Compile(strings.TrimPrefix(pattern, "regex:")); err != nil {
+				problems = append(problems, fmt.Sprintf("cached route %q is invalid: %s", pattern, err))
+			}
+		} else if !strings.HasPrefix(pattern, "/") {
+			problems = append(problems, fmt.Sprintf("cached route %q must start with /", pattern))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 // GetConfig provides public access to config
 func GetConfig() *Config {
 	return conf
 }
 
+// SetConfig replaces the config, for code building it rather than reading it from file such as tests
+func SetConfig(c *Config) {
+	conf = c
+}
+
 // reads the config yaml file and sets the config struct
 func readFromFile(cfg *Config) (err error) {
 	
@@ -130,5 +1148,21 @@ func readFromEnv(cfg *Config) (err error) {
 	if os.Getenv("REDIS_URL") != "" {
 		cfg.Redis.Url = os.Getenv("REDIS_URL")
 	}
+
+	if os.Getenv("CACHE_SNAPSHOT") != "" {
+		cfg.Cache.Snapshot = os.Getenv("CACHE_SNAPSHOT")
+	}
+
+	if os.Getenv("EXTERNAL_URL") != "" {
+		cfg.Server.ExternalURL = os.Getenv("EXTERNAL_URL")
+	}
+
+	if os.Getenv("GRPC_PORT") != "" {
+		cfg.Server.GRPCPort = os.Getenv("GRPC_PORT")
+	}
+
+	if os.Getenv("ADMIN_API_TOKEN") != "" {
+		cfg.Admin.Token = os.Getenv("ADMIN_API_TOKEN")
+	}
 	return nil
 }
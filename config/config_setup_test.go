@@ -0,0 +1,100 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetRefreshInterval(t *testing.T) {
+
+	conf := &Config{}
+	conf.Cache.RefreshInterval = 60
+	conf.Orgs = []OrgConfig{
+		{Name: "Netflix", Refresh: 300},
+		{Name: "Google", Repos: RepoConfig{Refresh: 900}, Members: MemberConfig{Refresh: 1200}},
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want time.Duration
+	}{
+		{"endpoints outside orgs", "/", time.Minute},
+		{"org endpoint", "/orgs/Netflix", 5 * time.Minute},
+		{"org defaults to cache refresh", "/orgs/Google/repos", time.Minute},
+		{"repository", "/repos/Netflix/zuul", 5 * time.Minute},
+		{"sub-resources of repositories", "/repos/Netflix/zuul/languages", 50 * time.Minute},
+		{"sub-resources configured", "/repos/Google/guava/topics", 15 * time.Minute},
+		{"repository resources job", "/repos/Netflix/*/*", 50 * time.Minute},
+		{"members", "/orgs/Netflix/members", 5 * time.Minute},
+		{"member profiles job", "/orgs/Netflix/members/*", 50 * time.Minute},
+		{"member profiles configured", "/orgs/Google/members/*", 20 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conf.GetRefreshInterval(tt.url); got != tt.want {
+				t.Errorf("GetRefreshInterval(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMaxStalenessFor(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		maxStaleness int
+		refresh      time.Duration
+		want         time.Duration
+	}{
+		{"configured staleness", 600, time.Minute, 10 * time.Minute},
+		{"never less than three refreshes", 600, 10 * time.Minute, 30 * time.Minute},
+		{"defaults to three cache refreshes", 0, 0, 3 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			conf := &Config{}
+			conf.Cache.RefreshInterval = 60
+			conf.Cache.MaxStaleness = tt.maxStaleness
+
+			if got := conf.GetMaxStalenessFor(tt.refresh); got != tt.want {
+				t.Errorf("GetMaxStalenessFor(%v) = %v, want %v", tt.refresh, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		routes []string
+		valid  bool
+	}{
+		{"templates and globs", []string{"/users/{user}/repos", "/gists/*.json", "/teams/**"}, true},
+		{"regular expression", []string{"regex:^/emojis/[a-z]+$"}, true},
+		{"invalid regular expression", []string{"regex:^/emojis/[a-z+$"}, false},
+		{"relative path", []string{"users/{user}/repos"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			conf := &Config{}
+			conf.Redis.Url = "localhost:6379"
+			conf.UpstreamTarget.Scheme = "https"
+			conf.UpstreamTarget.Url = "api.github.com"
+			conf.UpstreamTarget.Timeout = 10
+			conf.Cache.RefreshInterval = 60
+			conf.Orgs = []OrgConfig{{Name: "Netflix"}}
+			conf.Cache.Routes = tt.routes
+
+			if err := conf.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/aniketalshi/go_rest_cache/config"
 	"github.com/aniketalshi/go_rest_cache/app"
 )
 
+// shutdownTimeout is how long requests in flight may take to complete once shutting down
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	httpPort := flag.String("http_port", "3000", "Port to listen for HTTP traffic")
 	
@@ -16,17 +25,54 @@ func main() {
 	flag.Parse()
 
 	// initialize the config
-	_, err := config.InitConfig()
+	cfg, err := config.InitConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// refuse to start on settings which would otherwise be ignored or misbehave at runtime
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
 	// initialize the application
 	app := &app.App{}
 	app.Initialize()
 	app.Run()
 	
+	server := &http.Server{Addr: ":" + *httpPort, Handler: app.Handler}
+
+	// on interrupt stop taking requests, letting those in flight complete for a while
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		// grpc and http wind down side by side, neither eating into the time of the other
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			app.Shutdown(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Print("Error shutting down http server: " + err.Error())
+			}
+		}()
+		wg.Wait()
+		close(stopped)
+	}()
+
 	// launch the server 
-	log.Fatal(http.ListenAndServe(":" + *httpPort, app.Handler))
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
 }
 
@@ -35,12 +35,13 @@ function report() {
     echo "$PASS/$TOTAL ($PCT%) tests passed"
 }
 
-describe "test-01-01: healthcheck = "
+# readiness only succeeds once every cached key and view has been populated
+describe "test-01-01: readiness = "
 
 ATTEMPTS=0
 while true; do
     let ATTEMPTS=$ATTEMPTS+1
-    RESPONSE=$(curl -s -o /dev/null -w '%{http_code}' "$HEALTHCHECK_URL/healthcheck")
+    RESPONSE=$(curl -s -o /dev/null -w '%{http_code}' "$HEALTHCHECK_URL/readyz")
     if [[ $RESPONSE == "200" ]]; then
         let TIME=$ATTEMPTS*15
         echo -n "($TIME seconds) "; pass
@@ -345,4 +346,105 @@ else
     fail "$VALUE" '[["Netflix/Hystrix",17256],["Netflix/falcor",9318],["Netflix/eureka",7685],["Netflix/pollyjs",7630],["Netflix/zuul",7437],["Netflix/SimianArmy",7137],["Netflix/chaosmonkey",6371],["Netflix/fast_jsonapi",4262],["Netflix/security_monkey",3531],["Netflix/vector",3121]]'
 fi
 
+describe "test-06-09: /view/Netflix/top/5/stars = "
+
+VALUE=$(curl -s "$BASE_URL/view/Netflix/top/5/stars" |tr -d '\n' |sed -e 's/ //g')
+
+if [[ "$VALUE" == '[["Netflix/Hystrix",17256],["Netflix/falcor",9318],["Netflix/eureka",7685],["Netflix/pollyjs",7630],["Netflix/zuul",7437]]' ]]; then
+    pass
+else
+    fail "$VALUE" '[["Netflix/Hystrix",17256],["Netflix/falcor",9318],["Netflix/eureka",7685],["Netflix/pollyjs",7630],["Netflix/zuul",7437]]'
+fi
+
+describe "test-06-10: /view/members/Netflix/top/5/timeline status = "
+
+STATUS=$(curl -s -o /dev/null -w "%{http_code}" "$BASE_URL/view/members/Netflix/top/5/timeline")
+
+if [[ "$STATUS" == "200" ]]; then
+    pass
+else
+    fail "$STATUS" "200"
+fi
+
+describe "test-06-11: POST /orgs/Netflix/repos is proxied, rejected by default policy = "
+
+STATUS=$(curl -s -o /dev/null -w "%{http_code}" -X POST -d '{}' "$BASE_URL/orgs/Netflix/repos")
+
+if [[ "$STATUS" == "405" ]]; then
+    pass
+else
+    fail "$STATUS" "405"
+fi
+
+describe "test-06-12: DELETE /repos/Netflix/zuul is proxied, rejected by default policy = "
+
+STATUS=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE "$BASE_URL/repos/Netflix/zuul")
+
+if [[ "$STATUS" == "405" ]]; then
+    pass
+else
+    fail "$STATUS" "405"
+fi
+
+describe "test-07-01: /repos/Netflix/zuul full_name = "
+
+VALUE=$(curl -s "$BASE_URL/repos/Netflix/zuul" |jq -r '.full_name')
+
+if [[ "$VALUE" == "Netflix/zuul" ]]; then
+    pass
+else
+    fail "$VALUE" "Netflix/zuul"
+fi
+
+describe "test-07-02: /orgs/Netflix/repos?per_page=10&page=2 object count = "
+
+COUNT=$(curl -s "$BASE_URL/orgs/Netflix/repos?per_page=10&page=2" |jq -r '. |length')
+
+if [[ $COUNT -eq 10 ]]; then
+    pass
+else
+    fail "$COUNT" "10"
+fi
+
+describe "test-07-03: /orgs/Netflix/repos?per_page=10&page=2 Link rel next = "
+
+VALUE=$(curl -s -D - -o /dev/null "$BASE_URL/orgs/Netflix/repos?per_page=10&page=2" |grep -i '^link:' |grep -o '[?&]page=[0-9]*[^>]*>; rel="next"' |grep -o 'page=[0-9]*' |head -1)
+
+if [[ "$VALUE" == "page=3" ]]; then
+    pass
+else
+    fail "$VALUE" "page=3"
+fi
+
+describe "test-08-01: /search/repos?q=zuul first result full_name = "
+
+VALUE=$(curl -s "$BASE_URL/search/repos?q=zuul" |jq -r '.items[0].full_name')
+
+if [[ "$VALUE" == "Netflix/zuul" ]]; then
+    pass
+else
+    fail "$VALUE" "Netflix/zuul"
+fi
+
+describe "test-08-02: /search/repos?q=language:Java+stars:>1000 results not in Java = "
+
+COUNT=$(curl -s "$BASE_URL/search/repos?q=language:Java+stars:%3E1000&per_page=100" |jq -r '[.items[] | select(.language != "Java" or .stargazers_count <= 1000)] |length')
+
+if [[ $COUNT -eq 0 ]]; then
+    pass
+else
+    fail "$COUNT" "0"
+fi
+
+describe "test-09-01: /graphql organization top by stars count = "
+
+COUNT=$(curl -s -X POST -H 'Content-Type: application/json' "$BASE_URL/graphql" \
+    -d '{"query": "{ organization(login: \"Netflix\") { top(metric: STARS, limit: 5) { name } } }"}' |jq -r '.data.organization.top |length')
+
+if [[ $COUNT -eq 5 ]]; then
+    pass
+else
+    fail "$COUNT" "5"
+fi
+
 report